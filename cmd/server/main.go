@@ -1,27 +1,88 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	"github.com/rs/cors"
 
 	"github.com/aldotobing/neurogo/config"
+	"github.com/aldotobing/neurogo/prompts"
 	"github.com/aldotobing/neurogo/providers"
+	"github.com/aldotobing/neurogo/rag"
 	"github.com/aldotobing/neurogo/router"
+	"github.com/aldotobing/neurogo/routes"
 	"github.com/aldotobing/neurogo/server"
+	"github.com/aldotobing/neurogo/summarize"
+	"github.com/aldotobing/neurogo/tokens"
+	"github.com/aldotobing/neurogo/vector"
+	"github.com/aldotobing/neurogo/workflow"
 )
 
 // Global provider registry and current provider
 var providerRegistry = make(map[string]providers.Provider)
 var currentProvider = ""
 
+// modelCatalog caches the models each configured provider serves
+var modelCatalog = providers.NewCatalog(10 * time.Minute)
+
+// selectedModels holds the model chosen with "use provider/model" for each
+// provider
+var selectedModels = struct {
+	sync.Mutex
+	byProvider map[string]string
+}{byProvider: make(map[string]string)}
+
+// taskRequirements are what each task type needs from a provider in auto
+// mode, with the providers to try first
+var taskRequirements = map[string]providers.Requirements{
+	"translation": {Prefer: []string{"Gemini", "OpenAI", "DeepSeek", "Ollama"}},
+	"reasoning":   {Prefer: []string{"DeepSeek", "OpenAI", "Gemini", "Ollama"}},
+	"coding":      {Prefer: []string{"Ollama", "OpenAI", "DeepSeek", "Gemini"}},
+	"summary":     {MinContext: 4096, Prefer: []string{"OpenAI", "DeepSeek", "Gemini", "Ollama"}},
+	"rag":         {MinContext: 4096, Prefer: []string{"OpenAI", "DeepSeek", "Gemini", "Ollama"}},
+	"general":     {Prefer: []string{"OpenAI", "DeepSeek", "Gemini", "Ollama"}},
+}
+
+// contextStrategies are the strategies for prompts too long for the model
+// of task types whose prompts can be split. Other task types use
+// contextStrategy.
+var contextStrategies = map[string]tokens.Strategy{
+	"summary":     tokens.MapReduce,
+	"translation": tokens.MapReduce,
+}
+
+// contextStrategy is the strategy for prompts too long for the model, set
+// with CONTEXT_STRATEGY
+var contextStrategy = tokens.Reject
+
+// modelSelector picks the provider and model for requests in auto mode,
+// trying each provider's chosen or default model first
+var modelSelector = providers.NewSelector(modelCatalog, providers.WithPreferredModel(func(name string) string {
+	return getModelForProvider(modelCatalog.Provider(name))
+}))
+
+// promptLibrary holds the prompt templates routes reference by name
+var promptLibrary *prompts.Library
+
+// documents holds the document collections questions are answered from,
+// or is nil if RAG_EMBEDDING_PROVIDER is not set
+var documents *rag.Manager
+
 func main() {
 	// Debug: Print current working directory and file existence
 	if cwd, err := os.Getwd(); err == nil {
@@ -41,30 +102,77 @@ func main() {
 	// Load environment variables with better error handling
 	loadEnvironment()
 
+	// Choose what happens to prompts too long for the model
+	setupContextStrategy()
+
 	// Initialize the NeuroGO router
 	neuroRouter := router.New()
+	neuroRouter.Use(router.Recover(), router.Logging())
 
 	// Configure providers
 	setupProviders(neuroRouter)
 
+	// Load the prompt templates used by the universal routes
+	setupPrompts()
+
+	// "summarize [file]" summarizes a document instead of starting the
+	// server
+	if len(os.Args) > 1 && os.Args[1] == "summarize" {
+		os.Exit(summarizeCommand(os.Args[2:]))
+	}
+
 	// Setup universal routes (works with any provider)
 	setupUniversalRoutes(neuroRouter)
 
 	// Setup provider switching routes
 	setupProviderSwitchingRoutes(neuroRouter)
 
+	// Setup Ollama model management routes
+	setupOllamaRoutes(neuroRouter)
+
+	// Setup document collections for questions about local documents
+	setupDocuments()
+	setupDocumentRoutes(neuroRouter)
+
 	// Setup example routes
 	setupExampleRoutes(neuroRouter)
 
+	// Load routes declared in ROUTES_FILE
+	routeLoader := setupRouteConfig(neuroRouter)
+
+	// Optionally match unmatched prompts to routes by embedding similarity,
+	// then let an AI provider classify whatever is left
+	setupSemanticRouter(neuroRouter)
+	setupIntentClassifier(neuroRouter)
+
+	// Suggest commands for prompts nothing handles, or forward them to
+	// NOT_FOUND_ROUTE, and give handler errors an HTTP friendly code
+	setupErrorHandlers(neuroRouter)
+
 	// Create HTTP server
 	httpRouter := mux.NewRouter()
 
 	// Setup API routes
 	api := httpRouter.PathPrefix("/api").Subrouter()
 	server.SetupAPIRoutes(api, neuroRouter)
+	server.SetupModelRoutes(api, modelCatalog)
+	server.SetupSelectionRoutes(api, modelSelector)
+	server.SetupSummaryRoutes(api, summarizerFor)
+	server.SetupWorkflowRoutes(api, newWorkflowEngine(neuroRouter), loadWorkflows())
+	if routeLoader != nil {
+		server.SetupRouteReload(api, routeLoader)
+	}
 
 	// Setup WebSocket for real-time communication
-	server.SetupWebSocket(httpRouter, neuroRouter)
+	var wsOptions []server.WebSocketOption
+	if documents != nil {
+		server.SetupCollectionRoutes(api, documents, askDocuments)
+	}
+	if ollama := ollamaProvider(); ollama != nil {
+		server.SetupOllamaRoutes(api, ollama)
+		wsOptions = append(wsOptions, server.WithModelManager(ollama))
+	}
+	server.SetupWebSocket(httpRouter, neuroRouter, wsOptions...)
 
 	// Serve static files for the playground
 	setupStaticFiles(httpRouter)
@@ -135,48 +243,27 @@ func debugEnvironmentVariables() {
 	}
 }
 
-// getCurrentProvider returns the currently selected provider or the best available one
-func getCurrentProvider(taskType string) providers.Provider {
-	// If a specific provider is selected, use it
+// selectFor returns the current provider with its model, or in auto mode
+// the best provider and model that satisfy the task type's requirements
+// and the extra ones
+func selectFor(taskType string, extra providers.Requirements) (*providers.Selection, error) {
 	if currentProvider != "" {
 		if provider, exists := providerRegistry[currentProvider]; exists {
-			return provider
+			return &providers.Selection{
+				Provider: provider,
+				Model:    providers.Model{ID: getModelForProvider(provider), Provider: currentProvider},
+			}, nil
 		}
 	}
 
-	// Otherwise, use the best provider for the task type
-	return getBestProvider(taskType)
-}
-
-// getBestProvider returns the best available provider for a given task type
-func getBestProvider(taskType string) providers.Provider {
-	// Provider preferences for different task types
-	preferences := map[string][]string{
-		"translation": {"Gemini", "OpenAI", "DeepSeek", "Ollama"},
-		"reasoning":   {"DeepSeek", "OpenAI", "Gemini", "Ollama"},
-		"coding":      {"Ollama", "OpenAI", "DeepSeek", "Gemini"},
-		"summary":     {"OpenAI", "DeepSeek", "Gemini", "Ollama"},
-		"general":     {"OpenAI", "DeepSeek", "Gemini", "Ollama"},
-	}
-
-	providerList, exists := preferences[taskType]
+	requirements, exists := taskRequirements[taskType]
 	if !exists {
-		providerList = preferences["general"]
-	}
-
-	// Return the first available provider from the preference list
-	for _, providerName := range providerList {
-		if provider, exists := providerRegistry[providerName]; exists {
-			return provider
-		}
+		requirements = taskRequirements["general"]
 	}
 
-	// Fallback: return any available provider
-	for _, provider := range providerRegistry {
-		return provider
-	}
-
-	return nil
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return modelSelector.Select(ctx, requirements.Merge(extra))
 }
 
 // getProviderList returns a list of available provider names
@@ -269,6 +356,20 @@ func setupProviders(r *router.Router) {
 		log.Printf("🎯 Default provider set to: %s", currentProvider)
 	}
 
+	// List the models of every provider in the background, so the first
+	// requests do not wait for it
+	for _, name := range availableProviders {
+		modelCatalog.Add(providerRegistry[name])
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_, errs := modelCatalog.All(ctx)
+		for name, err := range errs {
+			log.Printf("⚠️  Could not list %s models: %v", name, err)
+		}
+	}()
+
 	// Log summary
 	if len(availableProviders) == 0 {
 		log.Println("⚠️  No AI providers configured. Only basic commands will work.")
@@ -279,42 +380,274 @@ func setupProviders(r *router.Router) {
 	}
 }
 
+// setupIntentClassifier registers an LLM-based fallback for unmatched
+// prompts when INTENT_CLASSIFIER_PROVIDER names a configured provider
+func setupIntentClassifier(r *router.Router) {
+	name := os.Getenv("INTENT_CLASSIFIER_PROVIDER")
+	if name == "" {
+		return
+	}
+
+	provider, exists := providerRegistry[normalizeProviderName(name)]
+	if !exists {
+		log.Printf("⚠️  Intent classifier provider '%s' not available", name)
+		return
+	}
+
+	r.UseFallback(&router.Classifier{
+		Provider:  provider,
+		Options:   config.CompletionOptions{Model: getModelForProvider(provider)},
+		Threshold: envFloat("INTENT_CLASSIFIER_THRESHOLD"),
+	})
+	log.Printf("🧭 Intent classifier enabled using %s", provider.GetName())
+}
+
+// setupSemanticRouter registers an embedding similarity fallback for
+// unmatched prompts when SEMANTIC_ROUTER_PROVIDER names a configured
+// provider that supports embeddings
+func setupSemanticRouter(r *router.Router) {
+	name := os.Getenv("SEMANTIC_ROUTER_PROVIDER")
+	if name == "" {
+		return
+	}
+
+	provider, exists := providerRegistry[normalizeProviderName(name)]
+	if !exists {
+		log.Printf("⚠️  Semantic router provider '%s' not available", name)
+		return
+	}
+
+	embedder, ok := provider.(providers.Embedder)
+	if !ok {
+		log.Printf("⚠️  Provider %s does not support embeddings", provider.GetName())
+		return
+	}
+
+	semantic := router.NewSemanticRouter(embedder, os.Getenv("SEMANTIC_ROUTER_MODEL"))
+	semantic.Threshold = envFloat("SEMANTIC_ROUTER_THRESHOLD")
+
+	// Embed the route examples once at startup
+	if err := semantic.Index(r.Routes()); err != nil {
+		log.Printf("❌ Semantic router indexing failed: %v", err)
+		return
+	}
+
+	r.UseFallback(semantic)
+	log.Printf("🧲 Semantic router enabled using %s", provider.GetName())
+}
+
+// setupErrorHandlers configures how unmatched prompts and handler errors
+// are reported. Unmatched prompts get "did you mean" suggestions unless
+// NOT_FOUND_ROUTE names a route, such as "chat *", to forward them to.
+func setupErrorHandlers(r *router.Router) {
+	if pattern := os.Getenv("NOT_FOUND_ROUTE"); pattern != "" {
+		r.SetNotFoundHandler(router.Forward(pattern))
+		log.Printf("↪️  Unmatched prompts are forwarded to '%s'", pattern)
+	} else {
+		r.SetNotFoundHandler(router.DidYouMean(3))
+	}
+
+	// Route handlers mostly fail when the provider does, so errors without
+	// a code are reported as provider errors, except for panics
+	r.SetErrorHandler(func(ctx *router.Context, err error) error {
+		if router.CodeOf(err) != router.CodeInternal || errors.Is(err, router.ErrPanic) {
+			return err
+		}
+		if errors.Is(err, tokens.ErrContextLength) {
+			return router.WithCode(router.CodeInvalidInput, err)
+		}
+		return router.WithCode(router.CodeProvider, err)
+	})
+}
+
+// setupRouteConfig mounts the routes declared in ROUTES_FILE and, if
+// ROUTES_WATCH_INTERVAL is set, reloads them whenever the file changes
+func setupRouteConfig(r *router.Router) *routes.Loader {
+	path := os.Getenv("ROUTES_FILE")
+	if path == "" {
+		return nil
+	}
+
+	loader := routes.NewLoader(r, path, routes.Backend{
+		Provider: func(ctx *router.Context) (providers.Provider, error) {
+			provider, ok := ctx.Get(providerKey).(providers.Provider)
+			if !ok {
+				return nil, router.WithCode(router.CodeInternal, fmt.Errorf("no provider was selected for route %q", ctx.MatchedPattern))
+			}
+			return provider, nil
+		},
+		Model: func(ctx *router.Context, provider providers.Provider) string {
+			if model := requestModel(ctx); model != "" {
+				return model
+			}
+			return getModelForProvider(provider)
+		},
+		Options: func(def *routes.Definition) []router.RouteOption {
+			taskType := def.TaskType
+			if taskType == "" {
+				taskType = "general"
+			}
+			return []router.RouteOption{
+				router.WithMiddleware(preferProvider(def.Provider)),
+				providerRoute(taskType),
+			}
+		},
+		Prompts: promptLibrary,
+	})
+
+	count, err := loader.Load()
+	if err != nil {
+		log.Printf("❌ Loading routes from %s failed: %v", path, err)
+	} else {
+		log.Printf("📄 Loaded %d routes from %s", count, path)
+	}
+
+	if value := os.Getenv("ROUTES_WATCH_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			log.Printf("⚠️  Invalid ROUTES_WATCH_INTERVAL '%s', not watching %s", value, path)
+		} else {
+			go loader.Watch(context.Background(), interval)
+			log.Printf("👀 Watching %s for changes every %s", path, interval)
+		}
+	}
+	return loader
+}
+
+// preferProvider makes a route prefer the named provider in auto mode, the
+// same way a group default does
+func preferProvider(name string) router.Middleware {
+	return func(next router.Handler) router.Handler {
+		return func(ctx *router.Context) error {
+			if name != "" {
+				ctx.Set(router.ProviderKey, name)
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// newWorkflowEngine creates the engine that runs workflows. Steps without a
+// provider use the current one, and steps can reference prompt templates.
+func newWorkflowEngine(r *router.Router) *workflow.Engine {
+	return &workflow.Engine{
+		Router: r,
+		Provider: func(name string) (workflow.Completer, config.CompletionOptions, error) {
+			if name == "" {
+				selection, err := selectFor("general", providers.Requirements{})
+				if err != nil {
+					return nil, config.CompletionOptions{}, err
+				}
+				return wrapProvider("general", selection.Provider), config.CompletionOptions{Model: selection.Model.ID}, nil
+			}
+
+			provider := providerRegistry[normalizeProviderName(name)]
+			if provider == nil {
+				return nil, config.CompletionOptions{}, fmt.Errorf("provider '%s' not available", name)
+			}
+			return wrapProvider("general", provider), config.CompletionOptions{Model: getModelForProvider(provider)}, nil
+		},
+	}
+}
+
+// loadWorkflows loads the workflow definitions in WORKFLOWS_DIR, if set
+func loadWorkflows() map[string]*workflow.Workflow {
+	dir := os.Getenv("WORKFLOWS_DIR")
+	if dir == "" {
+		return map[string]*workflow.Workflow{}
+	}
+
+	workflows, err := workflow.LoadDir(dir)
+	if err != nil {
+		log.Printf("❌ Loading workflows failed: %v", err)
+		return map[string]*workflow.Workflow{}
+	}
+
+	log.Printf("🔀 Loaded %d workflows from %s", len(workflows), dir)
+	return workflows
+}
+
+// envFloat parses a float environment variable, returning 0 (the default
+// for thresholds) if it is unset or invalid
+func envFloat(name string) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("⚠️  Invalid %s '%s', using default", name, value)
+		return 0
+	}
+	return parsed
+}
+
 // setupProviderSwitchingRoutes creates routes for switching between providers
 func setupProviderSwitchingRoutes(r *router.Router) {
+	g := r.Group("providers", router.WithDescription("🔄 Provider Management"))
+
 	// Switch to a specific provider
-	r.Handle("use *", func(ctx *router.Context) error {
-		providerName := normalizeProviderName(ctx.Captures[0])
+	g.Handle("use *", func(ctx *router.Context) error {
+		providerName, model := parseModelRef(ctx.Captures[0])
 
-		if _, exists := providerRegistry[providerName]; exists {
-			currentProvider = providerName
-			ctx.Response = fmt.Sprintf("✅ Switched to %s provider. All subsequent commands will use %s.", providerName, providerName)
-		} else {
+		if _, exists := providerRegistry[providerName]; !exists {
 			availableProviders := getProviderList()
 			ctx.Response = fmt.Sprintf("❌ Provider '%s' not available. Available providers: %s",
-				ctx.Captures[0], strings.Join(availableProviders, ", "))
+				strings.SplitN(ctx.Captures[0], "/", 2)[0], strings.Join(availableProviders, ", "))
+			return nil
 		}
+
+		warning := ""
+		if model != "" {
+			lookup, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, err := modelCatalog.Find(lookup, providerName, model); errors.Is(err, providers.ErrModelNotFound) {
+				ctx.Response = fmt.Sprintf("❌ %s does not serve '%s'. Type 'list models %s' to see its models.",
+					providerName, model, strings.ToLower(providerName))
+				return nil
+			} else if err != nil {
+				warning = fmt.Sprintf("\n⚠️  Could not check the model: %v", err)
+			}
+		}
+
+		currentProvider = providerName
+		selectModel(providerName, model)
+		ctx.Response = fmt.Sprintf("✅ Switched to %s provider with model %s. All subsequent commands will use it.%s",
+			providerName, getModelForProvider(providerRegistry[providerName]), warning)
 		return nil
-	})
+	}, router.WithMeta(router.Meta{
+		Description: "Switch to a specific provider, and optionally one of its models, for all subsequent commands",
+		Usage:       "use [provider] or use [provider]/[model]",
+		Examples:    []string{"use deepseek", "use openai", "use openai/gpt-4o", "use ollama/llama3"},
+	}))
 
 	// Switch to auto mode (best provider for each task)
-	r.Handle("use auto", func(ctx *router.Context) error {
+	g.Handle("use auto", func(ctx *router.Context) error {
 		currentProvider = ""
 		ctx.Response = "✅ Switched to auto mode. The system will automatically choose the best provider for each task."
 		return nil
-	})
+	}, router.WithMeta(router.Meta{
+		Description: "Let the system pick the best provider for each task",
+		Examples:    []string{"use auto"},
+	}))
 
 	// Show current provider
-	r.Handle("current provider", func(ctx *router.Context) error {
+	g.Handle("current provider", func(ctx *router.Context) error {
 		if currentProvider == "" {
 			ctx.Response = "🤖 Currently in auto mode - the system chooses the best provider for each task."
 		} else {
-			ctx.Response = fmt.Sprintf("🎯 Currently using: %s", currentProvider)
+			ctx.Response = fmt.Sprintf("🎯 Currently using: %s (model %s)",
+				currentProvider, getModelForProvider(providerRegistry[currentProvider]))
 		}
 		return nil
-	})
+	}, router.WithMeta(router.Meta{
+		Description: "Show the currently selected provider and model",
+		Examples:    []string{"current provider"},
+	}))
 
 	// List all available providers
-	r.Handle("list providers", func(ctx *router.Context) error {
+	g.Handle("list providers", func(ctx *router.Context) error {
 		providers := getProviderList()
 		if len(providers) == 0 {
 			ctx.Response = "❌ No providers configured."
@@ -332,17 +665,100 @@ func setupProviderSwitchingRoutes(r *router.Router) {
 
 		response += "\n💡 Commands:\n"
 		response += "• 'use [provider]' - Switch to specific provider\n"
+		response += "• 'use [provider]/[model]' - Switch to a specific model\n"
+		response += "• 'list models' - Show the models of every provider\n"
+		response += "• 'select provider for [requirements]' - Show what auto mode picks\n"
 		response += "• 'use auto' - Auto-select best provider for each task\n"
 		response += "• 'current provider' - Show current provider\n"
 		response += "• 'list providers' - Show this list\n"
 
 		ctx.Response = response
 		return nil
-	})
+	}, router.WithMeta(router.Meta{
+		Description: "List all configured providers",
+		Examples:    []string{"list providers"},
+	}))
+
+	// List the models of every provider, or of one
+	g.Handle("list models", func(ctx *router.Context) error {
+		ctx.Response = describeModels(getProviderList())
+		return nil
+	}, router.WithMeta(router.Meta{
+		Description: "List the models of every configured provider with their capabilities",
+		Examples:    []string{"list models"},
+	}))
 
-	// Provider-specific commands (force use of specific provider)
-	r.Handle("with * *", func(ctx *router.Context) error {
+	g.Handle("list models *", func(ctx *router.Context) error {
 		providerName := normalizeProviderName(ctx.Captures[0])
+		if _, exists := providerRegistry[providerName]; !exists {
+			ctx.Response = fmt.Sprintf("❌ Provider '%s' not available. Available providers: %s",
+				ctx.Captures[0], strings.Join(getProviderList(), ", "))
+			return nil
+		}
+		ctx.Response = describeModels([]string{providerName})
+		return nil
+	}, router.WithMeta(router.Meta{
+		Description: "List the models of one provider with their capabilities",
+		Usage:       "list models [provider]",
+		Examples:    []string{"list models openai", "list models ollama"},
+	}))
+
+	// Explain which provider and model auto mode picks for requirements
+	g.Handle("select provider for *", func(ctx *router.Context) error {
+		requirements, err := parseRequirements(ctx.Captures[0])
+		if err != nil {
+			return err
+		}
+
+		lookup, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		selection, err := modelSelector.Select(lookup, requirements)
+
+		var selectionErr *providers.SelectionError
+		switch {
+		case errors.As(err, &selectionErr):
+			ctx.Response = "❌ No provider satisfies these requirements." + describeRejections(selectionErr.Rejected)
+		case err != nil:
+			return err
+		default:
+			ctx.Response = fmt.Sprintf("🎯 %s/%s%s", selection.Provider.GetName(), selection.Model.ID, describeCapabilities(selection.Model)) +
+				describeRejections(selection.Rejected)
+		}
+		return nil
+	}, router.WithMeta(router.Meta{
+		Description: "Show the provider and model auto mode picks for requirements, and why others were rejected",
+		Usage:       "select provider for [streaming, tools, json, vision, local, context N, max cost N]",
+		Examples:    []string{"select provider for tools, json", "select provider for local, context 8k", "select provider for streaming, max cost 0.001"},
+	}))
+
+	// Count the tokens of text for the model auto mode or the current
+	// provider would use
+	g.Handle("count tokens *", func(ctx *router.Context) error {
+		selection, err := selectFor("general", providers.Requirements{})
+		if err != nil {
+			return router.WithCode(router.CodeUnavailable, err)
+		}
+
+		tokenizer := tokens.For(selection.Model.ID)
+		count := tokenizer.Count(ctx.Captures[0])
+		kind := "exact"
+		if !tokenizer.Exact() {
+			kind = "estimated"
+		}
+		ctx.Response = fmt.Sprintf("🔢 %d tokens (%s) for %s/%s", count, kind, selection.Provider.GetName(), selection.Model.ID)
+		if window := selection.Model.ContextWindow; window > 0 {
+			ctx.Response += fmt.Sprintf(", %.1f%% of its %d token context window", 100*float64(count)/float64(window), window)
+		}
+		return nil
+	}, router.WithMeta(router.Meta{
+		Description: "Count the tokens of text for the model in use",
+		Usage:       "count tokens [text]",
+		Examples:    []string{"count tokens the quick brown fox"},
+	}))
+
+	// Provider-specific commands (force use of specific provider)
+	g.Handle("with * *", func(ctx *router.Context) error {
+		providerName, model := parseModelRef(ctx.Captures[0])
 		command := ctx.Captures[1]
 
 		provider, exists := providerRegistry[providerName]
@@ -354,8 +770,11 @@ func setupProviderSwitchingRoutes(r *router.Router) {
 		}
 
 		// Execute the command with the specified provider
+		if model == "" {
+			model = getModelForProvider(provider)
+		}
 		response, err := provider.Complete(command, config.CompletionOptions{
-			Model: getModelForProvider(provider),
+			Model: model,
 		})
 		if err != nil {
 			return err
@@ -363,7 +782,11 @@ func setupProviderSwitchingRoutes(r *router.Router) {
 
 		ctx.Response = fmt.Sprintf("[Using %s]\n\n%s", providerName, response)
 		return nil
-	})
+	}, router.WithMeta(router.Meta{
+		Description: "Run a single command with a specific provider",
+		Usage:       "with [provider] [command] or with [provider]/[model] [command]",
+		Examples:    []string{"with openai explain quantum computing", "with openai/gpt-4o explain quantum computing"},
+	}))
 }
 
 // normalizeProviderName converts provider names to the correct case
@@ -384,248 +807,942 @@ func normalizeProviderName(name string) string {
 	}
 }
 
+// parseModelRef splits a "provider/model" reference into the provider's
+// registry name and the model, which is empty if the reference names only
+// a provider
+func parseModelRef(ref string) (string, string) {
+	provider, model, _ := strings.Cut(strings.TrimSpace(ref), "/")
+	return normalizeProviderName(provider), strings.TrimSpace(model)
+}
+
+// selectModel sets the model used for a provider. An empty model restores
+// the provider's default.
+func selectModel(provider, model string) {
+	selectedModels.Lock()
+	defer selectedModels.Unlock()
+
+	if model == "" {
+		delete(selectedModels.byProvider, provider)
+		return
+	}
+	selectedModels.byProvider[provider] = model
+}
+
+// describeModels lists the cataloged models of the providers for display
+func describeModels(names []string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	response := "📚 Available models:\n"
+	for _, name := range names {
+		response += fmt.Sprintf("\n%s:\n", name)
+		models, err := modelCatalog.Models(ctx, name)
+		if err != nil {
+			response += fmt.Sprintf("   ⚠️  %v\n", err)
+			continue
+		}
+		if len(models) == 0 {
+			response += "   Any model name can be used, with 'use " + strings.ToLower(name) + "/[model]'\n"
+			continue
+		}
+
+		current := getModelForProvider(providerRegistry[name])
+		for _, model := range models {
+			marker := "  "
+			if model.ID == current {
+				marker = "🎯"
+			}
+			response += fmt.Sprintf("%s %s%s\n", marker, model.ID, describeCapabilities(model))
+		}
+	}
+
+	response += "\n💡 Type 'use [provider]/[model]' to switch models."
+	return response
+}
+
+// parseRequirements parses requirements such as "tools, json, context 8k,
+// max cost 0.001", separated by commas or "and"
+func parseRequirements(text string) (providers.Requirements, error) {
+	var requirements providers.Requirements
+	text = strings.ReplaceAll(strings.ToLower(text), " and ", ",")
+	for _, clause := range strings.Split(text, ",") {
+		words := strings.Fields(clause)
+		if len(words) == 0 {
+			continue
+		}
+
+		switch words[0] {
+		case "streaming", "stream":
+			requirements.Streaming = true
+		case "tools", "tool", "functions":
+			requirements.Tools = true
+		case "json":
+			requirements.JSONMode = true
+		case "vision", "images":
+			requirements.Vision = true
+		case "local":
+			requirements.LocalOnly = true
+		case "context", "min":
+			tokens, err := parseTokenCount(words[len(words)-1])
+			if err != nil {
+				return requirements, router.Errorf(router.CodeInvalidInput, "invalid context length in %q", strings.TrimSpace(clause))
+			}
+			requirements.MinContext = tokens
+		case "cost", "max":
+			cost, err := strconv.ParseFloat(strings.TrimPrefix(words[len(words)-1], "$"), 64)
+			if err != nil || cost <= 0 {
+				return requirements, router.Errorf(router.CodeInvalidInput, "invalid cost in %q", strings.TrimSpace(clause))
+			}
+			requirements.MaxCostPer1K = cost
+		default:
+			return requirements, router.Errorf(router.CodeInvalidInput, "unknown requirement %q", strings.TrimSpace(clause))
+		}
+	}
+	return requirements, nil
+}
+
+// parseTokenCount parses a token count such as "8000" or "32k"
+func parseTokenCount(s string) (int, error) {
+	multiplier := 1
+	if strings.HasSuffix(s, "k") {
+		multiplier = 1000
+		s = strings.TrimSuffix(s, "k")
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid token count %q", s)
+	}
+	return n * multiplier, nil
+}
+
+// describeRejections lists why candidates were rejected for display
+func describeRejections(rejected []providers.Rejection) string {
+	if len(rejected) == 0 {
+		return ""
+	}
+
+	response := "\n\nRejected:\n"
+	for i, rejection := range rejected {
+		if i == 10 {
+			response += fmt.Sprintf("• ...and %d more\n", len(rejected)-i)
+			break
+		}
+		response += "• " + rejection.String() + "\n"
+	}
+	return response
+}
+
+// describeCapabilities formats a model's context window and capabilities
+func describeCapabilities(model providers.Model) string {
+	var details []string
+	if model.ContextWindow > 0 {
+		details = append(details, fmt.Sprintf("%dK context", model.ContextWindow/1000))
+	}
+	c := model.Capabilities
+	for _, capability := range []struct {
+		name string
+		has  bool
+	}{
+		{"streaming", c.Streaming},
+		{"tools", c.Tools},
+		{"JSON mode", c.JSONMode},
+		{"vision", c.Vision},
+		{"embeddings", c.Embeddings},
+	} {
+		if capability.has {
+			details = append(details, capability.name)
+		}
+	}
+	switch {
+	case model.Local:
+		details = append(details, "local")
+	case model.CostPer1K > 0:
+		details = append(details, fmt.Sprintf("$%g/1K", model.CostPer1K))
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(details, ", ") + ")"
+}
+
+// providerKey and modelKey are the context keys under which
+// selectProvider stores the provider and model chosen for a request
+const (
+	providerKey = "provider"
+	modelKey    = "model"
+)
+
+// requirementsKey is the context key under which a group can store, with
+// router.WithDefault, the providers.Requirements its routes add to those
+// of their task type
+const requirementsKey = "requirements"
+
+// selectProvider picks the current provider, or the best provider and
+// model for the task type, and stores them on the context for the handler
+func selectProvider(taskType string) router.Middleware {
+	return func(next router.Handler) router.Handler {
+		return func(ctx *router.Context) error {
+			if len(providerRegistry) == 0 {
+				return router.Errorf(router.CodeUnavailable, "no AI providers available")
+			}
+
+			// In auto mode, a group's default provider is tried before the
+			// task type's preferred providers
+			extra, _ := ctx.Get(requirementsKey).(providers.Requirements)
+			if name, ok := ctx.Get(router.ProviderKey).(string); ok {
+				extra.Prefer = append([]string{normalizeProviderName(name)}, extra.Prefer...)
+			}
+
+			selection, err := selectFor(taskType, extra)
+			if err != nil {
+				return router.WithCode(router.CodeUnavailable, err)
+			}
+			ctx.Set(providerKey, wrapProvider(taskType, selection.Provider))
+			ctx.Set(modelKey, selection.Model.ID)
+			ctx.Provider = selection.Provider.GetName()
+			return next(ctx)
+		}
+	}
+}
+
+// wrapProvider returns a provider that renders prompt templates and fits
+// the prompts of a task type into the model's context window
+func wrapProvider(taskType string, provider providers.Provider) providers.Provider {
+	return contextGuard(taskType, provider.GetName()).Wrap(promptLibrary.Wrap(provider))
+}
+
+// contextGuard returns the guard that checks the prompts of a task type
+// against the context window of a provider's models
+func contextGuard(taskType, provider string) *tokens.Guard {
+	guard := &tokens.Guard{
+		Strategy: contextStrategy,
+		Window: func(model string) int {
+			return modelWindow(provider, model)
+		},
+		Render: promptLibrary.Apply,
+	}
+	if strategy, ok := contextStrategies[taskType]; ok {
+		guard.Strategy = strategy
+	}
+	return guard
+}
+
+// modelWindow returns the context window of a provider's model, or 0 if it
+// is unknown
+func modelWindow(provider, model string) int {
+	lookup, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	found, err := modelCatalog.Find(lookup, provider, model)
+	if err != nil {
+		return 0
+	}
+	return found.ContextWindow
+}
+
+// summarizerFor returns a summarizer using the named provider, optionally
+// as "provider/model", or the one selected for summaries if the name is
+// empty or "auto"
+func summarizerFor(name string) (*summarize.Summarizer, error) {
+	if name == "" || strings.EqualFold(name, "auto") {
+		selection, err := selectFor("summary", providers.Requirements{})
+		if err != nil {
+			return nil, err
+		}
+		return newSummarizer(wrapProvider("summary", selection.Provider), selection.Model.ID), nil
+	}
+
+	providerName, model := parseModelRef(name)
+	provider, exists := providerRegistry[providerName]
+	if !exists {
+		return nil, fmt.Errorf("provider '%s' not available", name)
+	}
+	if model == "" {
+		model = getModelForProvider(provider)
+	}
+	return newSummarizer(wrapProvider("summary", provider), model), nil
+}
+
+// newSummarizer returns a summarizer for a provider's model that logs the
+// progress of documents too long for one request. The provider must render
+// prompt templates.
+func newSummarizer(provider providers.Provider, model string) *summarize.Summarizer {
+	return &summarize.Summarizer{
+		Provider:    provider,
+		Options:     config.CompletionOptions{Model: model},
+		Window:      modelWindow(provider.GetName(), model),
+		Concurrency: summaryConcurrency(),
+		Progress: func(progress summarize.Progress) {
+			if progress.Total > 1 || progress.Level > 0 {
+				log.Printf("📝 %s", describeProgress(progress))
+			}
+		},
+	}
+}
+
+// summaryConcurrency returns the number of summaries requested at once,
+// set with SUMMARY_CONCURRENCY
+func summaryConcurrency() int {
+	n, err := strconv.Atoi(os.Getenv("SUMMARY_CONCURRENCY"))
+	if err != nil || n <= 0 {
+		return summarize.DefaultConcurrency
+	}
+	return n
+}
+
+// describeProgress describes the progress of a summarization
+func describeProgress(progress summarize.Progress) string {
+	if progress.Stage == summarize.StageCombine {
+		return fmt.Sprintf("Combining summaries, round %d: %d/%d", progress.Level, progress.Done, progress.Total)
+	}
+	return fmt.Sprintf("Summarizing chunks: %d/%d", progress.Done, progress.Total)
+}
+
+// summarizeCommand summarizes the file named by the arguments, or standard
+// input if there is none or it is "-". Progress goes to standard error and
+// the summary to standard output. It returns the exit code.
+func summarizeCommand(args []string) int {
+	flags := flag.NewFlagSet("summarize", flag.ContinueOnError)
+	providerName := flags.String("provider", "auto", "provider to use, optionally as provider/model")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var text []byte
+	var err error
+	if path := flags.Arg(0); path == "" || path == "-" {
+		text, err = io.ReadAll(os.Stdin)
+	} else {
+		text, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+
+	summarizer, err := summarizerFor(*providerName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	summarizer.Progress = func(progress summarize.Progress) {
+		fmt.Fprintf(os.Stderr, "📝 %s\n", describeProgress(progress))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	result, err := summarizer.Summarize(ctx, string(text))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "✅ %s/%s summarized %d tokens in %d chunks and %d requests\n",
+		result.Provider, result.Model, result.Tokens, result.Chunks, result.Calls)
+	fmt.Println(result.Summary)
+	return 0
+}
+
+// setupContextStrategy reads CONTEXT_STRATEGY, the strategy for prompts
+// too long for the model of task types without their own
+func setupContextStrategy() {
+	value := os.Getenv("CONTEXT_STRATEGY")
+	if value == "" {
+		return
+	}
+	strategy, err := tokens.ParseStrategy(value)
+	if err != nil {
+		log.Printf("⚠️  %v, using %s", err, contextStrategy)
+		return
+	}
+	contextStrategy = strategy
+	log.Printf("📏 Prompts too long for the model are handled with %s", strategy)
+}
+
+// requestModel returns the model selectProvider chose for the request
+func requestModel(ctx *router.Context) string {
+	model, _ := ctx.Get(modelKey).(string)
+	return model
+}
+
+// providerInfo prefixes the response with the provider and model that
+// produced it
+func providerInfo(ctx *router.Context, response string) string {
+	provider := ctx.Get(providerKey).(providers.Provider)
+	if currentProvider == "" {
+		return fmt.Sprintf("[Auto-selected: %s/%s]\n\n", provider.GetName(), requestModel(ctx)) + response
+	}
+	return fmt.Sprintf("[Using: %s/%s]\n\n", provider.GetName(), requestModel(ctx)) + response
+}
+
+// providerRoute marks a route as backed by an AI provider chosen for the
+// task type, and labels its response with that provider
+func providerRoute(taskType string) router.RouteOption {
+	return router.WithMiddleware(router.Decorate(providerInfo), selectProvider(taskType))
+}
+
+// setupPrompts loads the built-in prompt templates, then any templates in
+// PROMPTS_DIR, which override built-in templates of the same name and
+// version or add newer versions
+func setupPrompts() {
+	library, err := prompts.Default()
+	if err != nil {
+		log.Fatalf("❌ Loading built-in prompt templates failed: %v", err)
+	}
+	promptLibrary = library
+
+	dir := os.Getenv("PROMPTS_DIR")
+	if dir == "" {
+		return
+	}
+	if err := promptLibrary.LoadDir(dir); err != nil {
+		log.Printf("❌ Loading prompt templates from %s failed: %v", dir, err)
+		return
+	}
+	log.Printf("📝 Loaded prompt templates from %s", dir)
+}
+
 // setupUniversalRoutes creates routes that work with any available provider
 func setupUniversalRoutes(r *router.Router) {
+	g := r.Group("universal", router.WithDescription("🌟 Universal Commands (work with any provider)"))
+
 	// Translation route - works with any provider
-	r.Handle("translate * to *", func(ctx *router.Context) error {
+	g.Handle("translate * to *", func(ctx *router.Context) error {
 		text := ctx.Captures[0]
 		language := ctx.Captures[1]
+		provider := ctx.Get(providerKey).(providers.Provider)
 
-		provider := getCurrentProvider("translation")
-		if provider == nil {
-			return fmt.Errorf("no AI providers available")
-		}
-
-		prompt := fmt.Sprintf("Translate the following text to %s: %s", language, text)
-		response, err := provider.Complete(prompt, config.CompletionOptions{
-			Model:        getModelForProvider(provider),
-			SystemPrompt: "You are a professional translator. Provide accurate translations.",
+		response, err := provider.Complete(text, config.CompletionOptions{
+			Model:     requestModel(ctx),
+			Template:  "translate",
+			Variables: map[string]interface{}{"text": text, "language": language},
 		})
 		if err != nil {
 			return err
 		}
 
-		providerInfo := ""
-		if currentProvider == "" {
-			providerInfo = fmt.Sprintf("[Auto-selected: %s]\n\n", provider.GetName())
-		} else {
-			providerInfo = fmt.Sprintf("[Using: %s]\n\n", provider.GetName())
-		}
-
-		ctx.Response = providerInfo + response
+		ctx.Response = response
 		return nil
-	})
+	}, providerRoute("translation"), router.WithMeta(router.Meta{
+		Description: "Translate text into another language",
+		Usage:       "translate [text] to [language]",
+		Examples:    []string{"translate hello world to Spanish"},
+	}))
 
 	// Summarization route - works with any provider
-	r.Handle("summarize *", func(ctx *router.Context) error {
-		provider := getCurrentProvider("summary")
-		if provider == nil {
-			return fmt.Errorf("no AI providers available")
-		}
+	g.Handle("summarize *", func(ctx *router.Context) error {
+		provider := ctx.Get(providerKey).(providers.Provider)
 
-		response, err := provider.Complete(ctx.Captures[0], config.CompletionOptions{
-			Model:        getModelForProvider(provider),
-			SystemPrompt: "You are a summarization expert. Provide concise, clear summaries.",
-		})
+		// Long texts are summarized in chunks, then the summaries combined
+		result, err := newSummarizer(provider, requestModel(ctx)).Summarize(ctx.Context(), ctx.Captures[0])
 		if err != nil {
 			return err
 		}
 
-		providerInfo := ""
-		if currentProvider == "" {
-			providerInfo = fmt.Sprintf("[Auto-selected: %s]\n\n", provider.GetName())
-		} else {
-			providerInfo = fmt.Sprintf("[Using: %s]\n\n", provider.GetName())
-		}
-
-		ctx.Response = providerInfo + response
+		ctx.Response = result.Summary
 		return nil
-	})
+	}, providerRoute("summary"), router.WithMeta(router.Meta{
+		Description: "Summarize a piece of text of any length",
+		Usage:       "summarize [text]",
+		Examples:    []string{"summarize the benefits of renewable energy"},
+	}))
 
 	// Reasoning route - works with any provider
-	r.Handle("think about *", func(ctx *router.Context) error {
-		provider := getCurrentProvider("reasoning")
-		if provider == nil {
-			return fmt.Errorf("no AI providers available")
-		}
+	g.Handle("think about *", func(ctx *router.Context) error {
+		provider := ctx.Get(providerKey).(providers.Provider)
 
 		response, err := provider.Complete(ctx.Captures[0], config.CompletionOptions{
-			Model:        getModelForProvider(provider),
-			SystemPrompt: "You are a deep thinking AI. Provide thoughtful, analytical responses.",
+			Model:    requestModel(ctx),
+			Template: "think",
 		})
 		if err != nil {
 			return err
 		}
 
-		providerInfo := ""
-		if currentProvider == "" {
-			providerInfo = fmt.Sprintf("[Auto-selected: %s]\n\n", provider.GetName())
-		} else {
-			providerInfo = fmt.Sprintf("[Using: %s]\n\n", provider.GetName())
-		}
-
-		ctx.Response = providerInfo + response
+		ctx.Response = response
 		return nil
-	})
+	}, providerRoute("reasoning"), router.WithMeta(router.Meta{
+		Description: "Give a thoughtful, in-depth analysis of a topic",
+		Usage:       "think about [topic]",
+		Examples:    []string{"think about artificial intelligence"},
+	}))
 
 	// Step-by-step reasoning
-	r.Handle("reason through *", func(ctx *router.Context) error {
-		provider := getCurrentProvider("reasoning")
-		if provider == nil {
-			return fmt.Errorf("no AI providers available")
-		}
+	g.Handle("reason through *", func(ctx *router.Context) error {
+		provider := ctx.Get(providerKey).(providers.Provider)
 
-		prompt := fmt.Sprintf("Please reason through this step by step: %s", ctx.Captures[0])
-		response, err := provider.Complete(prompt, config.CompletionOptions{
-			Model:        getModelForProvider(provider),
-			SystemPrompt: "You are an expert at logical reasoning. Break down problems step by step.",
+		response, err := provider.Complete(ctx.Captures[0], config.CompletionOptions{
+			Model:    requestModel(ctx),
+			Template: "reason",
 		})
 		if err != nil {
 			return err
 		}
 
-		providerInfo := ""
-		if currentProvider == "" {
-			providerInfo = fmt.Sprintf("[Auto-selected: %s]\n\n", provider.GetName())
-		} else {
-			providerInfo = fmt.Sprintf("[Using: %s]\n\n", provider.GetName())
-		}
-
-		ctx.Response = providerInfo + response
+		ctx.Response = response
 		return nil
-	})
+	}, providerRoute("reasoning"), router.WithMeta(router.Meta{
+		Description: "Work through a problem step by step",
+		Usage:       "reason through [problem]",
+		Examples:    []string{"reason through the Monty Hall problem"},
+	}))
 
 	// Code generation route
-	r.Handle("generate code for *", func(ctx *router.Context) error {
-		provider := getCurrentProvider("coding")
-		if provider == nil {
-			return fmt.Errorf("no AI providers available")
-		}
+	g.Handle("generate code for *", func(ctx *router.Context) error {
+		provider := ctx.Get(providerKey).(providers.Provider)
 
-		prompt := fmt.Sprintf("Write clean, well-documented code for: %s", ctx.Captures[0])
-		response, err := provider.Complete(prompt, config.CompletionOptions{
-			Model:        getModelForProvider(provider),
-			SystemPrompt: "You are an expert programmer. Write clean, efficient, and well-documented code.",
+		response, err := provider.Complete(ctx.Captures[0], config.CompletionOptions{
+			Model:    requestModel(ctx),
+			Template: "code",
 		})
 		if err != nil {
 			return err
 		}
 
-		providerInfo := ""
-		if currentProvider == "" {
-			providerInfo = fmt.Sprintf("[Auto-selected: %s]\n\n", provider.GetName())
-		} else {
-			providerInfo = fmt.Sprintf("[Using: %s]\n\n", provider.GetName())
-		}
-
-		ctx.Response = providerInfo + response
+		ctx.Response = response
 		return nil
-	})
+	}, providerRoute("coding"), router.WithMeta(router.Meta{
+		Description: "Generate well-documented code for a task",
+		Usage:       "generate code for [task]",
+		Examples:    []string{"generate code for a simple calculator"},
+	}))
 
 	// General chat route
-	r.Handle("chat *", func(ctx *router.Context) error {
-		provider := getCurrentProvider("general")
-		if provider == nil {
-			return fmt.Errorf("no AI providers available")
+	g.Handle("chat *", func(ctx *router.Context) error {
+		provider := ctx.Get(providerKey).(providers.Provider)
+
+		response, err := provider.Complete(ctx.Captures[0], config.CompletionOptions{
+			Model: requestModel(ctx),
+		})
+		if err != nil {
+			return err
 		}
 
+		ctx.Response = response
+		return nil
+	}, providerRoute("general"), router.WithMeta(router.Meta{
+		Description: "Have a general conversation",
+		Usage:       "chat [message]",
+		Examples:    []string{"chat what's a good name for a cat?"},
+	}))
+
+	// Sentiment analysis
+	g.Handle("analyze sentiment of *", func(ctx *router.Context) error {
+		provider := ctx.Get(providerKey).(providers.Provider)
+
 		response, err := provider.Complete(ctx.Captures[0], config.CompletionOptions{
-			Model: getModelForProvider(provider),
+			Model:    requestModel(ctx),
+			Template: "sentiment",
 		})
 		if err != nil {
 			return err
 		}
 
-		providerInfo := ""
-		if currentProvider == "" {
-			providerInfo = fmt.Sprintf("[Auto-selected: %s]\n\n", provider.GetName())
+		ctx.Response = response
+		return nil
+	}, providerRoute("general"), router.WithMeta(router.Meta{
+		Description: "Analyze the sentiment of a piece of text",
+		Usage:       "analyze sentiment of [text]",
+		Examples:    []string{"analyze sentiment of I love this product"},
+	}))
+}
+
+// ollamaProvider returns the configured Ollama provider, or nil
+func ollamaProvider() *providers.Ollama {
+	ollama, _ := providerRegistry["Ollama"].(*providers.Ollama)
+	return ollama
+}
+
+// setupDocuments opens the document collections in RAG_DIR when
+// RAG_EMBEDDING_PROVIDER names a configured provider that supports
+// embeddings. Documents are read from RAG_ROOT, the working directory by
+// default, and collections are refreshed every RAG_WATCH_INTERVAL if set.
+func setupDocuments() {
+	name := os.Getenv("RAG_EMBEDDING_PROVIDER")
+	if name == "" {
+		return
+	}
+
+	provider, exists := providerRegistry[normalizeProviderName(name)]
+	if !exists {
+		log.Printf("⚠️  Document embedding provider '%s' not available", name)
+		return
+	}
+	embedder, ok := provider.(providers.Embedder)
+	if !ok {
+		log.Printf("⚠️  Provider %s does not support embeddings", provider.GetName())
+		return
+	}
+
+	dir := os.Getenv("RAG_DIR")
+	if dir == "" {
+		dir = "data/collections"
+	}
+	root := os.Getenv("RAG_ROOT")
+	if root == "" {
+		root = "."
+	}
+	chunkTokens, _ := strconv.Atoi(os.Getenv("RAG_CHUNK_TOKENS"))
+
+	options := rag.Options{
+		Embedder:    embedder,
+		Provider:    provider.GetName(),
+		Model:       os.Getenv("RAG_EMBEDDING_MODEL"),
+		ChunkTokens: chunkTokens,
+		Root:        root,
+	}
+	storeURL := os.Getenv("RAG_VECTOR_STORE")
+	if storeURL != "" {
+		options.Store = vectorStores(storeURL)
+	}
+
+	manager, err := rag.NewManager(dir, options)
+	if err != nil {
+		log.Printf("❌ Opening document collections in %s failed: %v", dir, err)
+		return
+	}
+	documents = manager
+	log.Printf("📚 Document collections in %s, embedded with %s", dir, provider.GetName())
+	if storeURL != "" {
+		log.Printf("🗄️  Document vectors stored in %s", storeURL)
+	}
+
+	if value := os.Getenv("RAG_WATCH_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			log.Printf("⚠️  Invalid RAG_WATCH_INTERVAL '%s', not watching documents", value)
 		} else {
-			providerInfo = fmt.Sprintf("[Using: %s]\n\n", provider.GetName())
+			go manager.Watch(context.Background(), interval)
+			log.Printf("👀 Refreshing document collections every %s", interval)
+		}
+	}
+}
+
+// vectorStores opens the vector stores of collections from a URL, see
+// vector.Open, in which "{collection}" is replaced by the collection name.
+// Collections whose URLs are the same share one store, until it is closed
+// with the last of them, except that memory: gives each collection its own
+// index. A file: URL without "{collection}" holds a single collection: an
+// HNSW graph shared by several would lose recall on filtered queries.
+func vectorStores(rawURL string) func(collection string) (vector.Store, error) {
+	scheme := strings.ToLower(strings.SplitN(rawURL, ":", 2)[0])
+	shared := !strings.Contains(rawURL, "{collection}")
+
+	var mu sync.Mutex
+	stores := make(map[string]vector.Store)
+	owners := make(map[string]string)
+	return func(collection string) (vector.Store, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		u := strings.ReplaceAll(rawURL, "{collection}", collection)
+		key := u
+		if shared && scheme == "memory" {
+			key = u + " " + collection
+		}
+		if store, exists := stores[key]; exists {
+			if _, err := store.Count(context.Background(), nil); !errors.Is(err, vector.ErrClosed) {
+				if shared && scheme == "file" && owners[key] != collection {
+					return nil, fmt.Errorf("%s has no {collection}, so it only holds one collection and %s uses it", rawURL, owners[key])
+				}
+				return store, nil
+			}
+		}
+		store, err := vector.Open(u)
+		if err != nil {
+			return nil, err
+		}
+		stores[key], owners[key] = store, collection
+		return store, nil
+	}
+}
+
+// defaultCollection returns the collection "ask docs" and "ingest" use,
+// set with RAG_COLLECTION
+func defaultCollection() string {
+	if name := os.Getenv("RAG_COLLECTION"); name != "" {
+		return name
+	}
+	return "docs"
+}
+
+// askDocuments answers a question from a collection with the provider
+// selected for document questions
+func askDocuments(ctx context.Context, collection *rag.Collection, question string, k int) (*rag.Answer, error) {
+	selection, err := selectFor("rag", providers.Requirements{})
+	if err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		k, _ = strconv.Atoi(os.Getenv("RAG_TOP_K"))
+	}
+	return collection.Ask(ctx, wrapProvider("rag", selection.Provider), config.CompletionOptions{Model: selection.Model.ID}, question, k)
+}
+
+// setupDocumentRoutes creates commands to ingest documents into
+// collections and ask questions about them
+func setupDocumentRoutes(r *router.Router) {
+	if documents == nil {
+		return
+	}
+
+	g := r.Group("docs", router.WithDescription("📚 Documents"))
+
+	ask := func(ctx *router.Context, name, question string) error {
+		collection, err := documents.Collection(name)
+		if err != nil {
+			return router.WithCode(router.CodeNotFound, err)
 		}
+		k, _ := strconv.Atoi(os.Getenv("RAG_TOP_K"))
+		provider := ctx.Get(providerKey).(providers.Provider)
 
-		ctx.Response = providerInfo + response
+		answer, err := collection.Ask(ctx.Context(), provider, config.CompletionOptions{Model: requestModel(ctx)}, question, k)
+		if errors.Is(err, rag.ErrEmptyCollection) {
+			return router.WithCode(router.CodeInvalidInput, err)
+		}
+		if err != nil {
+			return err
+		}
+		ctx.Response = answer.Text + describeSources(collection, answer.Sources)
 		return nil
-	})
+	}
 
-	// Sentiment analysis
-	r.Handle("analyze sentiment of *", func(ctx *router.Context) error {
-		provider := getCurrentProvider("general")
-		if provider == nil {
-			return fmt.Errorf("no AI providers available")
+	g.Handle("ask docs *", func(ctx *router.Context) error {
+		return ask(ctx, defaultCollection(), ctx.Captures[0])
+	}, providerRoute("rag"), router.WithMeta(router.Meta{
+		Description: "Answer a question from your documents, citing the sources",
+		Usage:       "ask docs [question]",
+		Examples:    []string{"ask docs how do I configure providers?"},
+	}))
+
+	// The collection is named after "in", so questions to the default
+	// collection that mention docs are not mistaken for a collection name
+	g.Handle("ask docs in * about *", func(ctx *router.Context) error {
+		return ask(ctx, strings.ToLower(ctx.Captures[0]), ctx.Captures[1])
+	}, providerRoute("rag"), router.WithMeta(router.Meta{
+		Description: "Answer a question from the documents of a collection",
+		Usage:       "ask docs in [collection] about [question]",
+		Examples:    []string{"ask docs in api about which endpoints stream?"},
+	}))
+
+	ingest := func(ctx *router.Context, name, paths string) error {
+		collection, err := documents.Create(name)
+		if err != nil {
+			return router.WithCode(router.CodeInvalidInput, err)
+		}
+		report, err := collection.Ingest(ctx.Context(), strings.Fields(paths)...)
+		if errors.Is(err, rag.ErrOutsideRoot) || errors.Is(err, os.ErrNotExist) {
+			return router.WithCode(router.CodeInvalidInput, err)
+		}
+		if err != nil {
+			return err
 		}
 
-		prompt := fmt.Sprintf("Analyze the sentiment of this text and explain your reasoning: %s", ctx.Captures[0])
-		response, err := provider.Complete(prompt, config.CompletionOptions{
-			Model:        getModelForProvider(provider),
-			SystemPrompt: "You are a sentiment analysis expert. Analyze text sentiment and provide detailed explanations.",
-		})
+		info := collection.Info()
+		ctx.Response = fmt.Sprintf("📚 %s: %d added, %d updated, %d unchanged, %d removed. %d documents, %d chunks.",
+			info.Name, report.Added, report.Updated, report.Unchanged, report.Removed, info.Files, info.Chunks)
+		for path, reason := range report.Errors {
+			ctx.Response += fmt.Sprintf("\n⚠️  %s: %s", collection.Source(rag.Chunk{Source: path}), reason)
+		}
+		return nil
+	}
+
+	g.Handle("ingest * into *", func(ctx *router.Context) error {
+		return ingest(ctx, strings.ToLower(ctx.Captures[1]), ctx.Captures[0])
+	}, router.WithMeta(router.Meta{
+		Description: "Ingest files or directories into a collection, re-embedding changed documents",
+		Usage:       "ingest [paths] into [collection]",
+		Examples:    []string{"ingest docs README.md into handbook"},
+	}))
+
+	g.Handle("ingest *", func(ctx *router.Context) error {
+		return ingest(ctx, defaultCollection(), ctx.Captures[0])
+	}, router.WithMeta(router.Meta{
+		Description: "Ingest files or directories into the default collection",
+		Usage:       "ingest [paths]",
+		Examples:    []string{"ingest docs", "ingest README.md"},
+	}))
+
+	g.Handle("list collections", func(ctx *router.Context) error {
+		collections := documents.List()
+		if len(collections) == 0 {
+			ctx.Response = "📭 No collections yet. Try 'ingest docs'."
+			return nil
+		}
+
+		response := "📚 Collections:\n\n"
+		for _, info := range collections {
+			response += fmt.Sprintf("• %s: %d documents, %d chunks (%s", info.Name, info.Files, info.Chunks, info.Provider)
+			if info.Model != "" {
+				response += "/" + info.Model
+			}
+			response += ")\n"
+		}
+		ctx.Response = response
+		return nil
+	}, router.WithMeta(router.Meta{
+		Description: "List the document collections",
+		Usage:       "list collections",
+	}))
+}
+
+// describeSources lists the chunks an answer was given from, numbered as
+// the answer cites them
+func describeSources(collection *rag.Collection, sources []rag.Match) string {
+	if len(sources) == 0 {
+		return ""
+	}
+	response := "\n\n📎 Sources:"
+	for i, source := range sources {
+		response += fmt.Sprintf("\n[%d] %s (part %d, score %.2f)", i+1, collection.Source(source.Chunk), source.Index+1, source.Score)
+	}
+	return response
+}
+
+// setupOllamaRoutes creates commands to list, download, inspect and remove
+// local Ollama models
+func setupOllamaRoutes(r *router.Router) {
+	ollama := ollamaProvider()
+	if ollama == nil {
+		return
+	}
+
+	g := r.Group("ollama", router.WithPrefix("ollama"), router.WithDescription("🦙 Ollama Models"))
+
+	g.Handle("models", func(ctx *router.Context) error {
+		models, err := ollama.ListModels(ctx.Context())
 		if err != nil {
 			return err
 		}
+		if len(models) == 0 {
+			ctx.Response = "📭 No models installed. Try 'ollama pull llama3'."
+			return nil
+		}
 
-		providerInfo := ""
-		if currentProvider == "" {
-			providerInfo = fmt.Sprintf("[Auto-selected: %s]\n\n", provider.GetName())
-		} else {
-			providerInfo = fmt.Sprintf("[Using: %s]\n\n", provider.GetName())
+		response := "🦙 Installed models:\n\n"
+		for _, model := range models {
+			response += fmt.Sprintf("• %s (%s", model.Name, formatBytes(model.Size))
+			if model.Details.Family != "" {
+				response += ", " + model.Details.Family
+			}
+			if model.Details.ParameterSize != "" {
+				response += ", " + model.Details.ParameterSize
+			}
+			response += ")\n"
+		}
+		ctx.Response = response
+		return nil
+	}, router.WithMeta(router.Meta{
+		Description: "List the models installed in Ollama with their size and family",
+		Examples:    []string{"ollama models"},
+	}))
+
+	g.Handle("pull *", func(ctx *router.Context) error {
+		model := strings.TrimSpace(ctx.Captures[0])
+		lastStatus := ""
+		err := ollama.PullModel(ctx.Context(), model, func(progress providers.PullProgress) {
+			if progress.Status != lastStatus {
+				log.Printf("🦙 Pulling %s: %s", model, progress.Status)
+				lastStatus = progress.Status
+			}
+		})
+		if err != nil {
+			return err
+		}
+		modelCatalog.Refresh("Ollama")
+		ctx.Response = fmt.Sprintf("✅ Pulled %s", model)
+		return nil
+	}, router.WithMeta(router.Meta{
+		Description: "Download a model from the Ollama library",
+		Usage:       "ollama pull [model]",
+		Examples:    []string{"ollama pull llama3", "ollama pull nomic-embed-text"},
+	}))
+
+	g.Handle("show *", func(ctx *router.Context) error {
+		model := strings.TrimSpace(ctx.Captures[0])
+		info, err := ollama.ShowModel(ctx.Context(), model)
+		if err != nil {
+			return err
 		}
 
-		ctx.Response = providerInfo + response
+		response := fmt.Sprintf("🦙 %s\n\n", model)
+		response += fmt.Sprintf("Family: %s\n", info.Details.Family)
+		response += fmt.Sprintf("Parameters: %s\n", info.Details.ParameterSize)
+		response += fmt.Sprintf("Quantization: %s\n", info.Details.QuantizationLevel)
+		response += fmt.Sprintf("Format: %s\n", info.Details.Format)
+		if len(info.Capabilities) > 0 {
+			response += fmt.Sprintf("Capabilities: %s\n", strings.Join(info.Capabilities, ", "))
+		}
+		if info.Parameters != "" {
+			response += fmt.Sprintf("\nDefault parameters:\n%s\n", info.Parameters)
+		}
+		ctx.Response = response
 		return nil
-	})
+	}, router.WithMeta(router.Meta{
+		Description: "Show the details of an installed model",
+		Usage:       "ollama show [model]",
+		Examples:    []string{"ollama show llama3"},
+	}))
+
+	g.Handle("delete *", func(ctx *router.Context) error {
+		model := strings.TrimSpace(ctx.Captures[0])
+		if err := ollama.DeleteModel(ctx.Context(), model); err != nil {
+			return err
+		}
+		modelCatalog.Refresh("Ollama")
+		ctx.Response = fmt.Sprintf("🗑️  Deleted %s", model)
+		return nil
+	}, router.WithMeta(router.Meta{
+		Description: "Remove an installed model",
+		Usage:       "ollama delete [model]",
+		Examples:    []string{"ollama delete llama2"},
+	}))
+}
+
+// formatBytes formats a size in bytes for display
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
 }
 
-// getModelForProvider returns the appropriate model name for each provider
+// getModelForProvider returns the model chosen for the provider with
+// "use provider/model", or else OLLAMA_MODEL for Ollama, or else the
+// provider's default model from the catalog
 func getModelForProvider(provider providers.Provider) string {
-	switch provider.GetName() {
-	case "OpenAI":
-		return "gpt-3.5-turbo"
-	case "DeepSeek":
-		return "deepseek-chat"
-	case "Gemini":
-		return "gemini-pro"
-	case "Ollama":
-		return "llama2"
-	case "HuggingFace":
-		return "gpt2"
-	default:
-		return ""
+	name := provider.GetName()
+
+	selectedModels.Lock()
+	model := selectedModels.byProvider[name]
+	selectedModels.Unlock()
+	if model != "" {
+		return model
 	}
+
+	if name == "Ollama" {
+		if model := os.Getenv("OLLAMA_MODEL"); model != "" {
+			return model
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return modelCatalog.DefaultModel(ctx, name)
 }
 
 func setupExampleRoutes(r *router.Router) {
-	// Enhanced help command
-	r.Handle("help", func(ctx *router.Context) error {
-		help := `
-🧠 NeuroGO Framework - Available Commands:
-
-📋 General Commands:
-- "help" - Show this help message
-- "status" - Show system status and configured providers
-
-🔄 Provider Management:
-- "list providers" - Show all available providers
-- "use [provider]" - Switch to specific provider (e.g., "use deepseek")
-- "use auto" - Auto-select best provider for each task
-- "current provider" - Show currently selected provider
-- "with [provider] [command]" - Run one command with specific provider
-
-🌟 Universal Commands (work with any provider):
-- "translate [text] to [language]" - Translate text
-- "summarize [text]" - Summarize content
-- "think about [topic]" - Deep analysis
-- "reason through [problem]" - Step-by-step reasoning
-- "generate code for [task]" - Generate code
-- "chat [message]" - General conversation
-- "analyze sentiment of [text]" - Sentiment analysis
-
-💡 Examples:
-- "use deepseek" → "translate hello to Spanish"
-- "with openai explain quantum computing"
-- "list providers"
-- "current provider"
-
-🔧 Your configured providers: `
+	g := r.Group("general", router.WithDescription("📋 General Commands"))
+
+	// Help generated from the live route table
+	g.Handle("help", func(ctx *router.Context) error {
+		help := "\n🧠 NeuroGO Framework - Available Commands:\n\n" + r.Help()
+		help += "\n💡 Type \"help [command]\" for details and examples.\n\n🔧 Your configured providers: "
 
 		var configuredProviders []string
 		for name := range providerRegistry {
@@ -651,10 +1768,28 @@ func setupExampleRoutes(r *router.Router) {
 
 		ctx.Response = help
 		return nil
-	})
+	}, router.WithMeta(router.Meta{
+		Description: "Show this help message",
+		Examples:    []string{"help"},
+	}))
+
+	// Detailed help for a single command
+	g.Handle("help *", func(ctx *router.Context) error {
+		detail, found := r.HelpFor(ctx.Captures[0])
+		if !found {
+			ctx.Response = fmt.Sprintf("❌ No command matches '%s'. Type \"help\" to see all commands.", ctx.Captures[0])
+			return nil
+		}
+		ctx.Response = detail
+		return nil
+	}, router.WithMeta(router.Meta{
+		Description: "Show details and examples for a command",
+		Usage:       "help [command]",
+		Examples:    []string{"help translate", "help use *"},
+	}))
 
 	// Enhanced status command
-	r.Handle("status", func(ctx *router.Context) error {
+	g.Handle("status", func(ctx *router.Context) error {
 		status := map[string]interface{}{
 			"framework": "NeuroGO",
 			"version":   "1.0.0",
@@ -694,7 +1829,10 @@ func setupExampleRoutes(r *router.Router) {
 		jsonData, _ := json.MarshalIndent(status, "", "  ")
 		ctx.Response = string(jsonData)
 		return nil
-	})
+	}, router.WithMeta(router.Meta{
+		Description: "Show system status and configured providers",
+		Examples:    []string{"status"},
+	}))
 }
 
 func setupStaticFiles(r *mux.Router) {
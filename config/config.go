@@ -13,6 +13,14 @@ type CompletionOptions struct {
 	
 	// MaxTokens is the maximum number of tokens to generate
 	MaxTokens int
+	
+	// Template names a prompt template, optionally with a version as
+	// "name@2", that the prompt is rendered from. Providers do not render
+	// templates themselves; wrap them with a prompts.Library.
+	Template string
+	
+	// Variables are the values for the template's variables
+	Variables map[string]interface{}
 }
 
 // LoadConfig loads configuration from environment variables
@@ -0,0 +1,52 @@
+package neurogotest_test
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// fakeT is a testing.TB that records failures instead of reporting them
+type fakeT struct {
+	testing.TB
+
+	mu       sync.Mutex
+	failures []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Fatalf(format string, args ...interface{}) {
+	t.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// failures runs check against a fakeT, as a test would, and returns the
+// failures it reported
+func failures(t *testing.T, check func(t testing.TB)) []string {
+	fake := &fakeT{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		check(fake)
+	}()
+	<-done
+	return fake.failures
+}
+
+// assertFailures checks that check fails exactly n times
+func assertFailures(t *testing.T, n int, check func(t testing.TB)) []string {
+	t.Helper()
+	got := failures(t, check)
+	if len(got) != n {
+		t.Errorf("%d failures reported, want %d: %q", len(got), n, got)
+	}
+	return got
+}
@@ -0,0 +1,260 @@
+package neurogotest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aldotobing/neurogo/config"
+)
+
+// ErrUnexpectedCall is returned by a Provider called after its script has
+// run out
+var ErrUnexpectedCall = errors.New("unexpected provider call")
+
+// Request is a call a Provider received
+type Request struct {
+	Prompt  string
+	Options config.CompletionOptions
+	Stream  bool
+}
+
+// Provider is a scripted providers.Provider for tests. Each call consumes
+// the next step of its script, in order, and is checked against that
+// step's expectations. Calls that break an expectation, or that come after
+// the script ran out, fail with an error and are reported by AssertDone.
+type Provider struct {
+	name string
+
+	mu          sync.Mutex
+	unavailable bool
+	steps       []*Step
+	requests    []Request
+	failures    []string
+}
+
+// NewProvider creates a scripted provider reporting name from GetName
+func NewProvider(name string) *Provider {
+	return &Provider{name: name}
+}
+
+// Step is one scripted provider call: what it expects to receive and how
+// it responds
+type Step struct {
+	prompt   *string
+	contains []string
+	model    *string
+	match    []func(prompt string, options config.CompletionOptions) error
+
+	response string
+	chunks   []string
+	err      error
+	delay    time.Duration
+	times    int
+}
+
+// Expect appends a step to the script. Without further configuration it
+// accepts any call and responds with an empty string.
+func (p *Provider) Expect() *Step {
+	step := &Step{times: 1}
+	p.mu.Lock()
+	p.steps = append(p.steps, step)
+	p.mu.Unlock()
+	return step
+}
+
+// Prompt expects the prompt to be exactly prompt
+func (s *Step) Prompt(prompt string) *Step {
+	s.prompt = &prompt
+	return s
+}
+
+// PromptContains expects the prompt to contain every one of parts
+func (s *Step) PromptContains(parts ...string) *Step {
+	s.contains = append(s.contains, parts...)
+	return s
+}
+
+// Model expects options.Model to be model
+func (s *Step) Model(model string) *Step {
+	s.model = &model
+	return s
+}
+
+// Match adds a custom expectation, such as a check on the system prompt or
+// temperature. It returns an error describing any mismatch.
+func (s *Step) Match(match func(prompt string, options config.CompletionOptions) error) *Step {
+	s.match = append(s.match, match)
+	return s
+}
+
+// Reply makes the step respond with response
+func (s *Step) Reply(response string) *Step {
+	s.response = response
+	return s
+}
+
+// Stream makes the step respond with chunks. Stream passes them to the
+// callback one at a time; Complete returns them joined.
+func (s *Step) Stream(chunks ...string) *Step {
+	s.chunks = chunks
+	return s
+}
+
+// Fail makes the step fail with err. When streaming, any chunks are sent
+// before the error.
+func (s *Step) Fail(err error) *Step {
+	s.err = err
+	return s
+}
+
+// Delay makes the step wait before responding. Streams wait before every
+// chunk.
+func (s *Step) Delay(delay time.Duration) *Step {
+	s.delay = delay
+	return s
+}
+
+// Times makes the step handle n consecutive calls
+func (s *Step) Times(n int) *Step {
+	s.times = n
+	return s
+}
+
+// Always makes the step handle every remaining call, so it never runs out
+func (s *Step) Always() *Step {
+	s.times = -1
+	return s
+}
+
+// check returns a description of how the call breaks the step's
+// expectations, or an empty string
+func (s *Step) check(prompt string, options config.CompletionOptions) string {
+	var problems []string
+	if s.prompt != nil && prompt != *s.prompt {
+		problems = append(problems, fmt.Sprintf("prompt is %q, want %q", prompt, *s.prompt))
+	}
+	for _, part := range s.contains {
+		if !strings.Contains(prompt, part) {
+			problems = append(problems, fmt.Sprintf("prompt %q does not contain %q", prompt, part))
+		}
+	}
+	if s.model != nil && options.Model != *s.model {
+		problems = append(problems, fmt.Sprintf("model is %q, want %q", options.Model, *s.model))
+	}
+	for _, match := range s.match {
+		if err := match(prompt, options); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return strings.Join(problems, "; ")
+}
+
+// next records the call and returns the step that handles it
+func (p *Provider) next(prompt string, options config.CompletionOptions, stream bool) (*Step, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := len(p.requests) + 1
+	p.requests = append(p.requests, Request{Prompt: prompt, Options: options, Stream: stream})
+	if len(p.steps) == 0 {
+		p.failures = append(p.failures, fmt.Sprintf("call %d: unexpected prompt %q", n, prompt))
+		return nil, fmt.Errorf("%w: %q", ErrUnexpectedCall, prompt)
+	}
+
+	step := p.steps[0]
+	if step.times > 0 {
+		step.times--
+		if step.times == 0 {
+			p.steps = p.steps[1:]
+		}
+	}
+	if problem := step.check(prompt, options); problem != "" {
+		p.failures = append(p.failures, fmt.Sprintf("call %d: %s", n, problem))
+		return nil, fmt.Errorf("%s: call %d: %s", p.name, n, problem)
+	}
+	return step, nil
+}
+
+// Complete runs the next step of the script
+func (p *Provider) Complete(prompt string, options config.CompletionOptions) (string, error) {
+	step, err := p.next(prompt, options, false)
+	if err != nil {
+		return "", err
+	}
+
+	time.Sleep(step.delay)
+	if step.err != nil {
+		return "", step.err
+	}
+	if step.chunks != nil {
+		return strings.Join(step.chunks, ""), nil
+	}
+	return step.response, nil
+}
+
+// Stream runs the next step of the script, passing its chunks, or its
+// reply as a single chunk, to callback
+func (p *Provider) Stream(prompt string, options config.CompletionOptions, callback func(chunk string)) error {
+	step, err := p.next(prompt, options, true)
+	if err != nil {
+		return err
+	}
+
+	chunks := step.chunks
+	if chunks == nil && step.err == nil {
+		chunks = []string{step.response}
+	}
+	for _, chunk := range chunks {
+		time.Sleep(step.delay)
+		callback(chunk)
+	}
+	return step.err
+}
+
+// IsAvailable reports whether the provider is available, true unless
+// changed with SetAvailable
+func (p *Provider) IsAvailable() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.unavailable
+}
+
+// SetAvailable sets what IsAvailable reports
+func (p *Provider) SetAvailable(available bool) {
+	p.mu.Lock()
+	p.unavailable = !available
+	p.mu.Unlock()
+}
+
+// GetName returns the name the provider was created with
+func (p *Provider) GetName() string {
+	return p.name
+}
+
+// Requests returns every call the provider received, in order
+func (p *Provider) Requests() []Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Request(nil), p.requests...)
+}
+
+// AssertDone reports calls that broke the script and steps that were never
+// used. Steps set to Always may go unused.
+func (p *Provider) AssertDone(t testing.TB) {
+	t.Helper()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, failure := range p.failures {
+		t.Errorf("%s: %s", p.name, failure)
+	}
+	for _, step := range p.steps {
+		if step.times > 0 {
+			t.Errorf("%s: %d scripted call(s) not made", p.name, step.times)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package neurogotest_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aldotobing/neurogo/config"
+	"github.com/aldotobing/neurogo/neurogotest"
+	"github.com/aldotobing/neurogo/router"
+)
+
+// newRouter returns a router whose routes ask the provider
+func newRouter(provider *neurogotest.Provider) *router.Router {
+	r := router.New()
+	r.Handle("summarize *", func(ctx *router.Context) error {
+		response, err := provider.Complete("Summarize: "+ctx.Captures[0], config.CompletionOptions{Model: "small"})
+		ctx.Response = response
+		return err
+	})
+	r.Handle("translate * to *", func(ctx *router.Context) error {
+		var chunks []string
+		err := provider.Stream("Translate to "+ctx.Captures[1]+": "+ctx.Captures[0], config.CompletionOptions{}, func(chunk string) {
+			chunks = append(chunks, chunk)
+		})
+		ctx.Response = strings.Join(chunks, "")
+		return err
+	})
+	return r
+}
+
+func TestProviderScript(t *testing.T) {
+	provider := neurogotest.NewProvider("fake")
+	provider.Expect().Prompt("Summarize: a long text").Model("small").Reply("short")
+	provider.Expect().PromptContains("Translate to Spanish", "short").Stream("cor", "to")
+	provider.Expect().PromptContains("Summarize").Reply("again").Times(2)
+	provider.Expect().Reply("forever").Always()
+
+	r := newRouter(provider)
+	neurogotest.AssertResponse(t, r, "summarize a long text | translate to Spanish", "translate * to *", "corto")
+	neurogotest.AssertResponse(t, r, "summarize one", "summarize *", "again")
+	neurogotest.AssertResponse(t, r, "summarize two", "summarize *", "again")
+	for i := 0; i < 3; i++ {
+		neurogotest.AssertResponse(t, r, "summarize more", "summarize *", "forever")
+	}
+	provider.AssertDone(t)
+
+	requests := provider.Requests()
+	if len(requests) != 7 {
+		t.Fatalf("%d requests recorded, want 7", len(requests))
+	}
+	if !requests[1].Stream || requests[0].Stream {
+		t.Errorf("Stream flags are %v and %v, want false and true", requests[0].Stream, requests[1].Stream)
+	}
+}
+
+func TestProviderFail(t *testing.T) {
+	failure := errors.New("rate limited")
+	provider := neurogotest.NewProvider("fake")
+	provider.Expect().Fail(failure)
+	provider.Expect().Stream("partial").Fail(failure)
+
+	if _, err := provider.Complete("hi", config.CompletionOptions{}); !errors.Is(err, failure) {
+		t.Errorf("Complete returned %v, want %v", err, failure)
+	}
+	var chunks []string
+	err := provider.Stream("hi", config.CompletionOptions{}, func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+	if !errors.Is(err, failure) || strings.Join(chunks, "") != "partial" {
+		t.Errorf("Stream sent %q and returned %v, want %q and %v", chunks, err, "partial", failure)
+	}
+	provider.AssertDone(t)
+}
+
+func TestProviderAssertDone(t *testing.T) {
+	t.Run("unexpected call", func(t *testing.T) {
+		provider := neurogotest.NewProvider("fake")
+		provider.Expect().Reply("only once")
+		provider.Complete("one", config.CompletionOptions{})
+		if _, err := provider.Complete("two", config.CompletionOptions{}); !errors.Is(err, neurogotest.ErrUnexpectedCall) {
+			t.Errorf("second call returned %v, want %v", err, neurogotest.ErrUnexpectedCall)
+		}
+		got := assertFailures(t, 1, provider.AssertDone)
+		if len(got) == 1 && !strings.Contains(got[0], `unexpected prompt "two"`) {
+			t.Errorf("failure %q does not name the prompt", got[0])
+		}
+	})
+
+	t.Run("broken expectation", func(t *testing.T) {
+		provider := neurogotest.NewProvider("fake")
+		provider.Expect().Prompt("hello").Model("large")
+		if _, err := provider.Complete("goodbye", config.CompletionOptions{Model: "small"}); err == nil {
+			t.Error("a call breaking the script succeeded")
+		}
+		got := assertFailures(t, 1, provider.AssertDone)
+		if len(got) == 1 && (!strings.Contains(got[0], `want "hello"`) || !strings.Contains(got[0], `want "large"`)) {
+			t.Errorf("failure %q does not describe both mismatches", got[0])
+		}
+	})
+
+	t.Run("calls not made", func(t *testing.T) {
+		provider := neurogotest.NewProvider("fake")
+		provider.Expect().Reply("used")
+		provider.Expect().Reply("partly used").Times(3)
+		provider.Expect().Reply("unused").Always()
+		provider.Complete("one", config.CompletionOptions{})
+		provider.Complete("two", config.CompletionOptions{})
+		got := assertFailures(t, 1, provider.AssertDone)
+		if len(got) == 1 && !strings.Contains(got[0], "2 scripted call(s) not made") {
+			t.Errorf("failure %q does not count the missing calls", got[0])
+		}
+	})
+}
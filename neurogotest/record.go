@@ -0,0 +1,322 @@
+package neurogotest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aldotobing/neurogo/config"
+	"github.com/aldotobing/neurogo/providers"
+)
+
+// RecordEnv is the environment variable that makes Fixture record real
+// provider calls instead of replaying them
+const RecordEnv = "NEUROGO_RECORD"
+
+// ErrNoFixture is returned when replaying a request no recorded exchange
+// matches
+var ErrNoFixture = errors.New("no recorded exchange matches the request")
+
+// Exchange is one recorded provider call
+type Exchange struct {
+	Prompt   string   `json:"prompt"`
+	Options  Options  `json:"options"`
+	Stream   bool     `json:"stream,omitempty"`
+	Response string   `json:"response,omitempty"`
+	Chunks   []string `json:"chunks,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// Options are the completion options of a recorded exchange
+type Options struct {
+	Model        string                 `json:"model,omitempty"`
+	SystemPrompt string                 `json:"system_prompt,omitempty"`
+	Temperature  float64                `json:"temperature,omitempty"`
+	MaxTokens    int                    `json:"max_tokens,omitempty"`
+	Template     string                 `json:"template,omitempty"`
+	Variables    map[string]interface{} `json:"variables,omitempty"`
+}
+
+// recordOptions converts completion options for a fixture file
+func recordOptions(options config.CompletionOptions) Options {
+	return Options{
+		Model:        options.Model,
+		SystemPrompt: options.SystemPrompt,
+		Temperature:  options.Temperature,
+		MaxTokens:    options.MaxTokens,
+		Template:     options.Template,
+		Variables:    options.Variables,
+	}
+}
+
+// fixtureFile is the format of fixture files
+type fixtureFile struct {
+	Provider  string     `json:"provider"`
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// Matcher reports whether a recorded exchange answers a request
+type Matcher func(recorded Exchange, request Request) bool
+
+// MatchRequest matches exchanges with the same prompt, model, system
+// prompt, temperature, max tokens, template and template variables, so
+// calls rendering a template with different values, such as different
+// retrieved context, replay different exchanges. It is the default.
+func MatchRequest(recorded Exchange, request Request) bool {
+	a, b := recorded.Options, request.Options
+	return recorded.Prompt == request.Prompt &&
+		a.Model == b.Model &&
+		a.SystemPrompt == b.SystemPrompt &&
+		a.Temperature == b.Temperature &&
+		a.MaxTokens == b.MaxTokens &&
+		a.Template == b.Template &&
+		sameVariables(a.Variables, b.Variables)
+}
+
+// sameVariables compares template variables as they are written to fixture
+// files, so recorded numbers equal the values they were recorded from
+func sameVariables(recorded, requested map[string]interface{}) bool {
+	if len(recorded) == 0 || len(requested) == 0 {
+		return len(recorded) == len(requested)
+	}
+	a, errA := json.Marshal(recorded)
+	b, errB := json.Marshal(requested)
+	return errA == nil && errB == nil && string(a) == string(b)
+}
+
+// MatchPrompt matches exchanges with the same prompt, whatever the options
+func MatchPrompt(recorded Exchange, request Request) bool {
+	return recorded.Prompt == request.Prompt
+}
+
+// RecorderOption configures a Recorder
+type RecorderOption func(*Recorder)
+
+// WithMatcher sets how replayed requests are matched to recorded exchanges
+func WithMatcher(matcher Matcher) RecorderOption {
+	return func(r *Recorder) {
+		r.matcher = matcher
+	}
+}
+
+// Recorder is a providers.Provider that either records the calls it passes
+// to a real provider into a fixture file, or replays a fixture file without
+// calling any provider.
+//
+// When replaying, identical requests get their recorded responses in the
+// order they were recorded, and the last one once they run out. Streams
+// replay their recorded chunks, and recorded errors are returned again.
+// Requests no exchange matches fail with ErrNoFixture.
+type Recorder struct {
+	provider providers.Provider
+	path     string
+	name     string
+	matcher  Matcher
+
+	mu        sync.Mutex
+	exchanges []Exchange
+	replayed  []bool
+	unmatched []Request
+}
+
+// Record wraps provider, writing every call it handles to the fixture file
+// at path. The file is replaced, not appended to.
+func Record(provider providers.Provider, path string, opts ...RecorderOption) *Recorder {
+	r := &Recorder{provider: provider, path: path, name: provider.GetName(), matcher: MatchRequest}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Replay loads the fixture file at path and serves its exchanges
+func Replay(path string, opts ...RecorderOption) (*Recorder, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	var file fixtureFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: failed to parse fixture: %w", path, err)
+	}
+
+	r := &Recorder{
+		path:      path,
+		name:      file.Provider,
+		matcher:   MatchRequest,
+		exchanges: file.Exchanges,
+		replayed:  make([]bool, len(file.Exchanges)),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+// Fixture returns a Recorder for the fixture file at path. If RecordEnv is
+// set it records the provider returned by live, otherwise it replays the
+// file. Replayed requests that match no exchange fail the test when it
+// ends.
+func Fixture(t testing.TB, path string, live func() providers.Provider, opts ...RecorderOption) *Recorder {
+	t.Helper()
+
+	if os.Getenv(RecordEnv) != "" {
+		return Record(live(), path, opts...)
+	}
+
+	r, err := Replay(path, opts...)
+	if err != nil {
+		t.Fatalf("%v (set %s=1 to record it)", err, RecordEnv)
+	}
+	t.Cleanup(func() {
+		r.AssertDone(t)
+	})
+	return r
+}
+
+// Recording reports whether the recorder calls a real provider
+func (r *Recorder) Recording() bool {
+	return r.provider != nil
+}
+
+// Complete records or replays a completion
+func (r *Recorder) Complete(prompt string, options config.CompletionOptions) (string, error) {
+	request := Request{Prompt: prompt, Options: options}
+	if !r.Recording() {
+		exchange, err := r.replay(request)
+		if err != nil {
+			return "", err
+		}
+		if exchange.Error != "" {
+			return "", errors.New(exchange.Error)
+		}
+		if exchange.Chunks != nil {
+			return strings.Join(exchange.Chunks, ""), nil
+		}
+		return exchange.Response, nil
+	}
+
+	response, err := r.provider.Complete(prompt, options)
+	exchange := Exchange{Prompt: prompt, Options: recordOptions(options), Response: response}
+	if err != nil {
+		exchange.Error = err.Error()
+	}
+	if saveErr := r.record(exchange); saveErr != nil {
+		return "", saveErr
+	}
+	return response, err
+}
+
+// Stream records or replays a streamed completion
+func (r *Recorder) Stream(prompt string, options config.CompletionOptions, callback func(chunk string)) error {
+	request := Request{Prompt: prompt, Options: options, Stream: true}
+	if !r.Recording() {
+		exchange, err := r.replay(request)
+		if err != nil {
+			return err
+		}
+		chunks := exchange.Chunks
+		if chunks == nil && exchange.Response != "" {
+			chunks = []string{exchange.Response}
+		}
+		for _, chunk := range chunks {
+			callback(chunk)
+		}
+		if exchange.Error != "" {
+			return errors.New(exchange.Error)
+		}
+		return nil
+	}
+
+	exchange := Exchange{Prompt: prompt, Options: recordOptions(options), Stream: true, Chunks: []string{}}
+	err := r.provider.Stream(prompt, options, func(chunk string) {
+		exchange.Chunks = append(exchange.Chunks, chunk)
+		callback(chunk)
+	})
+	if err != nil {
+		exchange.Error = err.Error()
+	}
+	if saveErr := r.record(exchange); saveErr != nil {
+		return saveErr
+	}
+	return err
+}
+
+// IsAvailable reports whether the recorded provider is available. A
+// replaying recorder is always available.
+func (r *Recorder) IsAvailable() bool {
+	return !r.Recording() || r.provider.IsAvailable()
+}
+
+// GetName returns the name of the recorded provider
+func (r *Recorder) GetName() string {
+	return r.name
+}
+
+// Exchanges returns the recorded exchanges
+func (r *Recorder) Exchanges() []Exchange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Exchange(nil), r.exchanges...)
+}
+
+// AssertDone reports replayed requests that no exchange matched
+func (r *Recorder) AssertDone(t testing.TB) {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, request := range r.unmatched {
+		t.Errorf("%s: no exchange in %s matches prompt %q (model %q)", r.name, r.path, request.Prompt, request.Options.Model)
+	}
+}
+
+// replay returns the exchange that answers the request: the first matching
+// one not replayed yet, or the last matching one
+func (r *Recorder) replay(request Request) (Exchange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last := -1
+	for i, exchange := range r.exchanges {
+		if !r.matcher(exchange, request) {
+			continue
+		}
+		if !r.replayed[i] {
+			r.replayed[i] = true
+			return exchange, nil
+		}
+		last = i
+	}
+	if last >= 0 {
+		return r.exchanges[last], nil
+	}
+
+	r.unmatched = append(r.unmatched, request)
+	return Exchange{}, fmt.Errorf("%w: %s: prompt %q", ErrNoFixture, r.path, request.Prompt)
+}
+
+// record appends the exchange and rewrites the fixture file
+func (r *Recorder) record(exchange Exchange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.exchanges = append(r.exchanges, exchange)
+	data, err := json.MarshalIndent(fixtureFile{Provider: r.name, Exchanges: r.exchanges}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	if err := os.WriteFile(r.path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package neurogotest_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aldotobing/neurogo/config"
+	"github.com/aldotobing/neurogo/neurogotest"
+)
+
+func TestRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures", "chat.json")
+	rag := func(context string) config.CompletionOptions {
+		return config.CompletionOptions{Model: "small", Template: "rag_answer", Variables: map[string]interface{}{"context": context, "k": 3}}
+	}
+
+	live := neurogotest.NewProvider("live")
+	live.Expect().Prompt("hello").Reply("hi")
+	live.Expect().Prompt("hello").Reply("hi again")
+	live.Expect().Prompt("count").Stream("one ", "two")
+	live.Expect().Prompt("fail").Stream("partial").Fail(errors.New("rate limited"))
+	live.Expect().Prompt("where?").Reply("in the attic")
+	live.Expect().Prompt("where?").Reply("in the cellar")
+
+	recorder := neurogotest.Record(live, path)
+	if !recorder.Recording() {
+		t.Fatal("Record returned a replaying recorder")
+	}
+	calls := func(p interface {
+		Complete(string, config.CompletionOptions) (string, error)
+		Stream(string, config.CompletionOptions, func(string)) error
+	}) []string {
+		var got []string
+		complete := func(prompt string, options config.CompletionOptions) {
+			response, err := p.Complete(prompt, options)
+			got = append(got, response+"|"+errString(err))
+		}
+		stream := func(prompt string) {
+			var chunks []string
+			err := p.Stream(prompt, config.CompletionOptions{}, func(chunk string) {
+				chunks = append(chunks, chunk)
+			})
+			got = append(got, strings.Join(chunks, "+")+"|"+errString(err))
+		}
+		complete("hello", config.CompletionOptions{})
+		complete("hello", config.CompletionOptions{})
+		stream("count")
+		stream("fail")
+		complete("where?", rag("attic notes"))
+		complete("where?", rag("cellar notes"))
+		return got
+	}
+
+	recorded := calls(recorder)
+	live.AssertDone(t)
+	if n := len(recorder.Exchanges()); n != 6 {
+		t.Fatalf("%d exchanges recorded, want 6", n)
+	}
+
+	replayer, err := neurogotest.Replay(path)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if replayer.Recording() || replayer.GetName() != "live" {
+		t.Errorf("Replay returned a recorder named %q, recording %v", replayer.GetName(), replayer.Recording())
+	}
+	replayed := calls(replayer)
+	if strings.Join(replayed, "\n") != strings.Join(recorded, "\n") {
+		t.Errorf("replayed\n%q\nwant\n%q", replayed, recorded)
+	}
+	if replayed[4] == replayed[5] {
+		t.Errorf("requests with different template variables replayed the same exchange %q", replayed[4])
+	}
+
+	// Identical requests get the last response once the recorded ones ran out
+	if response, _ := replayer.Complete("hello", config.CompletionOptions{}); response != "hi again" {
+		t.Errorf("extra request got %q, want %q", response, "hi again")
+	}
+	replayer.AssertDone(t)
+
+	if _, err := replayer.Complete("hello", config.CompletionOptions{Model: "large"}); !errors.Is(err, neurogotest.ErrNoFixture) {
+		t.Errorf("unmatched request returned %v, want %v", err, neurogotest.ErrNoFixture)
+	}
+	if _, err := replayer.Complete("where?", rag("garden notes")); !errors.Is(err, neurogotest.ErrNoFixture) {
+		t.Errorf("request with new template variables returned %v, want %v", err, neurogotest.ErrNoFixture)
+	}
+	got := assertFailures(t, 2, replayer.AssertDone)
+	if len(got) == 2 && !strings.Contains(got[0], `prompt "hello" (model "large")`) {
+		t.Errorf("failure %q does not describe the request", got[0])
+	}
+}
+
+func TestReplayMatchPrompt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat.json")
+	live := neurogotest.NewProvider("live")
+	live.Expect().Reply("hi")
+	neurogotest.Record(live, path).Complete("hello", config.CompletionOptions{Model: "small"})
+
+	replayer, err := neurogotest.Replay(path, neurogotest.WithMatcher(neurogotest.MatchPrompt))
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if response, err := replayer.Complete("hello", config.CompletionOptions{Model: "large"}); response != "hi" || err != nil {
+		t.Errorf("Complete returned %q, %v, want %q", response, err, "hi")
+	}
+	replayer.AssertDone(t)
+}
+
+func TestFixtureMissing(t *testing.T) {
+	t.Setenv(neurogotest.RecordEnv, "")
+	path := filepath.Join(t.TempDir(), "missing.json")
+	got := assertFailures(t, 1, func(t testing.TB) {
+		neurogotest.Fixture(t, path, nil)
+	})
+	if len(got) == 1 && !strings.Contains(got[0], neurogotest.RecordEnv) {
+		t.Errorf("failure %q does not say how to record the fixture", got[0])
+	}
+}
+
+// errString returns the message of err, or an empty string
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,101 @@
+package neurogotest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aldotobing/neurogo/router"
+)
+
+// AssertRoute checks which routes would handle the prompt, one pattern per
+// pipeline stage, without running any handler
+func AssertRoute(t testing.TB, r *router.Router, prompt string, patterns ...string) {
+	t.Helper()
+
+	explanation := r.Explain(prompt)
+	winners := make([]string, len(explanation.Stages))
+	for i, stage := range explanation.Stages {
+		winners[i] = stage.Winner
+	}
+	if strings.Join(winners, "\x00") != strings.Join(patterns, "\x00") {
+		t.Errorf("%q is routed to %q, want %q", prompt, winners, patterns)
+	}
+}
+
+// AssertNoRoute checks that no pattern matches the prompt. Fallbacks and
+// the not-found handler are not consulted.
+func AssertNoRoute(t testing.TB, r *router.Router, prompt string) {
+	t.Helper()
+
+	for _, stage := range r.Explain(prompt).Stages {
+		if stage.Winner != "" {
+			t.Errorf("%q is routed to %q, want no route", prompt, stage.Winner)
+		}
+	}
+}
+
+// Run processes the prompt and fails the test if it returns an error
+func Run(t testing.TB, r *router.Router, prompt string) *router.Result {
+	t.Helper()
+
+	result, err := r.Execute(prompt)
+	if err != nil {
+		t.Fatalf("processing %q failed: %v", prompt, err)
+	}
+	return result
+}
+
+// AssertResponse processes the prompt and checks that it was handled by the
+// route with pattern and produced want. For pipelines, pattern is the last
+// stage's route. An empty pattern is not checked.
+func AssertResponse(t testing.TB, r *router.Router, prompt, pattern, want string) *router.Result {
+	t.Helper()
+
+	result := Run(t, r, prompt)
+	checkPattern(t, prompt, result, pattern)
+	if result.Response != want {
+		t.Errorf("%q responded %q, want %q", prompt, result.Response, want)
+	}
+	return result
+}
+
+// AssertResponseContains is like AssertResponse but only checks that the
+// response contains every one of parts
+func AssertResponseContains(t testing.TB, r *router.Router, prompt, pattern string, parts ...string) *router.Result {
+	t.Helper()
+
+	result := Run(t, r, prompt)
+	checkPattern(t, prompt, result, pattern)
+	for _, part := range parts {
+		if !strings.Contains(result.Response, part) {
+			t.Errorf("%q responded %q, which does not contain %q", prompt, result.Response, part)
+		}
+	}
+	return result
+}
+
+// AssertError processes the prompt and checks that it fails with code
+func AssertError(t testing.TB, r *router.Router, prompt string, code router.Code) error {
+	t.Helper()
+
+	_, err := r.Execute(prompt)
+	switch {
+	case err == nil:
+		t.Errorf("%q succeeded, want a %s error", prompt, code)
+	case router.CodeOf(err) != code:
+		t.Errorf("%q failed with %s error %q, want a %s error", prompt, router.CodeOf(err), err, code)
+	}
+	return err
+}
+
+// checkPattern checks the route that handled the last stage
+func checkPattern(t testing.TB, prompt string, result *router.Result, pattern string) {
+	t.Helper()
+
+	if pattern == "" || len(result.Stages) == 0 {
+		return
+	}
+	if got := result.Stages[len(result.Stages)-1].Pattern; got != pattern {
+		t.Errorf("%q was handled by %q, want %q", prompt, got, pattern)
+	}
+}
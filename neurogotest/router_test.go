@@ -0,0 +1,63 @@
+package neurogotest_test
+
+import (
+	"testing"
+
+	"github.com/aldotobing/neurogo/neurogotest"
+	"github.com/aldotobing/neurogo/router"
+)
+
+func TestAssertRoute(t *testing.T) {
+	r := newRouter(neurogotest.NewProvider("unused"))
+
+	neurogotest.AssertRoute(t, r, "summarize this", "summarize *")
+	neurogotest.AssertRoute(t, r, "summarize this | translate to Spanish", "summarize *", "translate * to *")
+	neurogotest.AssertNoRoute(t, r, "dance")
+
+	assertFailures(t, 1, func(t testing.TB) {
+		neurogotest.AssertRoute(t, r, "summarize this | translate to Spanish", "summarize *")
+	})
+	assertFailures(t, 1, func(t testing.TB) {
+		neurogotest.AssertRoute(t, r, "summarize this", "translate * to *")
+	})
+	assertFailures(t, 1, func(t testing.TB) {
+		neurogotest.AssertNoRoute(t, r, "summarize this")
+	})
+}
+
+func TestAssertResponse(t *testing.T) {
+	provider := neurogotest.NewProvider("fake")
+	provider.Expect().Reply("short").Always()
+	r := newRouter(provider)
+
+	neurogotest.AssertResponseContains(t, r, "summarize text", "summarize *", "sho", "ort")
+	assertFailures(t, 1, func(t testing.TB) {
+		neurogotest.AssertResponse(t, r, "summarize text", "summarize *", "long")
+	})
+	assertFailures(t, 1, func(t testing.TB) {
+		neurogotest.AssertResponse(t, r, "summarize text", "translate * to *", "short")
+	})
+	assertFailures(t, 1, func(t testing.TB) {
+		neurogotest.AssertResponseContains(t, r, "summarize text", "", "long")
+	})
+	// Run stops the test when the prompt fails, so nothing after it runs
+	assertFailures(t, 1, func(t testing.TB) {
+		neurogotest.AssertResponse(t, r, "dance", "", "")
+		t.Errorf("AssertResponse did not stop the test")
+	})
+}
+
+func TestAssertError(t *testing.T) {
+	r := newRouter(neurogotest.NewProvider("empty"))
+
+	neurogotest.AssertError(t, r, "dance", router.CodeNotFound)
+	assertFailures(t, 1, func(t testing.TB) {
+		neurogotest.AssertError(t, r, "dance", router.CodeInternal)
+	})
+
+	provider := neurogotest.NewProvider("fake")
+	provider.Expect().Reply("fine")
+	assertFailures(t, 1, func(t testing.TB) {
+		neurogotest.AssertError(t, newRouter(provider), "summarize text", router.CodeNotFound)
+	})
+}
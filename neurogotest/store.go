@@ -0,0 +1,184 @@
+package neurogotest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aldotobing/neurogo/vector"
+)
+
+// TestStore checks that a vector.Store behaves like the stores in the
+// vector package: upserts replace items, deletes ignore unknown IDs,
+// queries rank by cosine similarity and honor filters, and counts match.
+// open is called once per subtest and must return an empty store, which
+// TestStore closes. Adapters for external stores can run it against a
+// local container, as the vector package does for Qdrant:
+//
+//	func TestQdrant(t *testing.T) {
+//		url := os.Getenv("QDRANT_URL") // e.g. http://localhost:6333
+//		if url == "" {
+//			t.Skip("QDRANT_URL is not set")
+//		}
+//		neurogotest.TestStore(t, func(t *testing.T) vector.Store {
+//			name := strings.NewReplacer("/", "_", " ", "_").Replace(strings.ToLower(t.Name()))
+//			return vector.NewQdrantStore(url, name, "")
+//		})
+//	}
+func TestStore(t *testing.T, open func(t *testing.T) vector.Store) {
+	ctx := context.Background()
+
+	run := func(name string, check func(t *testing.T, store vector.Store)) {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			defer func() {
+				if err := store.Close(); err != nil {
+					t.Errorf("Close failed: %v", err)
+				}
+			}()
+			check(t, store)
+		})
+	}
+
+	run("Empty", func(t *testing.T, store vector.Store) {
+		assertCount(t, store, nil, 0)
+		results, err := store.Query(ctx, []float32{1, 0, 0}, 3, nil)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if len(results) != 0 {
+			t.Errorf("Query returned %d results from an empty store", len(results))
+		}
+	})
+
+	run("Query", func(t *testing.T, store vector.Store) {
+		upsert(t, store, storeItems()...)
+		assertCount(t, store, nil, 4)
+
+		results, err := store.Query(ctx, []float32{1, 0.1, 0}, 2, nil)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		assertIDs(t, results, "x", "xy")
+		if len(results) == 2 && results[0].Score < results[1].Score {
+			t.Errorf("results are not sorted best first: %v then %v", results[0].Score, results[1].Score)
+		}
+		if len(results) > 0 && results[0].Metadata["axis"] != "x" {
+			t.Errorf("metadata of x is %v, want axis=x", results[0].Metadata)
+		}
+	})
+
+	run("Filter", func(t *testing.T, store vector.Store) {
+		upsert(t, store, storeItems()...)
+		assertCount(t, store, vector.Filter{"group": "b"}, 2)
+		assertCount(t, store, vector.Filter{"group": "b", "axis": "y"}, 1)
+		assertCount(t, store, vector.Filter{"group": "none"}, 0)
+
+		results, err := store.Query(ctx, []float32{1, 0, 0}, 3, vector.Filter{"group": "b"})
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		assertIDs(t, results, "xy", "y")
+	})
+
+	run("Upsert replaces", func(t *testing.T, store vector.Store) {
+		upsert(t, store, storeItems()...)
+		upsert(t, store, vector.Item{ID: "z", Vector: []float32{1, 0, 0}, Metadata: map[string]string{"group": "c"}})
+		assertCount(t, store, nil, 4)
+		assertCount(t, store, vector.Filter{"group": "c"}, 1)
+
+		results, err := store.Query(ctx, []float32{1, 0, 0}, 2, vector.Filter{"group": "a"})
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		assertIDs(t, results, "x")
+	})
+
+	run("Delete", func(t *testing.T, store vector.Store) {
+		upsert(t, store, storeItems()...)
+		if err := store.Delete(ctx, "x", "unknown"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		assertCount(t, store, nil, 3)
+
+		results, err := store.Query(ctx, []float32{1, 0, 0}, 1, nil)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		assertIDs(t, results, "xy")
+	})
+
+	run("Many", func(t *testing.T, store vector.Store) {
+		items := make([]vector.Item, 500)
+		for i := range items {
+			v := make([]float32, 8)
+			v[i%8] = 1
+			v[(i+1)%8] = float32(i) / 500
+			items[i] = vector.Item{ID: fmt.Sprintf("item-%d", i), Vector: v, Metadata: map[string]string{"mod": fmt.Sprint(i % 10)}}
+		}
+		upsert(t, store, items...)
+		assertCount(t, store, nil, len(items))
+		assertCount(t, store, vector.Filter{"mod": "3"}, len(items)/10)
+
+		results, err := store.Query(ctx, items[123].Vector, 1, nil)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		assertIDs(t, results, "item-123")
+
+		results, err = store.Query(ctx, items[123].Vector, 5, vector.Filter{"mod": "7"})
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if len(results) != 5 {
+			t.Fatalf("Query returned %d results, want 5", len(results))
+		}
+		for _, result := range results {
+			if result.Metadata["mod"] != "7" {
+				t.Errorf("Query returned %s, which does not match the filter", result.ID)
+			}
+		}
+	})
+}
+
+// storeItems are the items the TestStore checks start from
+func storeItems() []vector.Item {
+	return []vector.Item{
+		{ID: "x", Vector: []float32{1, 0, 0}, Metadata: map[string]string{"axis": "x", "group": "a"}},
+		{ID: "xy", Vector: []float32{1, 1, 0}, Metadata: map[string]string{"axis": "xy", "group": "b"}},
+		{ID: "y", Vector: []float32{0, 1, 0}, Metadata: map[string]string{"axis": "y", "group": "b"}},
+		{ID: "z", Vector: []float32{0, 0, 1}, Metadata: map[string]string{"axis": "z", "group": "a"}},
+	}
+}
+
+// upsert adds items to a store, failing the test if it cannot
+func upsert(t *testing.T, store vector.Store, items ...vector.Item) {
+	t.Helper()
+	if err := store.Upsert(context.Background(), items...); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+}
+
+// assertCount checks the number of items matching a filter
+func assertCount(t *testing.T, store vector.Store, filter vector.Filter, want int) {
+	t.Helper()
+	n, err := store.Count(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if n != want {
+		t.Errorf("Count(%v) = %d, want %d", filter, n, want)
+	}
+}
+
+// assertIDs checks the IDs of query results, in order
+func assertIDs(t *testing.T, results []vector.Result, want ...string) {
+	t.Helper()
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.ID
+	}
+	if fmt.Sprint(ids) != fmt.Sprint(want) {
+		t.Errorf("Query returned %v, want %v", ids, want)
+	}
+}
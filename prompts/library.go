@@ -0,0 +1,258 @@
+package prompts
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aldotobing/neurogo/config"
+)
+
+// Extension is the file extension of template files
+const Extension = ".tmpl"
+
+// ErrNotFound is returned when a template reference matches no template
+var ErrNotFound = errors.New("prompt template not found")
+
+//go:embed defaults/*.tmpl
+var defaults embed.FS
+
+// Library holds named, versioned prompt templates and the partials they
+// share. It is safe for concurrent use.
+type Library struct {
+	mu        sync.RWMutex
+	templates map[string][]*Template
+	partials  map[string]string
+}
+
+// NewLibrary creates an empty library
+func NewLibrary() *Library {
+	return &Library{
+		templates: make(map[string][]*Template),
+		partials:  make(map[string]string),
+	}
+}
+
+// Default returns a library with the built-in prompts used by the server
+func Default() (*Library, error) {
+	l := NewLibrary()
+	if err := l.LoadFS(defaults, "defaults"); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// LoadDir adds the templates in a directory. Files ending in .tmpl are
+// templates named after the file unless their front matter says otherwise;
+// files starting with an underscore, such as "_tone.tmpl", are partials
+// named without it. A template with the same name and version as an
+// existing one replaces it.
+func (l *Library) LoadDir(dir string) error {
+	return l.LoadFS(os.DirFS(dir), ".")
+}
+
+// LoadFS is LoadDir for a directory in a file system
+func (l *Library) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("failed to read prompt directory: %w", err)
+	}
+
+	partials := make(map[string]string)
+	var templates []*Template
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || path.Ext(name) != Extension {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("failed to read prompt template: %w", err)
+		}
+
+		name = strings.TrimSuffix(name, Extension)
+		if strings.HasPrefix(name, "_") {
+			partials[strings.TrimPrefix(name, "_")] = strings.TrimRight(string(data), "\n")
+			continue
+		}
+
+		t, err := Parse(name, data)
+		if err != nil {
+			return err
+		}
+		templates = append(templates, t)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	merged := make(map[string]string, len(l.partials)+len(partials))
+	for name, body := range l.partials {
+		merged[name] = body
+	}
+	for name, body := range partials {
+		merged[name] = body
+	}
+	return l.update(merged, templates...)
+}
+
+// Add compiles templates and adds them to the library
+func (l *Library) Add(templates ...*Template) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, t := range templates {
+		if t.Version == 0 {
+			t.Version = 1
+		}
+	}
+	return l.update(l.partials, templates...)
+}
+
+// AddPartial adds a partial that templates can include with
+// {{ template "name" . }}, recompiling every template
+func (l *Library) AddPartial(name, text string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	partials := make(map[string]string, len(l.partials)+1)
+	for partial, body := range l.partials {
+		partials[partial] = body
+	}
+	partials[name] = text
+	return l.update(partials)
+}
+
+// update installs the partials and templates after recompiling everything
+// against the new partials. Nothing changes if any template fails to
+// compile. The caller must hold the write lock.
+func (l *Library) update(partials map[string]string, added ...*Template) error {
+	templates := make(map[string][]*Template, len(l.templates)+len(added))
+	for name, versions := range l.templates {
+		templates[name] = append([]*Template(nil), versions...)
+	}
+	for _, t := range added {
+		versions := templates[t.Name]
+		replaced := false
+		for i, existing := range versions {
+			if existing.Version == t.Version {
+				versions[i], replaced = t, true
+			}
+		}
+		if !replaced {
+			versions = append(versions, t)
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i].Version < versions[j].Version
+		})
+		templates[t.Name] = versions
+	}
+
+	// Templates are compiled into copies so a failure leaves the library
+	// untouched
+	for name, versions := range templates {
+		for i, t := range versions {
+			compiled := *t
+			if err := compiled.compile(partials); err != nil {
+				return err
+			}
+			versions[i] = &compiled
+		}
+		templates[name] = versions
+	}
+
+	l.templates, l.partials = templates, partials
+	return nil
+}
+
+// Get returns the template for a reference: "name" for the latest version
+// or "name@version" for a specific one
+func (l *Library) Get(ref string) (*Template, error) {
+	name, version := ref, 0
+	if i := strings.LastIndex(ref, "@"); i >= 0 {
+		v, err := strconv.Atoi(ref[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid template reference %q", ref)
+		}
+		name, version = ref[:i], v
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	versions := l.templates[name]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, ref)
+	}
+	if version == 0 {
+		return versions[len(versions)-1], nil
+	}
+	for _, t := range versions {
+		if t.Version == version {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, ref)
+}
+
+// List returns the latest version of every template, sorted by name
+func (l *Library) List() []*Template {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	list := make([]*Template, 0, len(l.templates))
+	for _, versions := range l.templates {
+		list = append(list, versions[len(versions)-1])
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Render renders the referenced template with the variables
+func (l *Library) Render(ref string, vars map[string]interface{}) (prompt, system string, err error) {
+	t, err := l.Get(ref)
+	if err != nil {
+		return "", "", err
+	}
+	return t.Render(vars)
+}
+
+// Apply renders the template named by options.Template, if any. The prompt
+// is available to the template as the "input" variable unless Variables
+// sets it. The rendered system prompt is used when options has none of its
+// own. The returned options no longer reference a template.
+func (l *Library) Apply(prompt string, options config.CompletionOptions) (string, config.CompletionOptions, error) {
+	if options.Template == "" {
+		return prompt, options, nil
+	}
+
+	vars := make(map[string]interface{}, len(options.Variables)+1)
+	for name, value := range options.Variables {
+		vars[name] = value
+	}
+	if _, exists := vars["input"]; !exists {
+		vars["input"] = prompt
+	}
+
+	rendered, system, err := l.Render(options.Template, vars)
+	if err != nil {
+		return "", options, err
+	}
+
+	if options.SystemPrompt == "" {
+		options.SystemPrompt = strings.TrimSpace(system)
+	}
+	options.Template = ""
+	options.Variables = nil
+	return rendered, options, nil
+}
@@ -0,0 +1,61 @@
+package prompts
+
+import (
+	"context"
+
+	"github.com/aldotobing/neurogo/config"
+	"github.com/aldotobing/neurogo/providers"
+)
+
+// templatedProvider renders prompt templates before calling the provider
+type templatedProvider struct {
+	providers.Provider
+	library *Library
+}
+
+// templatedEmbedder keeps the embedding support of a wrapped provider
+type templatedEmbedder struct {
+	*templatedProvider
+	providers.Embedder
+}
+
+// Wrap returns a provider that renders the template named in
+// CompletionOptions.Template before completing, so callers can reference
+// prompts by name. Embedding support of the provider is preserved.
+func (l *Library) Wrap(provider providers.Provider) providers.Provider {
+	wrapped := &templatedProvider{Provider: provider, library: l}
+	if embedder, ok := provider.(providers.Embedder); ok {
+		return &templatedEmbedder{templatedProvider: wrapped, Embedder: embedder}
+	}
+	return wrapped
+}
+
+// Complete implements providers.Provider
+func (p *templatedProvider) Complete(prompt string, options config.CompletionOptions) (string, error) {
+	prompt, options, err := p.library.Apply(prompt, options)
+	if err != nil {
+		return "", err
+	}
+	return p.Provider.Complete(prompt, options)
+}
+
+// Stream implements providers.Provider
+func (p *templatedProvider) Stream(prompt string, options config.CompletionOptions, callback func(chunk string)) error {
+	prompt, options, err := p.library.Apply(prompt, options)
+	if err != nil {
+		return err
+	}
+	return p.Provider.Stream(prompt, options, callback)
+}
+
+// StreamEvents implements providers.Streamer, keeping the typed events and
+// cancellation of the wrapped provider when it supports them
+func (p *templatedProvider) StreamEvents(ctx context.Context, prompt string, options config.CompletionOptions) *providers.EventStream {
+	prompt, options, err := p.library.Apply(prompt, options)
+	if err != nil {
+		return providers.NewEventStream(ctx, func(context.Context, func(providers.StreamEvent) bool) error {
+			return err
+		})
+	}
+	return providers.OpenStream(ctx, p.Provider, prompt, options)
+}
@@ -0,0 +1,271 @@
+package prompts
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidVariables is returned when the variables passed to a template
+// are missing or have the wrong type
+var ErrInvalidVariables = errors.New("invalid template variables")
+
+// VarType is the type of a template variable
+type VarType string
+
+const (
+	TypeString VarType = "string"
+	TypeInt    VarType = "int"
+	TypeFloat  VarType = "float"
+	TypeBool   VarType = "bool"
+	TypeList   VarType = "list"
+	TypeAny    VarType = "any"
+)
+
+// Variable declares a value a template expects
+type Variable struct {
+	// Type defaults to string
+	Type        VarType     `yaml:"type,omitempty" json:"type,omitempty"`
+	Required    bool        `yaml:"required,omitempty" json:"required,omitempty"`
+	Default     interface{} `yaml:"default,omitempty" json:"default,omitempty"`
+	Description string      `yaml:"description,omitempty" json:"description,omitempty"`
+}
+
+// Template is a named, versioned prompt. Text and System are text/template
+// strings rendered with the variables, and may include the library's
+// partials with {{ template "name" . }}.
+type Template struct {
+	Name        string              `yaml:"name,omitempty" json:"name"`
+	Version     int                 `yaml:"version,omitempty" json:"version"`
+	Description string              `yaml:"description,omitempty" json:"description,omitempty"`
+	Variables   map[string]Variable `yaml:"variables,omitempty" json:"variables,omitempty"`
+
+	// System is rendered into the completion's system prompt
+	System string `yaml:"system,omitempty" json:"system,omitempty"`
+
+	// Text is rendered into the prompt
+	Text string `yaml:"-" json:"text"`
+
+	prompt *template.Template
+	system *template.Template
+}
+
+// Ref returns the template's reference, "name@version"
+func (t *Template) Ref() string {
+	return fmt.Sprintf("%s@%d", t.Name, t.Version)
+}
+
+// Parse reads a template file: an optional YAML front matter block between
+// "---" lines declaring the name, version, description, system prompt and
+// variables, followed by the prompt text. The name defaults to the given
+// one and the version to 1.
+func Parse(name string, data []byte) (*Template, error) {
+	t := &Template{}
+	text := data
+
+	if rest, ok := cutLine(data, "---"); ok {
+		end := bytes.Index(rest, []byte("\n---"))
+		if end < 0 {
+			return nil, fmt.Errorf("template %s: unterminated front matter", name)
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(rest[:end]))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(t); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("template %s: invalid front matter: %w", name, err)
+		}
+
+		text = rest[end+len("\n---"):]
+		if after, ok := cutLine(text, ""); ok {
+			text = after
+		}
+	}
+
+	if t.Name == "" {
+		t.Name = name
+	}
+	if t.Version == 0 {
+		t.Version = 1
+	}
+	t.Text = strings.TrimRight(string(text), "\n")
+	return t, nil
+}
+
+// cutLine removes a first line equal to prefix, ignoring a carriage return
+func cutLine(data []byte, prefix string) ([]byte, bool) {
+	line, rest, found := bytes.Cut(data, []byte("\n"))
+	if !found || strings.TrimRight(string(line), "\r") != prefix {
+		return data, false
+	}
+	return rest, true
+}
+
+// compile parses the template's text and system prompt together with the
+// partials
+func (t *Template) compile(partials map[string]string) error {
+	if t.Name == "" {
+		return errors.New("template has no name")
+	}
+	for name, variable := range t.Variables {
+		switch variable.Type {
+		case "", TypeString, TypeInt, TypeFloat, TypeBool, TypeList, TypeAny:
+		default:
+			return fmt.Errorf("template %s: variable %q has unknown type %q", t.Ref(), name, variable.Type)
+		}
+	}
+
+	prompt, err := newTemplate(t.Ref(), t.Text, partials)
+	if err != nil {
+		return fmt.Errorf("template %s: %w", t.Ref(), err)
+	}
+	system, err := newTemplate(t.Ref()+"#system", t.System, partials)
+	if err != nil {
+		return fmt.Errorf("template %s: system prompt: %w", t.Ref(), err)
+	}
+
+	t.prompt, t.system = prompt, system
+	return nil
+}
+
+// newTemplate parses text with the partials available as named templates
+func newTemplate(name, text string, partials map[string]string) (*template.Template, error) {
+	root := template.New(name).Funcs(funcs).Option("missingkey=error")
+	for partial, body := range partials {
+		if _, err := root.New(partial).Parse(body); err != nil {
+			return nil, fmt.Errorf("partial %q: %w", partial, err)
+		}
+	}
+	return root.Parse(text)
+}
+
+var funcs = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+}
+
+// Render validates the variables and renders the prompt and system prompt.
+// Only templates returned by a Library can be rendered.
+func (t *Template) Render(vars map[string]interface{}) (prompt, system string, err error) {
+	if t.prompt == nil {
+		return "", "", fmt.Errorf("template %s is not compiled; get it from a library", t.Ref())
+	}
+
+	data, err := t.bind(vars)
+	if err != nil {
+		return "", "", err
+	}
+
+	var b strings.Builder
+	if err := t.prompt.Execute(&b, data); err != nil {
+		return "", "", fmt.Errorf("rendering template %s failed: %w", t.Ref(), err)
+	}
+	prompt = b.String()
+
+	b.Reset()
+	if err := t.system.Execute(&b, data); err != nil {
+		return "", "", fmt.Errorf("rendering template %s failed: %w", t.Ref(), err)
+	}
+	return prompt, b.String(), nil
+}
+
+// bind checks the variables against the declarations and fills in defaults.
+// Variables the template does not declare are passed through unchecked.
+func (t *Template) bind(vars map[string]interface{}) (map[string]interface{}, error) {
+	data := make(map[string]interface{}, len(vars)+len(t.Variables))
+	for name, value := range vars {
+		data[name] = value
+	}
+
+	var problems []string
+	for name, variable := range t.Variables {
+		value, exists := data[name]
+		if !exists || value == nil {
+			switch {
+			case variable.Default != nil:
+				data[name] = variable.Default
+			case variable.Required:
+				problems = append(problems, fmt.Sprintf("%q is required", name))
+			default:
+				data[name] = zero(variable.Type)
+			}
+			continue
+		}
+
+		if !hasType(value, variable.Type) {
+			problems = append(problems, fmt.Sprintf("%q must be of type %s, got %T", name, variable.typeName(), value))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("%w for %s: %s", ErrInvalidVariables, t.Ref(), strings.Join(problems, "; "))
+	}
+	return data, nil
+}
+
+func (v Variable) typeName() VarType {
+	if v.Type == "" {
+		return TypeString
+	}
+	return v.Type
+}
+
+// hasType reports whether value fits the variable type. Whole floats count
+// as ints, since JSON decodes every number as a float64.
+func hasType(value interface{}, varType VarType) bool {
+	v := reflect.ValueOf(value)
+	switch varType {
+	case "", TypeString:
+		return v.Kind() == reflect.String
+	case TypeInt:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return true
+		case reflect.Float32, reflect.Float64:
+			return v.Float() == float64(int64(v.Float()))
+		}
+		return false
+	case TypeFloat:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			return true
+		}
+		return false
+	case TypeBool:
+		return v.Kind() == reflect.Bool
+	case TypeList:
+		return v.Kind() == reflect.Slice || v.Kind() == reflect.Array
+	default:
+		return true
+	}
+}
+
+// zero returns the value an optional variable without a default takes
+func zero(varType VarType) interface{} {
+	switch varType {
+	case TypeInt:
+		return 0
+	case TypeFloat:
+		return 0.0
+	case TypeBool:
+		return false
+	case TypeList:
+		return []interface{}{}
+	case TypeAny:
+		return nil
+	default:
+		return ""
+	}
+}
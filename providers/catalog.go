@@ -0,0 +1,247 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// failedListingTTL is how long a Catalog waits before retrying a listing
+// that failed
+const failedListingTTL = time.Minute
+
+// availabilityTTL is how long a Catalog trusts a provider's IsAvailable
+// answer, which may cost a request to its server
+const availabilityTTL = 10 * time.Second
+
+// defaultModels are the preferred chat models of each provider, in order.
+// The first one is used when the provider's models cannot be listed.
+var defaultModels = map[string][]string{
+	"OpenAI":      {"gpt-4o-mini", "gpt-4o", "gpt-3.5-turbo"},
+	"DeepSeek":    {"deepseek-chat"},
+	"Gemini":      {"gemini-1.5-flash", "gemini-1.5-pro", "gemini-pro"},
+	"Ollama":      {"llama2"},
+	"HuggingFace": {"gpt2"},
+}
+
+// Catalog caches the models of a set of providers. Providers that are not
+// a ModelLister have no models listed, but can still be used with any
+// model name.
+type Catalog struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	providers map[string]Provider
+	order     []string
+	listings  map[string]*listing
+	available map[string]availability
+}
+
+// availability is a cached IsAvailable answer
+type availability struct {
+	ok      bool
+	checked time.Time
+}
+
+// listing is a cached provider listing
+type listing struct {
+	models  []Model
+	err     error
+	fetched time.Time
+}
+
+// NewCatalog creates a catalog that lists each provider's models again
+// once its listing is older than ttl
+func NewCatalog(ttl time.Duration) *Catalog {
+	return &Catalog{
+		ttl:       ttl,
+		providers: make(map[string]Provider),
+		listings:  make(map[string]*listing),
+		available: make(map[string]availability),
+	}
+}
+
+// Add adds a provider to the catalog under its name
+func (c *Catalog) Add(provider Provider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := provider.GetName()
+	if _, exists := c.providers[name]; !exists {
+		c.order = append(c.order, name)
+	}
+	c.providers[name] = provider
+	delete(c.listings, name)
+	delete(c.available, name)
+}
+
+// Providers returns the names of the providers in the order they were
+// added
+func (c *Catalog) Providers() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.order...)
+}
+
+// Provider returns a provider of the catalog by name, or nil
+func (c *Catalog) Provider(name string) Provider {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.providers[name]
+}
+
+// Available reports whether a provider is available, asking it at most
+// once every few seconds. Unknown providers are not available.
+func (c *Catalog) Available(provider string) bool {
+	c.mu.Lock()
+	p, exists := c.providers[provider]
+	cached, checked := c.available[provider]
+	c.mu.Unlock()
+
+	if !exists {
+		return false
+	}
+	if checked && time.Since(cached.checked) < availabilityTTL {
+		return cached.ok
+	}
+
+	ok := p.IsAvailable()
+	c.mu.Lock()
+	c.available[provider] = availability{ok: ok, checked: time.Now()}
+	c.mu.Unlock()
+	return ok
+}
+
+// Models returns the models of a provider, listing them if the cached
+// listing is missing or stale
+func (c *Catalog) Models(ctx context.Context, provider string) ([]Model, error) {
+	c.mu.Lock()
+	p, exists := c.providers[provider]
+	cached := c.listings[provider]
+	c.mu.Unlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unknown provider %q", provider)
+	}
+	if cached != nil && c.fresh(cached) {
+		return cached.models, cached.err
+	}
+
+	lister, ok := p.(ModelLister)
+	if !ok {
+		return nil, nil
+	}
+
+	// Listing is done without the lock, so concurrent misses may list the
+	// same provider twice
+	models, err := lister.Models(ctx)
+	for i := range models {
+		models[i].Provider = provider
+	}
+	if err != nil {
+		err = fmt.Errorf("listing %s models: %w", provider, err)
+	}
+
+	c.mu.Lock()
+	c.listings[provider] = &listing{models: models, err: err, fetched: time.Now()}
+	c.mu.Unlock()
+	return models, err
+}
+
+// fresh reports whether a cached listing can still be used
+func (c *Catalog) fresh(l *listing) bool {
+	ttl := c.ttl
+	if l.err != nil && ttl > failedListingTTL {
+		ttl = failedListingTTL
+	}
+	return time.Since(l.fetched) < ttl
+}
+
+// All returns the models of every provider, in the order the providers
+// were added, with the listing error of each provider that failed
+func (c *Catalog) All(ctx context.Context) ([]Model, map[string]error) {
+	var models []Model
+	errs := make(map[string]error)
+	for _, name := range c.Providers() {
+		listed, err := c.Models(ctx, name)
+		if err != nil {
+			errs[name] = err
+			continue
+		}
+		models = append(models, listed...)
+	}
+	return models, errs
+}
+
+// Find returns a model of a provider. It returns an error matching
+// ErrModelNotFound if the provider does not list the model, and the
+// listing error if its models cannot be listed.
+func (c *Catalog) Find(ctx context.Context, provider, id string) (Model, error) {
+	models, err := c.Models(ctx, provider)
+	if err != nil {
+		return Model{}, err
+	}
+	for _, model := range models {
+		// Ollama serves untagged names as their latest tag
+		if model.ID == id || model.ID == id+":latest" {
+			return model, nil
+		}
+	}
+
+	c.mu.Lock()
+	_, lists := c.providers[provider].(ModelLister)
+	c.mu.Unlock()
+	if !lists {
+		return describeModel(provider, id), nil
+	}
+	return Model{}, fmt.Errorf("%w: %s does not serve %q", ErrModelNotFound, provider, id)
+}
+
+// DefaultModel returns the chat model to use for a provider when none is
+// chosen: its first preferred model that is listed, or else its first
+// listed chat model. If the models cannot be listed, it returns the first
+// preferred model.
+func (c *Catalog) DefaultModel(ctx context.Context, provider string) string {
+	preferred := defaultModels[provider]
+	fallback := ""
+	if len(preferred) > 0 {
+		fallback = preferred[0]
+	}
+
+	models, err := c.Models(ctx, provider)
+	if err != nil || len(models) == 0 {
+		return fallback
+	}
+
+	listed := make(map[string]bool, len(models))
+	for _, model := range models {
+		listed[model.ID] = true
+	}
+	for _, id := range preferred {
+		if listed[id] {
+			return id
+		}
+	}
+	for _, model := range models {
+		if model.Chat() {
+			return model.ID
+		}
+	}
+	return fallback
+}
+
+// Refresh drops the cached listing and availability of a provider, or of
+// every provider if the name is empty, so the next lookup asks again
+func (c *Catalog) Refresh(provider string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if provider == "" {
+		c.listings = make(map[string]*listing)
+		c.available = make(map[string]availability)
+		return
+	}
+	delete(c.listings, provider)
+	delete(c.available, provider)
+}
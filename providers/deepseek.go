@@ -1,14 +1,13 @@
 package providers
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 
 	"github.com/aldotobing/neurogo/config"
 )
@@ -125,6 +124,18 @@ func (d *DeepSeek) Complete(prompt string, options config.CompletionOptions) (st
 
 // Stream streams a completion from DeepSeek
 func (d *DeepSeek) Stream(prompt string, options config.CompletionOptions, callback func(chunk string)) error {
+	return StreamCallback(d.StreamEvents(context.Background(), prompt, options), callback)
+}
+
+// StreamEvents streams a completion from DeepSeek as typed events
+func (d *DeepSeek) StreamEvents(ctx context.Context, prompt string, options config.CompletionOptions) *EventStream {
+	return NewEventStream(ctx, func(ctx context.Context, emit func(StreamEvent) bool) error {
+		return d.stream(ctx, prompt, options, emit)
+	})
+}
+
+// stream sends the streaming request and emits the events of the response
+func (d *DeepSeek) stream(ctx context.Context, prompt string, options config.CompletionOptions, emit func(StreamEvent) bool) error {
 	if !d.IsAvailable() {
 		return errors.New("DeepSeek API key is required")
 	}
@@ -155,7 +166,7 @@ func (d *DeepSeek) Stream(prompt string, options config.CompletionOptions, callb
 		return err
 	}
 
-	req, err := http.NewRequest("POST", "https://api.deepseek.com/v1/chat/completions", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.deepseek.com/v1/chat/completions", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return err
 	}
@@ -175,38 +186,7 @@ func (d *DeepSeek) Stream(prompt string, options config.CompletionOptions, callb
 	}
 
 	// Process the streaming response
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "data: ") {
-			data := strings.TrimPrefix(line, "data: ")
-			if data == "[DONE]" {
-				break
-			}
-
-			var chunk struct {
-				Choices []struct {
-					Delta struct {
-						Content string `json:"content"`
-					} `json:"delta"`
-				} `json:"choices"`
-			}
-
-			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
-				continue
-			}
-
-			if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
-				callback(chunk.Choices[0].Delta.Content)
-			}
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return err
-	}
-
-	return nil
+	return decodeChatStream(ctx, "DeepSeek", resp.Body, emit)
 }
 
 // IsAvailable checks if the DeepSeek provider is properly configured
@@ -0,0 +1,201 @@
+package providers
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/aldotobing/neurogo/config"
+)
+
+// EventType identifies the kind of a StreamEvent
+type EventType string
+
+// Event types of a completion stream. Every stream ends with exactly one
+// EventDone or EventError.
+const (
+	EventText     EventType = "text"
+	EventToolCall EventType = "tool_call"
+	EventUsage    EventType = "usage"
+	EventDone     EventType = "done"
+	EventError    EventType = "error"
+)
+
+// StreamEvent is one event of a completion stream
+type StreamEvent struct {
+	Type EventType
+
+	// Text is the content delta of an EventText
+	Text string
+
+	// ToolCall is the delta of an EventToolCall
+	ToolCall *ToolCallDelta
+
+	// Usage is the token usage of an EventUsage
+	Usage *Usage
+
+	// FinishReason is why the model stopped, if the provider reports it. It
+	// is set on the EventDone.
+	FinishReason string
+
+	// Err is the error of an EventError
+	Err error
+}
+
+// ToolCallDelta is part of a tool call streamed by the model. Deltas with
+// the same Index belong to one call; the ID and name come first and the
+// arguments arrive in pieces.
+type ToolCallDelta struct {
+	Index     int
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// Usage is the token usage of a completion
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+}
+
+// Streamer is implemented by providers that stream typed events and can
+// abort the upstream request. Use OpenStream to stream from any Provider.
+type Streamer interface {
+	// StreamEvents starts streaming a completion. Canceling ctx or closing
+	// the stream aborts the request.
+	StreamEvents(ctx context.Context, prompt string, options config.CompletionOptions) *EventStream
+}
+
+// EventStream delivers the events of a completion stream. Events are
+// produced as they are read, so a slow reader slows down the upstream
+// request. Read with Next or range over Events, and always Close the stream
+// when done, even after reading it to the end.
+type EventStream struct {
+	events chan StreamEvent
+	cancel context.CancelFunc
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewEventStream runs produce in a goroutine and delivers the events it
+// emits. emit blocks until the event is read and returns false once the
+// stream is closed, after which produce should return ctx.Err(). The stream
+// ends with an EventError if produce returns an error, or else with an
+// EventDone, which produce may emit itself to report a finish reason.
+func NewEventStream(ctx context.Context, produce func(ctx context.Context, emit func(StreamEvent) bool) error) *EventStream {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &EventStream{events: make(chan StreamEvent), cancel: cancel}
+
+	go func() {
+		defer close(s.events)
+		defer cancel()
+
+		done := false
+		emit := func(event StreamEvent) bool {
+			if done {
+				return false
+			}
+			select {
+			case s.events <- event:
+				done = event.Type == EventDone
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		err := produce(ctx, emit)
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+		switch {
+		case err != nil:
+			emit(StreamEvent{Type: EventError, Err: err})
+		case !done:
+			emit(StreamEvent{Type: EventDone})
+		}
+	}()
+	return s
+}
+
+// Events returns the channel events are delivered on. It is closed after
+// the EventDone or EventError, or when the stream is closed.
+func (s *EventStream) Events() <-chan StreamEvent {
+	return s.events
+}
+
+// Next returns the next event. It returns false once the stream has ended.
+func (s *EventStream) Next() (StreamEvent, bool) {
+	event, ok := <-s.events
+	return event, ok
+}
+
+// Err returns the error the stream ended with, or nil. A stream closed
+// before its end reports context.Canceled.
+func (s *EventStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close stops the stream and aborts the upstream request. It is safe to
+// call more than once.
+func (s *EventStream) Close() error {
+	s.cancel()
+	return nil
+}
+
+// Text reads the rest of the stream and returns its text, closing it
+func (s *EventStream) Text() (string, error) {
+	defer s.Close()
+
+	var b strings.Builder
+	for event := range s.events {
+		switch event.Type {
+		case EventText:
+			b.WriteString(event.Text)
+		case EventError:
+			return b.String(), event.Err
+		}
+	}
+	return b.String(), nil
+}
+
+// OpenStream streams a completion from any provider: with StreamEvents if
+// it is a Streamer, or by adapting its callback based Stream otherwise.
+// Adapted streams only carry text, and closing them stops delivery but
+// cannot abort the upstream request.
+func OpenStream(ctx context.Context, provider Provider, prompt string, options config.CompletionOptions) *EventStream {
+	if streamer, ok := provider.(Streamer); ok {
+		return streamer.StreamEvents(ctx, prompt, options)
+	}
+
+	return NewEventStream(ctx, func(ctx context.Context, emit func(StreamEvent) bool) error {
+		err := provider.Stream(prompt, options, func(chunk string) {
+			emit(StreamEvent{Type: EventText, Text: chunk})
+		})
+		if err == nil {
+			err = ctx.Err()
+		}
+		return err
+	})
+}
+
+// StreamCallback reads a stream and passes its text to callback, returning
+// the error it ended with. Providers implement Stream with it on top of
+// StreamEvents.
+func StreamCallback(stream *EventStream, callback func(chunk string)) error {
+	defer stream.Close()
+
+	for event := range stream.Events() {
+		switch event.Type {
+		case EventText:
+			callback(event.Text)
+		case EventError:
+			return event.Err
+		}
+	}
+	return stream.Err()
+}
@@ -1,14 +1,13 @@
 package providers
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 
 	"github.com/aldotobing/neurogo/config"
 )
@@ -137,6 +136,18 @@ func (g *Gemini) Complete(prompt string, options config.CompletionOptions) (stri
 
 // Stream streams a completion from Gemini
 func (g *Gemini) Stream(prompt string, options config.CompletionOptions, callback func(chunk string)) error {
+	return StreamCallback(g.StreamEvents(context.Background(), prompt, options), callback)
+}
+
+// StreamEvents streams a completion from Gemini as typed events
+func (g *Gemini) StreamEvents(ctx context.Context, prompt string, options config.CompletionOptions) *EventStream {
+	return NewEventStream(ctx, func(ctx context.Context, emit func(StreamEvent) bool) error {
+		return g.stream(ctx, prompt, options, emit)
+	})
+}
+
+// stream sends the streaming request and emits the events of the response
+func (g *Gemini) stream(ctx context.Context, prompt string, options config.CompletionOptions, emit func(StreamEvent) bool) error {
 	if g.apiKey == "" {
 		return errors.New("Gemini API key is required")
 	}
@@ -178,19 +189,14 @@ func (g *Gemini) Stream(prompt string, options config.CompletionOptions, callbac
 		return err
 	}
 
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:streamGenerateContent?key=%s", model, g.apiKey)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:streamGenerateContent?alt=sse&key=%s", model, g.apiKey)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	// Set stream parameter for streaming
-	q := req.URL.Query()
-	q.Add("alt", "sse")
-	req.URL.RawQuery = q.Encode()
-
 	resp, err := g.client.Do(req)
 	if err != nil {
 		return err
@@ -203,40 +209,137 @@ func (g *Gemini) Stream(prompt string, options config.CompletionOptions, callbac
 	}
 
 	// Process the streaming response
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "data: ") {
-			data := strings.TrimPrefix(line, "data: ")
-			if data == "[DONE]" {
-				break
-			}
+	finishReason := ""
+	err = DecodeSSE(resp.Body, func(event *Event) error {
+		var chunk struct {
+			Candidates []struct {
+				Content struct {
+					Parts []struct {
+						Text string `json:"text"`
+					} `json:"parts"`
+				} `json:"content"`
+				FinishReason string `json:"finishReason"`
+			} `json:"candidates"`
+			UsageMetadata *struct {
+				PromptTokenCount     int `json:"promptTokenCount"`
+				CandidatesTokenCount int `json:"candidatesTokenCount"`
+				TotalTokenCount      int `json:"totalTokenCount"`
+			} `json:"usageMetadata"`
+			Error *struct {
+				Message string `json:"message"`
+				Status  string `json:"status"`
+			} `json:"error"`
+		}
 
-			var chunk struct {
-				Candidates []struct {
-					Content struct {
-						Parts []struct {
-							Text string `json:"text"`
-						} `json:"parts"`
-					} `json:"content"`
-				} `json:"candidates"`
-			}
+		if err := decodeChunk("Gemini", []byte(event.Data), &chunk); err != nil {
+			return err
+		}
+		if chunk.Error != nil {
+			return &StreamError{Provider: "Gemini", Code: chunk.Error.Status, Message: chunk.Error.Message}
+		}
 
-			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
-				continue
+		if len(chunk.Candidates) > 0 {
+			for _, part := range chunk.Candidates[0].Content.Parts {
+				if part.Text != "" && !emit(StreamEvent{Type: EventText, Text: part.Text}) {
+					return ctx.Err()
+				}
 			}
+			if chunk.Candidates[0].FinishReason != "" {
+				finishReason = chunk.Candidates[0].FinishReason
+			}
+		}
 
-			if len(chunk.Candidates) > 0 && len(chunk.Candidates[0].Content.Parts) > 0 {
-				callback(chunk.Candidates[0].Content.Parts[0].Text)
+		// Every chunk carries the usage so far; only the last one counts
+		if chunk.UsageMetadata != nil && finishReason != "" {
+			usage := &Usage{
+				PromptTokens:     chunk.UsageMetadata.PromptTokenCount,
+				CompletionTokens: chunk.UsageMetadata.CandidatesTokenCount,
+				TotalTokens:      chunk.UsageMetadata.TotalTokenCount,
+			}
+			if !emit(StreamEvent{Type: EventUsage, Usage: usage}) {
+				return ctx.Err()
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
+	emit(StreamEvent{Type: EventDone, FinishReason: finishReason})
+	return nil
+}
 
-	if err := scanner.Err(); err != nil {
-		return err
+// Embed returns embedding vectors for the texts using Gemini's batch
+// embedding API
+func (g *Gemini) Embed(texts []string, model string) ([][]float32, error) {
+	if g.apiKey == "" {
+		return nil, errors.New("Gemini API key is required")
 	}
 
-	return nil
+	if model == "" {
+		model = "text-embedding-004"
+	}
+
+	requests := make([]map[string]interface{}, len(texts))
+	for i, text := range texts {
+		requests[i] = map[string]interface{}{
+			"model":   "models/" + model,
+			"content": GeminiContent{Parts: []GeminiPart{{Text: text}}},
+		}
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"requests": requests,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:batchEmbedContents?key=%s", model, g.apiKey)
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var embedResp struct {
+		Embeddings []struct {
+			Values []float32 `json:"values"`
+		} `json:"embeddings"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &embedResp); err != nil {
+		return nil, err
+	}
+
+	if embedResp.Error.Message != "" {
+		return nil, errors.New(embedResp.Error.Message)
+	}
+
+	if len(embedResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(embedResp.Embeddings))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for i, embedding := range embedResp.Embeddings {
+		vectors[i] = embedding.Values
+	}
+
+	return vectors, nil
 }
 
 // IsAvailable checks if the Gemini provider is properly configured
@@ -112,6 +112,59 @@ func (h *HuggingFace) Stream(prompt string, options config.CompletionOptions, ca
 	return errors.New("streaming not supported for HuggingFace provider")
 }
 
+// Embed returns embedding vectors for the texts using the HuggingFace
+// feature extraction pipeline
+func (h *HuggingFace) Embed(texts []string, model string) ([][]float32, error) {
+	if h.apiKey == "" {
+		return nil, errors.New("HuggingFace API key is required")
+	}
+
+	if model == "" {
+		model = "sentence-transformers/all-MiniLM-L6-v2"
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"inputs": texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", "https://api-inference.huggingface.co/pipeline/feature-extraction/"+model, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+h.apiKey)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("HuggingFace API error: " + string(body))
+	}
+
+	var vectors [][]float32
+	if err := json.Unmarshal(body, &vectors); err != nil {
+		return nil, errors.New("failed to parse HuggingFace embeddings: " + string(body))
+	}
+
+	if len(vectors) != len(texts) {
+		return nil, errors.New("unexpected number of embeddings from HuggingFace")
+	}
+
+	return vectors, nil
+}
+
 // IsAvailable checks if the HuggingFace provider is properly configured
 func (h *HuggingFace) IsAvailable() bool {
 	return h.apiKey != ""
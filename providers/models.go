@@ -0,0 +1,295 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Model describes a model a provider serves
+type Model struct {
+	// ID is the name the provider's API expects in requests
+	ID string `json:"id"`
+
+	// Provider is the name of the provider serving the model
+	Provider string `json:"provider"`
+
+	// Name is a display name, if the provider reports one
+	Name string `json:"name,omitempty"`
+
+	// ContextWindow is the number of input tokens the model accepts, or 0
+	// if it is unknown
+	ContextWindow int `json:"context_window,omitempty"`
+
+	// Local reports whether the model runs on this machine
+	Local bool `json:"local,omitempty"`
+
+	// CostPer1K is the list price in US dollars of 1K tokens, averaging
+	// input and output prices. It is 0 for local models and for models
+	// whose price is unknown.
+	CostPer1K float64 `json:"cost_per_1k,omitempty"`
+
+	Capabilities Capabilities `json:"capabilities"`
+}
+
+// Capabilities are the features a model supports
+type Capabilities struct {
+	Streaming  bool `json:"streaming"`
+	Tools      bool `json:"tools"`
+	JSONMode   bool `json:"json_mode"`
+	Vision     bool `json:"vision"`
+	Embeddings bool `json:"embeddings"`
+}
+
+// Chat reports whether the model generates text, as opposed to only
+// producing embeddings
+func (m Model) Chat() bool {
+	return !m.Capabilities.Embeddings
+}
+
+// knownModel is what is known about a model family whose API does not
+// report capabilities or prices
+type knownModel struct {
+	prefix        string
+	contextWindow int
+	capabilities  Capabilities
+	costPer1K     float64
+}
+
+var (
+	chatModel      = Capabilities{Streaming: true}
+	jsonChatModel  = Capabilities{Streaming: true, JSONMode: true}
+	toolModel      = Capabilities{Streaming: true, Tools: true}
+	jsonToolModel  = Capabilities{Streaming: true, Tools: true, JSONMode: true}
+	multimodal     = Capabilities{Streaming: true, Tools: true, JSONMode: true, Vision: true}
+	embeddingModel = Capabilities{Embeddings: true}
+)
+
+// knownModels lists the model families of the hosted providers. IDs match
+// the entry with the longest prefix. Prices are list prices and change
+// over time; they are meant for ranking models, not for billing.
+var knownModels = []knownModel{
+	{"gpt-4.1", 1047576, multimodal, 0.005},
+	{"gpt-4.1-mini", 1047576, multimodal, 0.001},
+	{"gpt-4o", 128000, multimodal, 0.00625},
+	{"gpt-4o-mini", 128000, multimodal, 0.000375},
+	{"gpt-4-turbo", 128000, multimodal, 0.02},
+	{"gpt-4", 8192, toolModel, 0.045},
+	{"gpt-3.5-turbo", 16385, jsonToolModel, 0.001},
+	{"chatgpt-4o", 128000, Capabilities{Streaming: true, Vision: true}, 0.01},
+	{"o1", 200000, multimodal, 0.0375},
+	{"o3", 200000, multimodal, 0.005},
+	{"o4-mini", 200000, multimodal, 0.00275},
+	{"text-embedding-3-small", 8191, embeddingModel, 0.00002},
+	{"text-embedding-3-large", 8191, embeddingModel, 0.00013},
+	{"text-embedding-ada-002", 8191, embeddingModel, 0.0001},
+	{"deepseek-chat", 64000, jsonToolModel, 0.000685},
+	{"deepseek-reasoner", 64000, chatModel, 0.00137},
+	{"gemini-1.0-pro", 30720, toolModel, 0.001},
+	{"gemini-pro", 30720, toolModel, 0.001},
+	{"gemini-1.5-flash", 1048576, multimodal, 0.0001875},
+	{"gemini-1.5-pro", 2097152, multimodal, 0.003125},
+	{"gemini-2", 1048576, multimodal, 0.00025},
+	{"text-embedding-004", 2048, embeddingModel, 0},
+	{"embedding-001", 2048, embeddingModel, 0},
+	{"gpt2", 1024, Capabilities{}, 0},
+	{"sentence-transformers/", 512, embeddingModel, 0},
+}
+
+// lookupModel returns what is known about a model ID
+func lookupModel(id string) (knownModel, bool) {
+	var best knownModel
+	found := false
+	for _, known := range knownModels {
+		if strings.HasPrefix(id, known.prefix) && len(known.prefix) > len(best.prefix) {
+			best, found = known, true
+		}
+	}
+	return best, found
+}
+
+// describeModel fills in a model's context window and capabilities from
+// knownModels
+func describeModel(provider, id string) Model {
+	model := Model{ID: id, Provider: provider, Capabilities: chatModel}
+	if known, ok := lookupModel(id); ok {
+		model.ContextWindow = known.contextWindow
+		model.Capabilities = known.capabilities
+		model.CostPer1K = known.costPer1K
+	}
+	return model
+}
+
+// openAINonChat marks the OpenAI model variants that do not take chat
+// completion requests
+var openAINonChat = []string{"audio", "realtime", "transcribe", "tts", "search", "image"}
+
+// Models lists the chat and embedding models of the account. Models of
+// unknown families, such as image and speech models, are left out.
+func (o *OpenAI) Models(ctx context.Context) ([]Model, error) {
+	ids, err := listOpenAIModels(ctx, o.client, "OpenAI", "https://api.openai.com/v1/models", o.apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var models []Model
+	for _, id := range ids {
+		if _, ok := lookupModel(id); !ok || containsAny(id, openAINonChat) {
+			continue
+		}
+		models = append(models, describeModel("OpenAI", id))
+	}
+	return models, nil
+}
+
+// Models lists the DeepSeek models
+func (d *DeepSeek) Models(ctx context.Context) ([]Model, error) {
+	ids, err := listOpenAIModels(ctx, d.client, "DeepSeek", "https://api.deepseek.com/models", d.apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	models := make([]Model, len(ids))
+	for i, id := range ids {
+		models[i] = describeModel("DeepSeek", id)
+	}
+	return models, nil
+}
+
+// Models lists the Gemini models that generate content or embeddings,
+// with the input token limit Gemini reports for each
+func (g *Gemini) Models(ctx context.Context) ([]Model, error) {
+	if g.apiKey == "" {
+		return nil, fmt.Errorf("Gemini API key is required")
+	}
+
+	var models []Model
+	pageToken := ""
+	for {
+		endpoint := "https://generativelanguage.googleapis.com/v1beta/models?pageSize=1000&key=" + url.QueryEscape(g.apiKey)
+		if pageToken != "" {
+			endpoint += "&pageToken=" + url.QueryEscape(pageToken)
+		}
+
+		var page struct {
+			Models []struct {
+				Name                       string   `json:"name"`
+				DisplayName                string   `json:"displayName"`
+				InputTokenLimit            int      `json:"inputTokenLimit"`
+				SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+			} `json:"models"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := getJSON(ctx, g.client, "Gemini", endpoint, "", &page); err != nil {
+			return nil, err
+		}
+
+		for _, item := range page.Models {
+			id := strings.TrimPrefix(item.Name, "models/")
+			model := describeModel("Gemini", id)
+			model.Name = item.DisplayName
+			if item.InputTokenLimit > 0 {
+				model.ContextWindow = item.InputTokenLimit
+			}
+
+			generates, embeds := false, false
+			for _, method := range item.SupportedGenerationMethods {
+				switch method {
+				case "generateContent":
+					generates = true
+				case "embedContent":
+					embeds = true
+				}
+			}
+			if !generates && !embeds {
+				continue
+			}
+			model.Capabilities.Streaming = generates
+			model.Capabilities.Embeddings = embeds && !generates
+			models = append(models, model)
+		}
+
+		if page.NextPageToken == "" {
+			return models, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// listOpenAIModels returns the model IDs of an OpenAI compatible /models
+// endpoint
+func listOpenAIModels(ctx context.Context, client *http.Client, provider, endpoint, apiKey string) ([]string, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("%s API key is required", provider)
+	}
+
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, client, provider, endpoint, apiKey, &list); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(list.Data))
+	for i, item := range list.Data {
+		ids[i] = item.ID
+	}
+	return ids, nil
+}
+
+// getJSON sends a GET request, with a bearer token if apiKey is set, and
+// decodes the response into out
+func getJSON(ctx context.Context, client *http.Client, provider, endpoint, apiKey string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		message := truncate(string(body), 200)
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+			message = apiErr.Error.Message
+		}
+		return fmt.Errorf("%s API error: %s", provider, message)
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", provider, err)
+	}
+	return nil
+}
+
+// containsAny reports whether s contains any of the substrings
+func containsAny(s string, substrings []string) bool {
+	for _, sub := range substrings {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
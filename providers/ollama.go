@@ -2,8 +2,10 @@ package providers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 
@@ -95,6 +97,18 @@ func (o *Ollama) Complete(prompt string, options config.CompletionOptions) (stri
 
 // Stream streams a completion from Ollama
 func (o *Ollama) Stream(prompt string, options config.CompletionOptions, callback func(chunk string)) error {
+	return StreamCallback(o.StreamEvents(context.Background(), prompt, options), callback)
+}
+
+// StreamEvents streams a completion from Ollama as typed events
+func (o *Ollama) StreamEvents(ctx context.Context, prompt string, options config.CompletionOptions) *EventStream {
+	return NewEventStream(ctx, func(ctx context.Context, emit func(StreamEvent) bool) error {
+		return o.stream(ctx, prompt, options, emit)
+	})
+}
+
+// stream sends the streaming request and emits the events of the response
+func (o *Ollama) stream(ctx context.Context, prompt string, options config.CompletionOptions, emit func(StreamEvent) bool) error {
 	model := "llama2"
 	if options.Model != "" {
 		model = options.Model
@@ -113,7 +127,7 @@ func (o *Ollama) Stream(prompt string, options config.CompletionOptions, callbac
 		return err
 	}
 
-	req, err := http.NewRequest("POST", o.host+"/api/generate", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", o.host+"/api/generate", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return err
 	}
@@ -126,26 +140,104 @@ func (o *Ollama) Stream(prompt string, options config.CompletionOptions, callbac
 	}
 	defer resp.Body.Close()
 
-	decoder := json.NewDecoder(resp.Body)
-	for {
-		var streamResp OllamaResponse
-		if err := decoder.Decode(&streamResp); err != nil {
-			if err == io.EOF {
-				break
-			}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("Ollama API error: %s", string(body))
+	}
+
+	finishReason := ""
+	err = DecodeNDJSON(resp.Body, func(line []byte) error {
+		var streamResp struct {
+			OllamaResponse
+			Done            bool   `json:"done"`
+			DoneReason      string `json:"done_reason"`
+			PromptEvalCount int    `json:"prompt_eval_count"`
+			EvalCount       int    `json:"eval_count"`
+		}
+		if err := decodeChunk("Ollama", line, &streamResp); err != nil {
 			return err
 		}
 
 		if streamResp.Error != "" {
-			return errors.New(streamResp.Error)
+			return &StreamError{Provider: "Ollama", Message: streamResp.Error}
 		}
 
-		callback(streamResp.Response)
-	}
+		if streamResp.Response != "" && !emit(StreamEvent{Type: EventText, Text: streamResp.Response}) {
+			return ctx.Err()
+		}
 
+		// The last chunk reports the token counts
+		if streamResp.Done {
+			finishReason = streamResp.DoneReason
+			usage := &Usage{
+				PromptTokens:     streamResp.PromptEvalCount,
+				CompletionTokens: streamResp.EvalCount,
+				TotalTokens:      streamResp.PromptEvalCount + streamResp.EvalCount,
+			}
+			if !emit(StreamEvent{Type: EventUsage, Usage: usage}) {
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	emit(StreamEvent{Type: EventDone, FinishReason: finishReason})
 	return nil
 }
 
+// Embed returns embedding vectors for the texts using a local Ollama model
+func (o *Ollama) Embed(texts []string, model string) ([][]float32, error) {
+	if model == "" {
+		model = "nomic-embed-text"
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"model": model,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", o.host+"/api/embed", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var embedResp struct {
+		Embeddings [][]float32 `json:"embeddings"`
+		Error      string      `json:"error,omitempty"`
+	}
+	if err := json.Unmarshal(body, &embedResp); err != nil {
+		return nil, err
+	}
+
+	if embedResp.Error != "" {
+		return nil, errors.New(embedResp.Error)
+	}
+
+	if len(embedResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(embedResp.Embeddings))
+	}
+
+	return embedResp.Embeddings, nil
+}
+
 // IsAvailable checks if the Ollama provider is properly configured
 func (o *Ollama) IsAvailable() bool {
 	// Try to ping Ollama to see if it's running
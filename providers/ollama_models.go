@@ -0,0 +1,245 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrModelNotFound is returned when Ollama does not have the requested
+// model locally
+var ErrModelNotFound = errors.New("model not found")
+
+// OllamaModel is a model installed in Ollama
+type OllamaModel struct {
+	Name       string             `json:"name"`
+	Size       int64              `json:"size"`
+	Digest     string             `json:"digest"`
+	ModifiedAt time.Time          `json:"modified_at"`
+	Details    OllamaModelDetails `json:"details"`
+}
+
+// OllamaModelDetails describes a model's architecture and quantization
+type OllamaModelDetails struct {
+	Format            string   `json:"format,omitempty"`
+	Family            string   `json:"family,omitempty"`
+	Families          []string `json:"families,omitempty"`
+	ParameterSize     string   `json:"parameter_size,omitempty"`
+	QuantizationLevel string   `json:"quantization_level,omitempty"`
+}
+
+// OllamaModelInfo is the full description of an installed model
+type OllamaModelInfo struct {
+	License      string                 `json:"license,omitempty"`
+	Modelfile    string                 `json:"modelfile,omitempty"`
+	Parameters   string                 `json:"parameters,omitempty"`
+	Template     string                 `json:"template,omitempty"`
+	Details      OllamaModelDetails     `json:"details"`
+	ModelInfo    map[string]interface{} `json:"model_info,omitempty"`
+	Capabilities []string               `json:"capabilities,omitempty"`
+	ModifiedAt   time.Time              `json:"modified_at"`
+}
+
+// PullProgress reports the progress of a model download. Total and
+// Completed are in bytes and only set while a layer is downloading.
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
+
+// Percent returns how much of the current layer has been downloaded, from
+// 0 to 100, or -1 if the status has no size
+func (p PullProgress) Percent() float64 {
+	if p.Total <= 0 {
+		return -1
+	}
+	return float64(p.Completed) / float64(p.Total) * 100
+}
+
+// ListModels returns the models installed in Ollama
+func (o *Ollama) ListModels(ctx context.Context) ([]OllamaModel, error) {
+	var tags struct {
+		Models []OllamaModel `json:"models"`
+	}
+	if err := o.call(ctx, "GET", "/api/tags", nil, &tags); err != nil {
+		return nil, err
+	}
+	return tags.Models, nil
+}
+
+// ShowModel returns the details, parameters and template of an installed
+// model
+func (o *Ollama) ShowModel(ctx context.Context, name string) (*OllamaModelInfo, error) {
+	var info OllamaModelInfo
+	if err := o.call(ctx, "POST", "/api/show", map[string]string{"model": name}, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// DeleteModel removes an installed model
+func (o *Ollama) DeleteModel(ctx context.Context, name string) error {
+	return o.call(ctx, "DELETE", "/api/delete", map[string]string{"model": name}, nil)
+}
+
+// PullModel downloads a model from the Ollama library, passing every
+// progress update to progress. It returns when the download finishes,
+// fails or ctx is canceled.
+func (o *Ollama) PullModel(ctx context.Context, name string, progress func(PullProgress)) error {
+	resp, err := o.send(ctx, "POST", "/api/pull", map[string]interface{}{"model": name, "stream": true})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return DecodeNDJSON(resp.Body, func(line []byte) error {
+		var update struct {
+			PullProgress
+			Error string `json:"error"`
+		}
+		if err := decodeChunk("Ollama", line, &update); err != nil {
+			return err
+		}
+		if update.Error != "" {
+			return &StreamError{Provider: "Ollama", Message: update.Error}
+		}
+		if progress != nil {
+			progress(update.PullProgress)
+		}
+		return nil
+	})
+}
+
+// Models lists the installed models. Capabilities and context lengths
+// come from each model's details; older Ollama versions that do not report
+// capabilities get them guessed from the model name.
+func (o *Ollama) Models(ctx context.Context) ([]Model, error) {
+	installed, err := o.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	models := make([]Model, len(installed))
+	for i, item := range installed {
+		model := Model{ID: item.Name, Provider: "Ollama", Local: true, Capabilities: jsonChatModel}
+		if strings.Contains(item.Name, "embed") || strings.Contains(item.Details.Family, "bert") {
+			model.Capabilities = embeddingModel
+		}
+
+		if info, err := o.ShowModel(ctx, item.Name); err == nil {
+			if len(info.Capabilities) > 0 {
+				model.Capabilities = ollamaCapabilities(info.Capabilities)
+			}
+			for key, value := range info.ModelInfo {
+				if length, ok := value.(float64); ok && strings.HasSuffix(key, ".context_length") {
+					model.ContextWindow = int(length)
+				}
+			}
+		}
+		models[i] = model
+	}
+	return models, nil
+}
+
+// ollamaCapabilities converts the capabilities Ollama reports for a model
+func ollamaCapabilities(names []string) Capabilities {
+	var capabilities Capabilities
+	for _, name := range names {
+		switch name {
+		case "completion":
+			// Every completion model can be asked for JSON output
+			capabilities.Streaming = true
+			capabilities.JSONMode = true
+		case "tools":
+			capabilities.Tools = true
+		case "vision":
+			capabilities.Vision = true
+		case "embedding":
+			capabilities.Embeddings = true
+		}
+	}
+	return capabilities
+}
+
+// call sends a JSON request to the Ollama API and decodes the response
+// into out, if it is not nil
+func (o *Ollama) call(ctx context.Context, method, path string, body, out interface{}) error {
+	resp, err := o.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse Ollama response: %w", err)
+	}
+	return nil
+}
+
+// send sends a JSON request to the Ollama API and checks the status. The
+// caller closes the response body.
+func (o *Ollama) send(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, o.host+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	message := string(data)
+	if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+		message = apiErr.Error
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &ollamaNotFound{message: message}
+	}
+	return nil, fmt.Errorf("Ollama API error: %s", message)
+}
+
+// ollamaNotFound is Ollama's error for a missing model. It matches
+// ErrModelNotFound but keeps Ollama's message.
+type ollamaNotFound struct {
+	message string
+}
+
+func (e *ollamaNotFound) Error() string {
+	return "Ollama API error: " + e.message
+}
+
+func (e *ollamaNotFound) Is(target error) bool {
+	return target == ErrModelNotFound
+}
@@ -1,14 +1,13 @@
 package providers
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 
 	"github.com/aldotobing/neurogo/config"
 )
@@ -122,6 +121,18 @@ func (o *OpenAI) Complete(prompt string, options config.CompletionOptions) (stri
 
 // Stream streams a completion from OpenAI
 func (o *OpenAI) Stream(prompt string, options config.CompletionOptions, callback func(chunk string)) error {
+	return StreamCallback(o.StreamEvents(context.Background(), prompt, options), callback)
+}
+
+// StreamEvents streams a completion from OpenAI as typed events
+func (o *OpenAI) StreamEvents(ctx context.Context, prompt string, options config.CompletionOptions) *EventStream {
+	return NewEventStream(ctx, func(ctx context.Context, emit func(StreamEvent) bool) error {
+		return o.stream(ctx, prompt, options, emit)
+	})
+}
+
+// stream sends the streaming request and emits the events of the response
+func (o *OpenAI) stream(ctx context.Context, prompt string, options config.CompletionOptions, emit func(StreamEvent) bool) error {
 	if o.apiKey == "" {
 		return errors.New("OpenAI API key is required")
 	}
@@ -145,6 +156,9 @@ func (o *OpenAI) Stream(prompt string, options config.CompletionOptions, callbac
 		"temperature": options.Temperature,
 		"max_tokens":  options.MaxTokens,
 		"stream":      true,
+
+		// Ask for a final chunk with the token usage
+		"stream_options": map[string]bool{"include_usage": true},
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -152,7 +166,7 @@ func (o *OpenAI) Stream(prompt string, options config.CompletionOptions, callbac
 		return err
 	}
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return err
 	}
@@ -172,38 +186,76 @@ func (o *OpenAI) Stream(prompt string, options config.CompletionOptions, callbac
 	}
 
 	// Process the streaming response
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "data: ") {
-			data := strings.TrimPrefix(line, "data: ")
-			if data == "[DONE]" {
-				break
-			}
-
-			var chunk struct {
-				Choices []struct {
-					Delta struct {
-						Content string `json:"content"`
-					} `json:"delta"`
-				} `json:"choices"`
-			}
-
-			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
-				continue
-			}
-
-			if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
-				callback(chunk.Choices[0].Delta.Content)
-			}
-		}
+	return decodeChatStream(ctx, "OpenAI", resp.Body, emit)
+}
+
+// Embed returns embedding vectors for the texts using OpenAI's embeddings API
+func (o *OpenAI) Embed(texts []string, model string) ([][]float32, error) {
+	if o.apiKey == "" {
+		return nil, errors.New("OpenAI API key is required")
 	}
 
-	if err := scanner.Err(); err != nil {
-		return err
+	if model == "" {
+		model = "text-embedding-3-small"
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"model": model,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", "https://api.openai.com/v1/embeddings", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", o.apiKey))
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var embeddingResp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &embeddingResp); err != nil {
+		return nil, err
+	}
+
+	if embeddingResp.Error.Message != "" {
+		return nil, errors.New(embeddingResp.Error.Message)
+	}
+
+	if len(embeddingResp.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(embeddingResp.Data))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range embeddingResp.Data {
+		if item.Index < 0 || item.Index >= len(vectors) {
+			return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
 	}
 
-	return nil
+	return vectors, nil
 }
 
 // IsAvailable checks if the OpenAI provider is properly configured
@@ -1,6 +1,8 @@
 package providers
 
 import (
+	"context"
+
 	"github.com/aldotobing/neurogo/config"
 )
 
@@ -18,3 +20,19 @@ type Provider interface {
 	// GetName returns the provider name
 	GetName() string
 }
+
+// Embedder is implemented by providers that can turn text into embedding
+// vectors. Use a type assertion to check whether a Provider supports it.
+type Embedder interface {
+	// Embed returns one vector per input text, in the same order. An empty
+	// model selects the provider's default embedding model.
+	Embed(texts []string, model string) ([][]float32, error)
+}
+
+// ModelLister is implemented by providers that can list the models they
+// serve. Use a Catalog to cache the listings of several providers.
+type ModelLister interface {
+	// Models returns the models the provider serves, with the
+	// capabilities known for each
+	Models(ctx context.Context) ([]Model, error)
+}
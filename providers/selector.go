@@ -0,0 +1,290 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrNoProvider is returned when no healthy provider has a model that
+// satisfies the requirements
+var ErrNoProvider = errors.New("no provider satisfies the requirements")
+
+// Requirements state what a request needs from a provider and model. The
+// zero value accepts any chat model.
+type Requirements struct {
+	Streaming bool `json:"streaming,omitempty"`
+	Tools     bool `json:"tools,omitempty"`
+	JSONMode  bool `json:"json_mode,omitempty"`
+	Vision    bool `json:"vision,omitempty"`
+
+	// MinContext is the smallest context window accepted, in tokens.
+	// Models whose context window is unknown are rejected when it is set.
+	MinContext int `json:"min_context,omitempty"`
+
+	// LocalOnly accepts only models that run on this machine
+	LocalOnly bool `json:"local_only,omitempty"`
+
+	// MaxCostPer1K is the highest price accepted, in US dollars per 1K
+	// tokens; see Model.CostPer1K. Hosted models with an unknown price are
+	// rejected when it is set.
+	MaxCostPer1K float64 `json:"max_cost_per_1k,omitempty"`
+
+	// Prefer lists provider names to try first, in order. Other providers
+	// are tried after them in the order they were added to the catalog.
+	Prefer []string `json:"prefer,omitempty"`
+}
+
+// Merge returns the requirements with those of other added: capabilities
+// either needs, the larger minimum context, the lower cost limit, and
+// other's preferred providers first
+func (r Requirements) Merge(other Requirements) Requirements {
+	merged := Requirements{
+		Streaming:    r.Streaming || other.Streaming,
+		Tools:        r.Tools || other.Tools,
+		JSONMode:     r.JSONMode || other.JSONMode,
+		Vision:       r.Vision || other.Vision,
+		MinContext:   r.MinContext,
+		LocalOnly:    r.LocalOnly || other.LocalOnly,
+		MaxCostPer1K: r.MaxCostPer1K,
+		Prefer:       append(append([]string(nil), other.Prefer...), r.Prefer...),
+	}
+	if other.MinContext > merged.MinContext {
+		merged.MinContext = other.MinContext
+	}
+	if other.MaxCostPer1K > 0 && (merged.MaxCostPer1K == 0 || other.MaxCostPer1K < merged.MaxCostPer1K) {
+		merged.MaxCostPer1K = other.MaxCostPer1K
+	}
+	return merged
+}
+
+// Check returns the reasons a model does not satisfy the requirements, or
+// nil if it does
+func (r Requirements) Check(model Model) []string {
+	var reasons []string
+	c := model.Capabilities
+	if !model.Chat() {
+		reasons = append(reasons, "only produces embeddings")
+	}
+	if r.Streaming && !c.Streaming {
+		reasons = append(reasons, "no streaming")
+	}
+	if r.Tools && !c.Tools {
+		reasons = append(reasons, "no tool calling")
+	}
+	if r.JSONMode && !c.JSONMode {
+		reasons = append(reasons, "no JSON mode")
+	}
+	if r.Vision && !c.Vision {
+		reasons = append(reasons, "no vision")
+	}
+	if r.MinContext > 0 {
+		switch {
+		case model.ContextWindow == 0:
+			reasons = append(reasons, "context window unknown")
+		case model.ContextWindow < r.MinContext:
+			reasons = append(reasons, fmt.Sprintf("context window %d < %d", model.ContextWindow, r.MinContext))
+		}
+	}
+	if r.LocalOnly && !model.Local {
+		reasons = append(reasons, "not local")
+	}
+	if r.MaxCostPer1K > 0 && !model.Local {
+		switch {
+		case model.CostPer1K == 0:
+			reasons = append(reasons, "price unknown")
+		case model.CostPer1K > r.MaxCostPer1K:
+			reasons = append(reasons, fmt.Sprintf("costs $%g/1K > $%g/1K", model.CostPer1K, r.MaxCostPer1K))
+		}
+	}
+	return reasons
+}
+
+// Rejection explains why a provider, or one of its models, was not
+// selected. Model is empty when the whole provider was rejected.
+type Rejection struct {
+	Provider string   `json:"provider"`
+	Model    string   `json:"model,omitempty"`
+	Reasons  []string `json:"reasons"`
+}
+
+func (r Rejection) String() string {
+	name := r.Provider
+	if r.Model != "" {
+		name += "/" + r.Model
+	}
+	return name + ": " + strings.Join(r.Reasons, ", ")
+}
+
+// Selection is the provider and model chosen for a request, with the
+// candidates rejected before it
+type Selection struct {
+	Provider Provider    `json:"-"`
+	Model    Model       `json:"model"`
+	Rejected []Rejection `json:"rejected,omitempty"`
+}
+
+// SelectionError reports that no candidate satisfied the requirements. It
+// matches ErrNoProvider.
+type SelectionError struct {
+	Requirements Requirements
+	Rejected     []Rejection
+}
+
+func (e *SelectionError) Error() string {
+	if len(e.Rejected) == 0 {
+		return ErrNoProvider.Error() + ": no providers configured"
+	}
+
+	reasons := make([]string, 0, len(e.Rejected))
+	for i, rejection := range e.Rejected {
+		if i == 5 {
+			reasons = append(reasons, fmt.Sprintf("%d more", len(e.Rejected)-i))
+			break
+		}
+		reasons = append(reasons, rejection.String())
+	}
+	return ErrNoProvider.Error() + " (" + strings.Join(reasons, "; ") + ")"
+}
+
+func (e *SelectionError) Is(target error) bool {
+	return target == ErrNoProvider
+}
+
+// Selector picks the provider and model that best satisfy a request's
+// requirements, from the providers of a catalog
+type Selector struct {
+	catalog   *Catalog
+	preferred func(provider string) string
+}
+
+// SelectorOption configures a Selector
+type SelectorOption func(*Selector)
+
+// WithPreferredModel sets the model a provider is tried with first. By
+// default it is the catalog's default model.
+func WithPreferredModel(model func(provider string) string) SelectorOption {
+	return func(s *Selector) {
+		s.preferred = model
+	}
+}
+
+// NewSelector creates a selector choosing among the catalog's providers
+func NewSelector(catalog *Catalog, opts ...SelectorOption) *Selector {
+	s := &Selector{catalog: catalog}
+	s.preferred = func(provider string) string {
+		return catalog.DefaultModel(context.Background(), provider)
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Select returns the first provider and model that satisfy the
+// requirements. Providers are tried in the order of preference, skipping
+// unavailable ones and those whose models cannot be listed. Within a
+// provider its preferred model is tried first, then the cheapest models,
+// then those with the largest context window. If nothing is selected, the
+// error is a *SelectionError listing every rejection.
+func (s *Selector) Select(ctx context.Context, req Requirements) (*Selection, error) {
+	var rejected []Rejection
+	for _, name := range s.order(req.Prefer) {
+		provider := s.catalog.Provider(name)
+		if !s.catalog.Available(name) {
+			rejected = append(rejected, Rejection{Provider: name, Reasons: []string{"unavailable"}})
+			continue
+		}
+
+		candidates, err := s.candidates(ctx, name)
+		if err != nil {
+			rejected = append(rejected, Rejection{Provider: name, Reasons: []string{err.Error()}})
+			continue
+		}
+
+		for _, model := range candidates {
+			reasons := req.Check(model)
+			if len(reasons) == 0 {
+				return &Selection{Provider: provider, Model: model, Rejected: rejected}, nil
+			}
+			rejected = append(rejected, Rejection{Provider: name, Model: model.ID, Reasons: reasons})
+		}
+	}
+	return nil, &SelectionError{Requirements: req, Rejected: rejected}
+}
+
+// order returns the catalog's providers with the preferred ones first
+func (s *Selector) order(prefer []string) []string {
+	all := s.catalog.Providers()
+	ordered := make([]string, 0, len(all))
+	seen := make(map[string]bool, len(all))
+	for _, want := range prefer {
+		for _, name := range all {
+			if strings.EqualFold(name, want) && !seen[name] {
+				ordered = append(ordered, name)
+				seen[name] = true
+			}
+		}
+	}
+	for _, name := range all {
+		if !seen[name] {
+			ordered = append(ordered, name)
+		}
+	}
+	return ordered
+}
+
+// candidates returns the chat models of a provider in the order they are
+// tried. Providers without a listing are tried with their preferred model.
+func (s *Selector) candidates(ctx context.Context, provider string) ([]Model, error) {
+	models, err := s.catalog.Models(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	preferred := s.preferred(provider)
+	if len(models) == 0 {
+		if preferred == "" {
+			return nil, nil
+		}
+		model, err := s.catalog.Find(ctx, provider, preferred)
+		if err != nil {
+			return nil, err
+		}
+		return []Model{model}, nil
+	}
+
+	var candidates []Model
+	for _, model := range models {
+		if model.Chat() {
+			candidates = append(candidates, model)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if isModel(a, preferred) != isModel(b, preferred) {
+			return isModel(a, preferred)
+		}
+		if ca, cb := rankCost(a), rankCost(b); ca != cb {
+			return ca < cb
+		}
+		return a.ContextWindow > b.ContextWindow
+	})
+	return candidates, nil
+}
+
+// isModel reports whether a model has the ID, or is its latest tag
+func isModel(model Model, id string) bool {
+	return model.ID == id || model.ID == id+":latest"
+}
+
+// rankCost returns a model's price for ordering, putting hosted models
+// with an unknown price last
+func rankCost(model Model) float64 {
+	if model.CostPer1K == 0 && !model.Local {
+		return 1e9
+	}
+	return model.CostPer1K
+}
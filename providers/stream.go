@@ -0,0 +1,281 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MaxStreamLineSize is the longest line a stream decoder accepts. Lines of
+// large responses, such as a long completion sent in one event, can be far
+// beyond bufio.Scanner's 64KB default.
+const MaxStreamLineSize = 16 << 20
+
+// streamDone is the data of the event OpenAI compatible APIs send last
+const streamDone = "[DONE]"
+
+// ErrNotEventStream is returned when a response expected to be a stream of
+// server-sent events is not one
+var ErrNotEventStream = errors.New("response is not an event stream")
+
+// Event is a server-sent event
+type Event struct {
+	// Type is the event's "event:" field, or "message" if it has none
+	Type string
+
+	// Data is the event's "data:" lines, joined with newlines
+	Data string
+
+	ID string
+}
+
+// StreamError is an error reported by an API in the middle of a stream
+type StreamError struct {
+	Provider string
+	Code     string
+	Message  string
+}
+
+func (e *StreamError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%s stream error (%s): %s", e.Provider, e.Code, e.Message)
+	}
+	return fmt.Sprintf("%s stream error: %s", e.Provider, e.Message)
+}
+
+// SSEDecoder reads server-sent events. It handles multi-line data, event
+// types, ids and comments, and lines up to MaxStreamLineSize.
+type SSEDecoder struct {
+	scanner *bufio.Scanner
+}
+
+// NewSSEDecoder creates a decoder reading events from r
+func NewSSEDecoder(r io.Reader) *SSEDecoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxStreamLineSize)
+	return &SSEDecoder{scanner: scanner}
+}
+
+// Next returns the next event, or io.EOF at the end of the stream. A last
+// event without a terminating blank line is still returned.
+func (d *SSEDecoder) Next() (*Event, error) {
+	var event Event
+	var data []string
+	pending := false
+	for d.scanner.Scan() {
+		line := strings.TrimSuffix(d.scanner.Text(), "\r")
+		if line == "" {
+			if !pending {
+				continue
+			}
+			break
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "data":
+			data = append(data, value)
+		case "event":
+			event.Type = value
+		case "id":
+			event.ID = value
+		default:
+			// Unknown fields, including "retry", are ignored, but a line
+			// that is not a field at all means this is not an event stream
+			if !isSSEField(field) {
+				return nil, fmt.Errorf("%w: unexpected line %q", ErrNotEventStream, truncate(line, 200))
+			}
+			continue
+		}
+		pending = true
+	}
+	if err := d.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading event stream: %w", err)
+	}
+	if !pending {
+		return nil, io.EOF
+	}
+
+	if event.Type == "" {
+		event.Type = "message"
+	}
+	event.Data = strings.Join(data, "\n")
+	return &event, nil
+}
+
+// isSSEField reports whether a line's text before the colon can be a field
+// name. JSON, which APIs send when streaming was not negotiated, cannot.
+func isSSEField(field string) bool {
+	if field == "" {
+		return false
+	}
+	for _, c := range field {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// DecodeSSE calls handle for every event with data until the stream ends
+// or an OpenAI style "[DONE]" event arrives. Errors from reading the stream
+// or from handle are returned, and so is ErrNotEventStream if the stream
+// holds something other than events or ends without any event with data.
+func DecodeSSE(r io.Reader, handle func(event *Event) error) error {
+	decoder := NewSSEDecoder(r)
+	received := false
+	for {
+		event, err := decoder.Next()
+		if errors.Is(err, io.EOF) {
+			if !received {
+				return fmt.Errorf("%w: no events received", ErrNotEventStream)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if event.Data == streamDone {
+			return nil
+		}
+		if event.Data == "" {
+			continue
+		}
+		received = true
+		if err := handle(event); err != nil {
+			return err
+		}
+	}
+}
+
+// DecodeNDJSON calls handle with every line of a newline-delimited JSON
+// stream, skipping blank lines, until the stream ends. Lines may be up to
+// MaxStreamLineSize long.
+func DecodeNDJSON(r io.Reader, handle func(line []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxStreamLineSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := handle(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading stream: %w", err)
+	}
+	return nil
+}
+
+// decodeChunk unmarshals a stream chunk, naming the provider in the error
+// if it is malformed
+func decodeChunk(provider string, data []byte, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s stream: malformed chunk %q: %w", provider, truncate(string(data), 200), err)
+	}
+	return nil
+}
+
+// truncate shortens s to at most n bytes for error messages
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// chatStreamChunk is a chunk of an OpenAI compatible chat completion
+// stream. The last chunk may carry only usage, and errors may arrive in
+// place of a chunk.
+type chatStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+// decodeChatStream reads an OpenAI compatible chat completion stream,
+// emitting its text, tool call and usage events, and then the done event
+// with the finish reason
+func decodeChatStream(ctx context.Context, provider string, r io.Reader, emit func(StreamEvent) bool) error {
+	finishReason := ""
+	err := DecodeSSE(r, func(event *Event) error {
+		var chunk chatStreamChunk
+		err := decodeChunk(provider, []byte(event.Data), &chunk)
+		switch {
+		case err == nil && chunk.Error != nil:
+			return &StreamError{Provider: provider, Code: chunk.Error.Type, Message: chunk.Error.Message}
+		case event.Type == "error":
+			return &StreamError{Provider: provider, Message: event.Data}
+		case err != nil:
+			return err
+		}
+
+		var events []StreamEvent
+		if len(chunk.Choices) > 0 {
+			choice := chunk.Choices[0]
+			if choice.Delta.Content != "" {
+				events = append(events, StreamEvent{Type: EventText, Text: choice.Delta.Content})
+			}
+			for _, call := range choice.Delta.ToolCalls {
+				events = append(events, StreamEvent{Type: EventToolCall, ToolCall: &ToolCallDelta{
+					Index:     call.Index,
+					ID:        call.ID,
+					Name:      call.Function.Name,
+					Arguments: call.Function.Arguments,
+				}})
+			}
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+		}
+		if chunk.Usage != nil {
+			events = append(events, StreamEvent{Type: EventUsage, Usage: &Usage{
+				PromptTokens:     chunk.Usage.PromptTokens,
+				CompletionTokens: chunk.Usage.CompletionTokens,
+				TotalTokens:      chunk.Usage.TotalTokens,
+			}})
+		}
+		for _, e := range events {
+			if !emit(e) {
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	emit(StreamEvent{Type: EventDone, FinishReason: finishReason})
+	return nil
+}
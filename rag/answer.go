@@ -0,0 +1,81 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/aldotobing/neurogo/config"
+	"github.com/aldotobing/neurogo/providers"
+)
+
+// DefaultTopK is the number of chunks retrieved for a question when none
+// is given
+const DefaultTopK = 4
+
+// ErrEmptyCollection is returned when asking a collection without
+// documents
+var ErrEmptyCollection = errors.New("collection has no documents")
+
+// Answer is a response grounded in the chunks of a collection. The
+// response cites chunks by their position in Sources, as [1], [2], ...
+type Answer struct {
+	Text    string  `json:"answer"`
+	Sources []Match `json:"sources"`
+}
+
+// Ask answers a question from the k chunks of the collection most similar
+// to it. provider must render prompt templates, see prompts.Library.Wrap;
+// the "rag_answer" template receives the question and the numbered chunks
+// as the "question" and "context" variables.
+func (c *Collection) Ask(ctx context.Context, provider providers.Provider, options config.CompletionOptions, question string, k int) (*Answer, error) {
+	if k <= 0 {
+		k = DefaultTopK
+	}
+	if c.Info().Chunks == 0 {
+		return nil, fmt.Errorf("%w: ingest documents into %s first", ErrEmptyCollection, c.Name())
+	}
+
+	matches, err := c.Search(ctx, question, k)
+	if err != nil {
+		return nil, err
+	}
+
+	options.Template = "rag_answer"
+	options.Variables = map[string]interface{}{
+		"question": question,
+		"context":  c.context(matches),
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	text, err := provider.Complete(question, options)
+	if err != nil {
+		return nil, err
+	}
+	return &Answer{Text: strings.TrimSpace(text), Sources: matches}, nil
+}
+
+// context numbers the retrieved chunks with their sources for the prompt
+func (c *Collection) context(matches []Match) string {
+	var b strings.Builder
+	for i, match := range matches {
+		fmt.Fprintf(&b, "[%d] %s\n%s\n\n", i+1, c.Source(match.Chunk), match.Text)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// Source returns the path of a chunk's document relative to the documents
+// root, if there is one
+func (c *Collection) Source(chunk Chunk) string {
+	if c.options.Root != "" {
+		if root, err := c.options.rootDir(); err == nil {
+			if rel, err := filepath.Rel(root, chunk.Source); err == nil && !strings.HasPrefix(rel, "..") {
+				return rel
+			}
+		}
+	}
+	return chunk.Source
+}
@@ -0,0 +1,631 @@
+package rag
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aldotobing/neurogo/tokens"
+	"github.com/aldotobing/neurogo/vector"
+)
+
+// embedBatch is the number of chunks embedded per request
+const embedBatch = 32
+
+// ErrEmbeddingModel is returned when searching a collection embedded with
+// another model than the one configured. Refreshing it embeds it again.
+var ErrEmbeddingModel = errors.New("collection was embedded with another model")
+
+// ErrOutsideRoot is returned when ingesting a path outside the root
+// documents may be read from
+var ErrOutsideRoot = errors.New("path is outside the documents root")
+
+// Chunk is a piece of a document stored in a collection
+type Chunk struct {
+	ID string `json:"id"`
+
+	// Source is the path of the document
+	Source string `json:"source"`
+
+	// Index is the position of the chunk in the document
+	Index int    `json:"index"`
+	Text  string `json:"text"`
+}
+
+// File is a document ingested into a collection
+type File struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Hash    string    `json:"hash"`
+	Chunks  int       `json:"chunks"`
+}
+
+// Info describes a collection
+type Info struct {
+	Name     string    `json:"name"`
+	Provider string    `json:"provider"`
+	Model    string    `json:"model,omitempty"`
+	Sources  []string  `json:"sources"`
+	Files    int       `json:"files"`
+	Chunks   int       `json:"chunks"`
+	Updated  time.Time `json:"updated"`
+}
+
+// Report is the outcome of ingesting documents
+type Report struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"`
+	Chunks    int `json:"chunks"`
+
+	// Errors maps the documents that could not be ingested to the reason
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// Changed reports whether the ingestion changed the collection
+func (r *Report) Changed() bool {
+	return r.Added+r.Updated+r.Removed > 0
+}
+
+// collectionData is what is stored of a collection in its file. The
+// vectors of its chunks are only kept by its vector store.
+type collectionData struct {
+	Name     string
+	Provider string
+	Model    string
+	Sources  []string
+	Files    map[string]*File
+	Chunks   map[string]*Chunk
+	Updated  time.Time
+}
+
+// Collection is a named set of documents, split into chunks and embedded
+// for retrieval. Its documents and chunks are stored in a file and their
+// vectors in a vector store. It is safe for concurrent use.
+type Collection struct {
+	path    string
+	options *Options
+
+	// ingesting serializes ingestions, which embed outside of mu
+	ingesting sync.Mutex
+
+	mu    sync.RWMutex
+	data  collectionData
+	store vector.Store
+}
+
+// newCollection creates an empty collection stored at path
+func newCollection(name, path string, options *Options) (*Collection, error) {
+	store, err := options.openStore(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Collection{
+		path:    path,
+		options: options,
+		data: collectionData{
+			Name:     name,
+			Provider: options.Provider,
+			Model:    options.Model,
+			Files:    make(map[string]*File),
+			Chunks:   make(map[string]*Chunk),
+		},
+		store: store,
+	}, nil
+}
+
+// loadCollection reads a collection stored at path and makes sure its
+// vector store holds its chunks
+func loadCollection(ctx context.Context, path string, options *Options) (*Collection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := &Collection{path: path, options: options}
+	if err := gob.NewDecoder(f).Decode(&c.data); err != nil {
+		return nil, fmt.Errorf("reading collection %s: %w", path, err)
+	}
+	if c.data.Files == nil {
+		c.data.Files = make(map[string]*File)
+	}
+	if c.data.Chunks == nil {
+		c.data.Chunks = make(map[string]*Chunk)
+	}
+
+	c.store, err = options.openStore(c.data.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.sync(ctx); err != nil {
+		c.store.Close()
+		return nil, fmt.Errorf("loading collection %s into its vector store: %w", c.data.Name, err)
+	}
+	return c, nil
+}
+
+// sync embeds the chunks again if the vector store holds fewer of them than
+// the collection, as when it keeps them in memory or was emptied. Chunks
+// embedded with another model are left for the next ingestion to replace.
+// Extra items, left by a crash before the collection was saved, are not
+// found again and are ignored by Search.
+func (c *Collection) sync(ctx context.Context) error {
+	if c.data.Provider != c.options.Provider || c.data.Model != c.options.Model {
+		return nil
+	}
+	n, err := c.store.Count(ctx, c.filter())
+	if err != nil || n >= len(c.data.Chunks) {
+		return err
+	}
+
+	chunks := make([]Chunk, 0, len(c.data.Chunks))
+	for _, chunk := range c.data.Chunks {
+		chunks = append(chunks, *chunk)
+	}
+	for start := 0; start < len(chunks); start += embedBatch {
+		batch := chunks[start:min(start+embedBatch, len(chunks))]
+		vectors, err := c.embed(ctx, batch)
+		if err != nil {
+			return err
+		}
+		items := make([]vector.Item, len(batch))
+		for i := range batch {
+			items[i] = c.item(&batch[i], vectors[i])
+		}
+		if err := c.store.Upsert(ctx, items...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// item returns the vector store item of a chunk. Items are tagged with the
+// collection, so collections can share a store.
+func (c *Collection) item(chunk *Chunk, embedding []float32) vector.Item {
+	return vector.Item{
+		ID:       c.storeID(chunk.ID),
+		Vector:   embedding,
+		Metadata: map[string]string{"collection": c.data.Name, "source": chunk.Source},
+	}
+}
+
+// storeID returns the vector store ID of a chunk, which is unique among
+// collections
+func (c *Collection) storeID(chunkID string) string {
+	return c.data.Name + ":" + chunkID
+}
+
+// filter selects the collection's items in the vector store
+func (c *Collection) filter() vector.Filter {
+	return vector.Filter{"collection": c.data.Name}
+}
+
+// Name returns the name of the collection
+func (c *Collection) Name() string {
+	return c.data.Name
+}
+
+// Info describes the collection
+func (c *Collection) Info() Info {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return Info{
+		Name:     c.data.Name,
+		Provider: c.data.Provider,
+		Model:    c.data.Model,
+		Sources:  append([]string{}, c.data.Sources...),
+		Files:    len(c.data.Files),
+		Chunks:   len(c.data.Chunks),
+		Updated:  c.data.Updated,
+	}
+}
+
+// Files returns the documents of the collection, sorted by path
+func (c *Collection) Files() []File {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	files := make([]File, 0, len(c.data.Files))
+	for _, file := range c.data.Files {
+		files = append(files, *file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+// Ingest adds the documents at paths, files or directories searched
+// recursively, to the collection. Documents that did not change since they
+// were ingested are skipped, changed ones are embedded again, and those
+// that disappeared from an ingested directory are removed. Documents under
+// a directory that cannot be read are kept. The paths are remembered so
+// Refresh can ingest them again. The collection is saved even if ingestion
+// fails or ctx is canceled, so its file matches the vectors stored up to
+// that point.
+func (c *Collection) Ingest(ctx context.Context, paths ...string) (report *Report, err error) {
+	c.ingesting.Lock()
+	defer c.ingesting.Unlock()
+	defer func() {
+		if saveErr := c.save(); err == nil {
+			err = saveErr
+		}
+	}()
+
+	return c.ingest(ctx, paths)
+}
+
+// ingest is Ingest for callers that hold ingesting and save the collection
+func (c *Collection) ingest(ctx context.Context, paths []string) (*Report, error) {
+	roots := make([]string, 0, len(paths))
+	for _, path := range paths {
+		root, err := c.options.resolve(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(root); err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+	}
+
+	// Documents embedded with another model cannot be compared with
+	// queries, so they are all embedded again
+	c.mu.Lock()
+	if c.data.Provider != c.options.Provider || c.data.Model != c.options.Model {
+		if err := c.store.Delete(ctx, c.storeIDs()...); err != nil {
+			c.mu.Unlock()
+			return nil, err
+		}
+		c.data.Files = make(map[string]*File)
+		c.data.Chunks = make(map[string]*Chunk)
+		c.data.Provider, c.data.Model = c.options.Provider, c.options.Model
+	}
+	for _, root := range roots {
+		if !contains(c.data.Sources, root) {
+			c.data.Sources = append(c.data.Sources, root)
+		}
+	}
+	c.mu.Unlock()
+
+	report := &Report{Errors: make(map[string]string)}
+	seen := make(map[string]bool)
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				// What could not be read is not known to be gone
+				report.Errors[path] = err.Error()
+				c.mu.RLock()
+				for file := range c.data.Files {
+					if within(file, []string{path}) {
+						seen[file] = true
+					}
+				}
+				c.mu.RUnlock()
+				return nil
+			}
+			if entry.IsDir() {
+				if path != root && strings.HasPrefix(entry.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !Supported(path) {
+				return nil
+			}
+			seen[path] = true
+			if err := c.ingestFile(ctx, path, report); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				report.Errors[path] = err.Error()
+			}
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+
+	// Drop the documents that are gone from the ingested paths
+	var err error
+	c.mu.Lock()
+	for path := range c.data.Files {
+		if !seen[path] && within(path, roots) {
+			if err = c.removeFile(ctx, path); err != nil {
+				break
+			}
+			report.Removed++
+		}
+	}
+	c.data.Updated = time.Now()
+	report.Chunks = len(c.data.Chunks)
+	c.mu.Unlock()
+
+	if len(report.Errors) == 0 {
+		report.Errors = nil
+	}
+	return report, err
+}
+
+// Refresh ingests the collection's paths again, picking up changed, new
+// and deleted documents. Like Ingest, it saves the collection even if it
+// fails.
+func (c *Collection) Refresh(ctx context.Context) (report *Report, err error) {
+	c.ingesting.Lock()
+	defer c.ingesting.Unlock()
+	defer func() {
+		if saveErr := c.save(); err == nil {
+			err = saveErr
+		}
+	}()
+
+	c.mu.Lock()
+	var existing []string
+	for _, source := range append([]string(nil), c.data.Sources...) {
+		if _, err := os.Stat(source); err == nil {
+			existing = append(existing, source)
+			continue
+		}
+
+		// The whole path is gone, so are its documents
+		for path := range c.data.Files {
+			if within(path, []string{source}) {
+				if err := c.removeFile(ctx, path); err != nil {
+					c.mu.Unlock()
+					return nil, err
+				}
+			}
+		}
+		c.data.Sources = remove(c.data.Sources, source)
+	}
+	c.mu.Unlock()
+
+	return c.ingest(ctx, existing)
+}
+
+// ingestFile embeds a document unless it is unchanged
+func (c *Collection) ingestFile(ctx context.Context, path string, report *Report) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	c.mu.RLock()
+	known := c.data.Files[path]
+	c.mu.RUnlock()
+	if known != nil && known.Size == info.Size() && known.ModTime.Equal(info.ModTime()) {
+		report.Unchanged++
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if known != nil && known.Hash == hash {
+		c.mu.Lock()
+		known.Size, known.ModTime = info.Size(), info.ModTime()
+		c.mu.Unlock()
+		report.Unchanged++
+		return nil
+	}
+
+	text, err := Extract(path, data)
+	if err != nil {
+		return err
+	}
+	chunks := c.split(path, text)
+	vectors, err := c.embed(ctx, chunks)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.removeFile(ctx, path); err != nil {
+		return err
+	}
+	items := make([]vector.Item, len(chunks))
+	for i := range chunks {
+		items[i] = c.item(&chunks[i], vectors[i])
+	}
+	if err := c.store.Upsert(ctx, items...); err != nil {
+		return fmt.Errorf("storing vectors failed: %w", err)
+	}
+	for i := range chunks {
+		c.data.Chunks[chunks[i].ID] = &chunks[i]
+	}
+	c.data.Files[path] = &File{Path: path, Size: info.Size(), ModTime: info.ModTime(), Hash: hash, Chunks: len(chunks)}
+
+	if known != nil {
+		report.Updated++
+	} else {
+		report.Added++
+	}
+	return nil
+}
+
+// split splits a document into chunks that fit the chunk budget
+func (c *Collection) split(path, text string) []Chunk {
+	pieces := tokens.Split(tokens.For(c.options.Model), text, c.options.chunkTokens())
+	chunks := make([]Chunk, len(pieces))
+	for i, piece := range pieces {
+		chunks[i] = Chunk{ID: fmt.Sprintf("%s#%d", path, i), Source: path, Index: i, Text: piece}
+	}
+	return chunks
+}
+
+// embed returns the embeddings of chunks, requesting them in batches
+func (c *Collection) embed(ctx context.Context, chunks []Chunk) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(chunks))
+	for start := 0; start < len(chunks); start += embedBatch {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		end := min(start+embedBatch, len(chunks))
+		texts := make([]string, 0, end-start)
+		for _, chunk := range chunks[start:end] {
+			texts = append(texts, chunk.Text)
+		}
+		batch, err := c.options.Embedder.Embed(texts, c.options.Model)
+		if err != nil {
+			return nil, fmt.Errorf("embedding failed: %w", err)
+		}
+		if len(batch) != len(texts) {
+			return nil, fmt.Errorf("embedding failed: got %d vectors for %d chunks", len(batch), len(texts))
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// removeFile drops a document and its chunks. The caller holds mu.
+func (c *Collection) removeFile(ctx context.Context, path string) error {
+	file := c.data.Files[path]
+	if file == nil {
+		return nil
+	}
+	ids := make([]string, 0, file.Chunks)
+	storeIDs := make([]string, 0, file.Chunks)
+	for i := 0; i < file.Chunks; i++ {
+		id := fmt.Sprintf("%s#%d", path, i)
+		ids = append(ids, id)
+		storeIDs = append(storeIDs, c.storeID(id))
+	}
+	if err := c.store.Delete(ctx, storeIDs...); err != nil {
+		return fmt.Errorf("removing vectors failed: %w", err)
+	}
+	for _, id := range ids {
+		delete(c.data.Chunks, id)
+	}
+	delete(c.data.Files, path)
+	return nil
+}
+
+// storeIDs returns the vector store IDs of every chunk. The caller holds
+// mu.
+func (c *Collection) storeIDs() []string {
+	ids := make([]string, 0, len(c.data.Chunks))
+	for id := range c.data.Chunks {
+		ids = append(ids, c.storeID(id))
+	}
+	return ids
+}
+
+// drop removes the collection's vectors from its store
+func (c *Collection) drop(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.Delete(ctx, c.storeIDs()...)
+}
+
+// save writes the collection to its file, replacing it only once the new
+// one is complete
+func (c *Collection) save() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(&c.data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("saving collection %s: %w", c.data.Name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// Match is a chunk retrieved for a query, with its similarity to it
+type Match struct {
+	Chunk
+	Score float64 `json:"score"`
+}
+
+// Search returns the k chunks most similar to the query, best first
+func (c *Collection) Search(ctx context.Context, query string, k int) ([]Match, error) {
+	c.mu.RLock()
+	provider, model := c.data.Provider, c.data.Model
+	c.mu.RUnlock()
+	if provider != c.options.Provider || model != c.options.Model {
+		return nil, fmt.Errorf("%w: %s/%s, not %s/%s", ErrEmbeddingModel, provider, model, c.options.Provider, c.options.Model)
+	}
+
+	vectors, err := c.options.Embedder.Embed([]string{query}, c.options.Model)
+	if err != nil {
+		return nil, fmt.Errorf("embedding the query failed: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("embedding the query failed: got %d vectors", len(vectors))
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	results, err := c.store.Query(ctx, vectors[0], k, c.filter())
+	if err != nil {
+		return nil, fmt.Errorf("searching vectors failed: %w", err)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	matches := make([]Match, 0, len(results))
+	for _, result := range results {
+		if chunk := c.data.Chunks[strings.TrimPrefix(result.ID, c.data.Name+":")]; chunk != nil {
+			matches = append(matches, Match{Chunk: *chunk, Score: result.Score})
+		}
+	}
+	return matches, nil
+}
+
+// within reports whether path is one of roots or inside one of them
+func within(path string, roots []string) bool {
+	for _, root := range roots {
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether list has s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// remove returns list without s
+func remove(list []string, s string) []string {
+	kept := list[:0]
+	for _, item := range list {
+		if item != s {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,325 @@
+package rag
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ErrUnsupported is returned for documents in a format that cannot be read
+var ErrUnsupported = errors.New("unsupported document format")
+
+// ErrNoText is returned for documents without extractable text, such as
+// scanned PDFs
+var ErrNoText = errors.New("document has no text")
+
+// Extractor returns the plain text of a document
+type Extractor func(data []byte) (string, error)
+
+// extractors maps file extensions to the extractor of their format
+var extractors = map[string]Extractor{
+	".md":       plainText,
+	".markdown": plainText,
+	".txt":      plainText,
+	".text":     plainText,
+	".html":     htmlText,
+	".htm":      htmlText,
+	".pdf":      pdfText,
+}
+
+// Supported reports whether documents with the path's extension can be
+// ingested
+func Supported(path string) bool {
+	_, ok := extractors[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// Extract returns the plain text of a document, choosing its format by the
+// extension of path
+func Extract(path string, data []byte) (string, error) {
+	extract, ok := extractors[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupported, filepath.Ext(path))
+	}
+	text, err := extract(data)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", ErrNoText
+	}
+	return text, nil
+}
+
+// plainText reads Markdown and text files as they are
+func plainText(data []byte) (string, error) {
+	return strings.ReplaceAll(string(bytes.ToValidUTF8(data, nil)), "\r\n", "\n"), nil
+}
+
+// blockElements end a line of text in HTML
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "pre": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"section": true, "article": true, "header": true, "footer": true,
+	"blockquote": true, "table": true, "ul": true, "ol": true, "dt": true, "dd": true,
+}
+
+// hiddenElements hold no readable text
+var hiddenElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "svg": true, "head": true,
+}
+
+// htmlText returns the readable text of an HTML page, with paragraphs on
+// lines of their own
+func htmlText(data []byte) (string, error) {
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	var b strings.Builder
+	hidden := 0
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return "", err
+			}
+			return collapseLines(b.String()), nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := tokenizer.TagName()
+			tag := string(name)
+			if hiddenElements[tag] {
+				hidden++
+			}
+			if blockElements[tag] {
+				b.WriteString("\n\n")
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			tag := string(name)
+			if hiddenElements[tag] && hidden > 0 {
+				hidden--
+			}
+			if blockElements[tag] {
+				b.WriteString("\n\n")
+			}
+		case html.TextToken:
+			if hidden == 0 {
+				b.Write(tokenizer.Text())
+			}
+		}
+	}
+}
+
+var (
+	spaces     = regexp.MustCompile(`[ \t\r\f\v]+`)
+	blankLines = regexp.MustCompile(`\n\s*\n\s*`)
+)
+
+// collapseLines squeezes runs of spaces and keeps single blank lines
+// between paragraphs
+func collapseLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spaces.ReplaceAllString(line, " "))
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// pdfStream matches the streams of a PDF with the dictionary before them
+var pdfStream = regexp.MustCompile(`(?s)<<(.{0,1000}?)>>\s*stream\r?\n`)
+
+// pdfText returns the text of the content streams of a PDF. It reads the
+// strings shown by text operators, uncompressed or Flate compressed, which
+// covers most PDFs produced from documents; text in custom font encodings
+// may come out garbled.
+func pdfText(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("%PDF")) {
+		return "", fmt.Errorf("not a PDF file")
+	}
+
+	var b strings.Builder
+	for _, match := range pdfStream.FindAllSubmatchIndex(data, -1) {
+		dict := data[match[2]:match[3]]
+		start := match[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		content := data[start : start+end]
+
+		switch {
+		case bytes.Contains(dict, []byte("/FlateDecode")):
+			r, err := zlib.NewReader(bytes.NewReader(content))
+			if err != nil {
+				continue
+			}
+			// Streams are often followed by padding that fails the
+			// checksum, so keep what was read
+			content, _ = io.ReadAll(r)
+		case bytes.Contains(dict, []byte("/Filter")):
+			// Images and other encodings carry no text
+			continue
+		}
+
+		if bytes.Contains(content, []byte("BT")) && bytes.Contains(content, []byte("ET")) {
+			b.WriteString(pdfContentText(content))
+			b.WriteString("\n\n")
+		}
+	}
+	return collapseLines(b.String()), nil
+}
+
+// pdfContentText returns the strings shown in a page content stream
+func pdfContentText(content []byte) string {
+	var b strings.Builder
+	var operands []string
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '(':
+			s, next := pdfLiteral(content, i+1)
+			operands = append(operands, s)
+			i = next
+		case c == '<' && i+1 < len(content) && content[i+1] != '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				return b.String()
+			}
+			operands = append(operands, pdfHex(content[i+1:i+end]))
+			i += end
+		case isPDFOperatorByte(c):
+			j := i
+			for j < len(content) && isPDFOperatorByte(content[j]) {
+				j++
+			}
+			operator := string(content[i:j])
+			i = j - 1
+			switch operator {
+			case "Tj", "TJ", "'", "\"":
+				if operator != "TJ" && operator != "Tj" {
+					b.WriteString("\n")
+				}
+				b.WriteString(strings.Join(operands, ""))
+				operands = nil
+			case "T*", "Td", "TD", "ET":
+				b.WriteString("\n")
+				operands = nil
+			case "BT":
+				operands = nil
+			}
+		case c == '-' && i+1 < len(content) && content[i+1] >= '0' && content[i+1] <= '9':
+			// Large negative kerning in a TJ array separates words
+			j := i + 1
+			for j < len(content) && (content[j] >= '0' && content[j] <= '9' || content[j] == '.') {
+				j++
+			}
+			if n := string(content[i+1 : j]); len(operands) > 0 && (len(n) > 3 || len(n) == 3 && n >= "200") {
+				operands = append(operands, " ")
+			}
+			i = j - 1
+		}
+	}
+	return b.String()
+}
+
+// isPDFOperatorByte reports whether c can be part of a content stream
+// operator
+func isPDFOperatorByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '*' || c == '\'' || c == '"'
+}
+
+// pdfLiteral reads a literal string starting after its opening
+// parenthesis, returning it and the index of its closing parenthesis
+func pdfLiteral(content []byte, i int) (string, int) {
+	var b strings.Builder
+	depth := 1
+	for ; i < len(content); i++ {
+		c := content[i]
+		switch c {
+		case '\\':
+			i++
+			if i >= len(content) {
+				return b.String(), i
+			}
+			switch e := content[i]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r', 't', 'b', 'f':
+				b.WriteByte(' ')
+			case '\n', '\r':
+				// Line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					n := 0
+					for k := 0; k < 3 && i < len(content) && content[i] >= '0' && content[i] <= '7'; k++ {
+						n = n*8 + int(content[i]-'0')
+						i++
+					}
+					i--
+					b.WriteRune(rune(n))
+				} else {
+					b.WriteByte(e)
+				}
+			}
+		case '(':
+			depth++
+			b.WriteByte(c)
+		case ')':
+			depth--
+			if depth == 0 {
+				return b.String(), i
+			}
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), i
+}
+
+// pdfHex decodes a hex string, reading two byte codes as UTF-16 when most
+// high bytes are zero
+func pdfHex(hex []byte) string {
+	var raw []byte
+	var high byte
+	odd := false
+	for _, c := range hex {
+		var v byte
+		switch {
+		case c >= '0' && c <= '9':
+			v = c - '0'
+		case c >= 'a' && c <= 'f':
+			v = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			v = c - 'A' + 10
+		default:
+			continue
+		}
+		if odd {
+			raw = append(raw, high<<4|v)
+		} else {
+			high = v
+		}
+		odd = !odd
+	}
+
+	zeros := 0
+	for i := 0; i < len(raw); i += 2 {
+		if raw[i] == 0 {
+			zeros++
+		}
+	}
+	if len(raw)%2 == 0 && len(raw) > 0 && zeros*2 >= len(raw)/2 {
+		var b strings.Builder
+		for i := 0; i+1 < len(raw); i += 2 {
+			b.WriteRune(rune(raw[i])<<8 | rune(raw[i+1]))
+		}
+		return b.String()
+	}
+	return string(raw)
+}
@@ -0,0 +1,302 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aldotobing/neurogo/providers"
+	"github.com/aldotobing/neurogo/vector"
+)
+
+// DefaultChunkTokens is the size of chunks when Options.ChunkTokens is zero
+const DefaultChunkTokens = 400
+
+// collectionExt is the extension of collection files
+const collectionExt = ".collection"
+
+// vectorsExt is the extension of the files of the default vector stores
+const vectorsExt = ".vectors"
+
+// ErrCollectionNotFound is returned for unknown collection names
+var ErrCollectionNotFound = errors.New("collection not found")
+
+// validName matches the names collections can have
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Options configure how the collections of a Manager are built
+type Options struct {
+	// Embedder embeds chunks and queries
+	Embedder providers.Embedder
+
+	// Provider names the embedder's provider. Collections embedded by
+	// another provider or model are embedded again when refreshed.
+	Provider string
+
+	// Model is the embedding model, or empty for the provider's default
+	Model string
+
+	// ChunkTokens is the size of chunks in tokens
+	ChunkTokens int
+
+	// Root is the directory documents are read from. Relative paths are
+	// resolved against it and paths outside it are refused. If empty,
+	// any path can be ingested.
+	Root string
+
+	// Store opens the vector store holding a collection's vectors, see
+	// vector.Open. Collections may share a store. If nil, each collection
+	// keeps its vectors in a vector.FileStore next to its file. Stores
+	// that lose their vectors, like vector.Index, get them embedded again
+	// when the collection is opened.
+	Store func(collection string) (vector.Store, error)
+
+	// dir is the directory of the Manager, which holds the default stores
+	dir string
+}
+
+// openStore opens the vector store of a collection
+func (o *Options) openStore(collection string) (vector.Store, error) {
+	open := o.Store
+	if open == nil {
+		open = func(collection string) (vector.Store, error) {
+			return vector.OpenFileStore(o.storePath(collection), vector.HNSWOptions{})
+		}
+	}
+	store, err := open(collection)
+	if err != nil {
+		return nil, fmt.Errorf("opening the vector store of %s: %w", collection, err)
+	}
+	return store, nil
+}
+
+// storePath returns the path of the default vector store of a collection
+func (o *Options) storePath(collection string) string {
+	return filepath.Join(o.dir, collection+vectorsExt)
+}
+
+// chunkTokens returns the size of chunks
+func (o *Options) chunkTokens() int {
+	if o.ChunkTokens > 0 {
+		return o.ChunkTokens
+	}
+	return DefaultChunkTokens
+}
+
+// resolve returns the absolute path of a document, checking that it is
+// under the root
+func (o *Options) resolve(path string) (string, error) {
+	if o.Root == "" {
+		return filepath.Abs(path)
+	}
+
+	root, err := o.rootDir()
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path = filepath.Clean(path)
+
+	// Symbolic links could point outside the root
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if !within(path, []string{root}) {
+		return "", fmt.Errorf("%w: %s", ErrOutsideRoot, path)
+	}
+	return path, nil
+}
+
+// rootDir returns the absolute path of the documents root, with symbolic
+// links resolved
+func (o *Options) rootDir() (string, error) {
+	root, err := filepath.Abs(o.Root)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	return root, nil
+}
+
+// Manager keeps the collections stored in a directory
+type Manager struct {
+	dir     string
+	options *Options
+
+	mu          sync.Mutex
+	collections map[string]*Collection
+}
+
+// NewManager opens the collections stored in dir, creating it if needed
+func NewManager(dir string, options Options) (*Manager, error) {
+	if options.Embedder == nil {
+		return nil, fmt.Errorf("an embedder is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	options.dir = dir
+	m := &Manager{dir: dir, options: &options, collections: make(map[string]*Collection)}
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+collectionExt))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		c, err := loadCollection(context.Background(), path, m.options)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.collections[c.Name()] = c
+	}
+	return m, nil
+}
+
+// Collection returns a collection by name
+func (m *Manager) Collection(name string) (*Collection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, exists := m.collections[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
+	}
+	return c, nil
+}
+
+// Create returns the collection with a name, creating it if it does not
+// exist. Names are lowercase letters, digits, "-" and "_".
+func (m *Manager) Create(name string) (*Collection, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("invalid collection name %q: use lowercase letters, digits, '-' and '_'", name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, exists := m.collections[name]; exists {
+		return c, nil
+	}
+	c, err := newCollection(name, filepath.Join(m.dir, name+collectionExt), m.options)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.save(); err != nil {
+		c.store.Close()
+		return nil, err
+	}
+	m.collections[name] = c
+	return c, nil
+}
+
+// Delete removes a collection, its file and its vectors
+func (m *Manager) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, exists := m.collections[name]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
+	}
+	if err := c.drop(context.Background()); err != nil {
+		return fmt.Errorf("removing the vectors of %s: %w", name, err)
+	}
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(m.collections, name)
+
+	for _, other := range m.collections {
+		if other.store == c.store {
+			return nil
+		}
+	}
+	if err := c.store.Close(); err != nil {
+		return err
+	}
+	if m.options.Store == nil {
+		return vector.RemoveFileStore(m.options.storePath(name))
+	}
+	return nil
+}
+
+// Close closes the vector stores of the collections, once each
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	closed := make(map[vector.Store]bool)
+	for _, c := range m.collections {
+		if closed[c.store] {
+			continue
+		}
+		closed[c.store] = true
+		if err := c.store.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// List describes the collections, sorted by name
+func (m *Manager) List() []Info {
+	m.mu.Lock()
+	collections := make([]*Collection, 0, len(m.collections))
+	for _, c := range m.collections {
+		collections = append(collections, c)
+	}
+	m.mu.Unlock()
+
+	infos := make([]Info, len(collections))
+	for i, c := range collections {
+		infos[i] = c.Info()
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// Watch refreshes every collection each interval, so changed documents are
+// ingested again, until ctx is canceled
+func (m *Manager) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, info := range m.List() {
+			c, err := m.Collection(info.Name)
+			if err != nil {
+				continue
+			}
+			// Documents that cannot be read are reported when ingested, not
+			// on every refresh
+			report, err := c.Refresh(ctx)
+			switch {
+			case err != nil:
+				log.Printf("❌ Refreshing collection %s failed: %v", info.Name, err)
+			case report.Changed():
+				log.Printf("🔁 Collection %s: %d added, %d updated, %d removed", info.Name, report.Added, report.Updated, report.Removed)
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aldotobing/neurogo/config"
+)
+
+// DefaultClassifierThreshold is the confidence a Classifier needs to
+// dispatch when its Threshold is zero
+const DefaultClassifierThreshold = 0.6
+
+// Completer is the part of a provider the classifier needs.
+// providers.Provider satisfies it.
+type Completer interface {
+	Complete(prompt string, options config.CompletionOptions) (string, error)
+}
+
+// Classifier is a Fallback that asks a language model to pick the route an
+// unmatched prompt was meant for, and to extract its wildcard values.
+// Hidden routes are never offered to the model. If the model cannot be
+// reached or its reply cannot be read, the failure is logged and the
+// prompt goes on to the next fallback.
+type Classifier struct {
+	// Provider answers the classification prompt
+	Provider Completer
+
+	// Options are passed to the provider, typically to choose a small model
+	Options config.CompletionOptions
+
+	// Threshold is the minimum confidence needed to dispatch to the chosen
+	// route. Below it, the classifier replies with a clarifying question.
+	Threshold float64
+
+	// Clarify builds the reply for a low confidence classification. If nil,
+	// a reply suggesting the best candidate is used.
+	Clarify func(prompt string, candidate *Route, confidence float64) string
+}
+
+// classification is the JSON object the model is asked to return
+type classification struct {
+	Route      string   `json:"route"`
+	Params     []string `json:"params"`
+	Confidence float64  `json:"confidence"`
+}
+
+// Resolve implements Fallback
+func (c *Classifier) Resolve(prompt string, routes []*Route) (*Resolution, error) {
+	candidates := make([]*Route, 0, len(routes))
+	for _, route := range routes {
+		if !route.Meta.Hidden {
+			candidates = append(candidates, route)
+		}
+	}
+	if c.Provider == nil || len(candidates) == 0 {
+		return nil, nil
+	}
+
+	options := c.Options
+	options.SystemPrompt = "You are an intent classifier for a command router. Reply with a single JSON object and nothing else."
+
+	response, err := c.Provider.Complete(classificationPrompt(prompt, candidates), options)
+	if err != nil {
+		log.Printf("router: intent classification failed: %v", err)
+		return nil, nil
+	}
+
+	result, err := parseClassification(response)
+	if err != nil {
+		log.Printf("router: intent classification failed: %v", err)
+		return nil, nil
+	}
+	if result.Route == "" {
+		return nil, nil
+	}
+
+	// Only a listed route can be chosen, so a hidden one never runs
+	var route *Route
+	for _, candidate := range candidates {
+		if candidate.Pattern == result.Route {
+			route = candidate
+			break
+		}
+	}
+	if route == nil {
+		return nil, nil
+	}
+
+	threshold := c.Threshold
+	if threshold <= 0 {
+		threshold = DefaultClassifierThreshold
+	}
+
+	if result.Confidence < threshold || len(result.Params) != route.wildcards {
+		clarify := c.Clarify
+		if clarify == nil {
+			clarify = clarifyReply
+		}
+		return &Resolution{
+			Confidence: result.Confidence,
+			Reply:      clarify(prompt, route, result.Confidence),
+			Source:     "classifier",
+		}, nil
+	}
+
+	return &Resolution{
+		Route:      route,
+		Captures:   result.Params,
+		Confidence: result.Confidence,
+		Source:     "classifier",
+	}, nil
+}
+
+// classificationPrompt lists the routes with their descriptions and
+// examples and asks the model to pick one
+func classificationPrompt(prompt string, routes []*Route) string {
+	var b strings.Builder
+	b.WriteString("Choose the command that best matches the user's request.\n\n")
+	b.WriteString("Commands (each * is a parameter):\n")
+	for _, route := range routes {
+		fmt.Fprintf(&b, "- %q", route.Pattern)
+		if route.Meta.Description != "" {
+			fmt.Fprintf(&b, ": %s", route.Meta.Description)
+		}
+		b.WriteString("\n")
+		for _, example := range route.Meta.Examples {
+			fmt.Fprintf(&b, "    example: %q\n", example)
+		}
+	}
+	b.WriteString("\nReply with JSON of the form ")
+	b.WriteString(`{"route": "<command pattern exactly as listed>", "params": ["<value for each *, in order>"], "confidence": <0 to 1>}`)
+	b.WriteString(". If no command fits, use an empty route.\n\n")
+	fmt.Fprintf(&b, "User request: %q\n", prompt)
+	return b.String()
+}
+
+// parseClassification extracts the JSON object from the model's reply,
+// tolerating code fences and surrounding prose
+func parseClassification(response string) (*classification, error) {
+	start := strings.Index(response, "{")
+	end := strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("no JSON object in classifier response")
+	}
+
+	var result classification
+	if err := json.Unmarshal([]byte(response[start:end+1]), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// clarifyReply suggests the most likely command when the classifier is not
+// confident enough to run it
+func clarifyReply(prompt string, candidate *Route, confidence float64) string {
+	suggestion := candidate.Pattern
+	if len(candidate.Meta.Examples) > 0 {
+		suggestion = candidate.Meta.Examples[0]
+	}
+	return fmt.Sprintf("🤔 I'm not sure what you meant by %q. Did you mean something like %q?", prompt, suggestion)
+}
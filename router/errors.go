@@ -0,0 +1,130 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Code classifies an error so callers such as the HTTP API can react to it
+// without matching on messages
+type Code string
+
+// Error codes set by the router and its middleware. Handlers may use them
+// too, or define their own.
+const (
+	// CodeNotFound means no route or fallback handled the prompt
+	CodeNotFound Code = "not_found"
+
+	// CodeInvalidInput means the prompt was rejected before reaching a
+	// provider, for example because it was too long
+	CodeInvalidInput Code = "invalid_input"
+
+	// CodeUnavailable means no provider was available to handle the prompt
+	CodeUnavailable Code = "unavailable"
+
+	// CodeProvider means the AI provider failed or returned an error
+	CodeProvider Code = "provider_error"
+
+	// CodeTimeout means the prompt took too long to handle
+	CodeTimeout Code = "timeout"
+
+	// CodeInternal is the code of errors that carry none
+	CodeInternal Code = "internal"
+)
+
+// Error is an error with a Code. Suggestions lists commands the user may
+// have meant, for errors returned by DidYouMean.
+type Error struct {
+	Code        Code
+	Message     string
+	Suggestions []string
+	Err         error
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Message != "":
+		return e.Message
+	case e.Err != nil:
+		return e.Err.Error()
+	default:
+		return string(e.Code)
+	}
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errorf returns an *Error with the code and a formatted message. Use %w to
+// wrap an underlying error.
+func Errorf(code Code, format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	return &Error{Code: code, Message: err.Error(), Err: errors.Unwrap(err)}
+}
+
+// WithCode gives err a code, keeping its message. It returns nil for a nil
+// error and leaves errors that already carry a code alone.
+func WithCode(code Code, err error) error {
+	var coded *Error
+	if err == nil || errors.As(err, &coded) {
+		return err
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// CodeOf returns the code of err: the code of the first *Error in its
+// chain, a code for the router's own errors, or CodeInternal
+func CodeOf(err error) Code {
+	var coded *Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &coded):
+		return coded.Code
+	case errors.Is(err, ErrNoRoute):
+		return CodeNotFound
+	case errors.Is(err, ErrPipelineTooDeep), errors.Is(err, ErrInputTooLong):
+		return CodeInvalidInput
+	case errors.Is(err, context.DeadlineExceeded):
+		return CodeTimeout
+	default:
+		return CodeInternal
+	}
+}
+
+// SuggestionsOf returns the suggestions carried by err, if any
+func SuggestionsOf(err error) []string {
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Suggestions
+	}
+	return nil
+}
+
+// ErrorHandler is called with the error of a failed handler, not-found
+// handler or fallback. It may return a different error, for example one
+// with a code, or set ctx.Response and return nil to reply instead of
+// failing.
+type ErrorHandler func(ctx *Context, err error) error
+
+// SetNotFoundHandler sets the handler for prompts that no route or fallback
+// handles. It runs without middleware, receives a context with only the
+// prompt and pipeline input set, and may reply by setting ctx.Response.
+// Without one, such prompts fail with ErrNoRoute.
+func (r *Router) SetNotFoundHandler(handler Handler) {
+	r.update(func(t *table) error {
+		t.notFound = handler
+		return nil
+	})
+}
+
+// SetErrorHandler sets the handler for errors returned while handling a
+// stage of a prompt
+func (r *Router) SetErrorHandler(handler ErrorHandler) {
+	r.update(func(t *table) error {
+		t.onError = handler
+		return nil
+	})
+}
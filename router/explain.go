@@ -0,0 +1,119 @@
+package router
+
+import "fmt"
+
+// Explanation describes how a prompt would be routed, without running any
+// handler, middleware or fallback
+type Explanation struct {
+	Prompt string `json:"prompt"`
+
+	// Stages has one entry per pipeline stage; a plain prompt has one
+	Stages []StageExplanation `json:"stages"`
+}
+
+// StageExplanation describes how one stage of a prompt would be routed
+type StageExplanation struct {
+	Prompt string `json:"prompt"`
+
+	// Routes lists every route in the order they are tried
+	Routes []RouteMatch `json:"routes"`
+
+	// Winner is the pattern of the route that would handle the stage, or
+	// empty if none matches
+	Winner string `json:"winner,omitempty"`
+
+	// Fallback is set when no pattern matches and the router's fallbacks
+	// would be consulted. They are not called while explaining.
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+// RouteMatch is the result of testing a prompt against one route
+type RouteMatch struct {
+	Pattern   string `json:"pattern"`
+	Regex     string `json:"regex"`
+	Group     string `json:"group,omitempty"`
+	Priority  int    `json:"priority"`
+	Literals  int    `json:"literals"`
+	Wildcards int    `json:"wildcards"`
+
+	// Normalized is the prompt as the route sees it after normalization
+	Normalized string `json:"normalized"`
+
+	Matched  bool     `json:"matched"`
+	Captures []string `json:"captures,omitempty"`
+
+	// Piped is set when the route only matches with one wildcard filled by
+	// the previous stage's output
+	Piped bool `json:"piped,omitempty"`
+
+	Winner bool `json:"winner,omitempty"`
+}
+
+// Explain reports how the router would handle a prompt: how it is split
+// into pipeline stages, every route tested for each stage with its
+// compiled regex, the captures of the routes that match and which one
+// wins. Nothing is dispatched, so no handler or provider is called. The
+// output of earlier stages is unknown, so piped captures show a
+// placeholder for it.
+func (r *Router) Explain(prompt string) *Explanation {
+	t := r.snapshot()
+	explanation := &Explanation{Prompt: prompt}
+	for i, stage := range t.splitPipeline(prompt) {
+		input := ""
+		if i > 0 {
+			input = fmt.Sprintf("<output of stage %d>", i)
+		}
+		explanation.Stages = append(explanation.Stages, t.explainStage(stage, input, i > 0))
+	}
+	return explanation
+}
+
+// explainStage tests a stage against every route, choosing the winner the
+// same way find does
+func (t *table) explainStage(prompt, input string, piped bool) StageExplanation {
+	stage := StageExplanation{Prompt: prompt}
+	winner := -1
+	for i, route := range t.routes {
+		match := RouteMatch{
+			Pattern:    route.Pattern,
+			Regex:      route.RegexPattern.String(),
+			Priority:   route.Priority,
+			Literals:   route.literals,
+			Wildcards:  route.wildcards,
+			Normalized: route.options.normalize(prompt),
+		}
+		if route.group != nil {
+			match.Group = route.group.Name()
+		}
+		if matches := route.match(prompt); matches != nil {
+			match.Matched = true
+			match.Captures = matches[1:]
+			if winner < 0 {
+				winner = i
+			}
+		}
+		stage.Routes = append(stage.Routes, match)
+	}
+
+	if winner < 0 && piped {
+		for i, route := range t.routes {
+			if captures, ok := route.matchPiped(prompt, input); ok {
+				stage.Routes[i].Matched = true
+				stage.Routes[i].Piped = true
+				stage.Routes[i].Captures = captures
+				if winner < 0 {
+					winner = i
+				}
+			}
+		}
+	}
+
+	switch {
+	case winner >= 0:
+		stage.Routes[winner].Winner = true
+		stage.Winner = stage.Routes[winner].Pattern
+	case !piped && len(t.fallbacks) > 0:
+		stage.Fallback = true
+	}
+	return stage
+}
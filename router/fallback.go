@@ -0,0 +1,45 @@
+package router
+
+// FallbackKey is the context key under which the Resolution is stored when a
+// route was chosen by a fallback rather than by pattern matching
+const FallbackKey = "router.fallback"
+
+// Resolution is the outcome of a fallback for a prompt that matched no
+// pattern
+type Resolution struct {
+	// Route is the route to dispatch to, or nil if the fallback could not
+	// pick one with enough confidence
+	Route *Route
+
+	// Captures are the values for the route's wildcards, in order
+	Captures []string
+
+	// Confidence is how sure the fallback is about Route, from 0 to 1
+	Confidence float64
+
+	// Reply is returned to the user instead of dispatching when Route is
+	// nil, for example to ask a clarifying question
+	Reply string
+
+	// Source names the fallback that produced the resolution
+	Source string
+
+	// Candidates are the best scoring routes the fallback considered, for
+	// debugging. Not every fallback reports them.
+	Candidates []Candidate
+}
+
+// Fallback resolves prompts that no registered pattern matches. It returns
+// nil if it has nothing to offer, so the next fallback can be tried.
+type Fallback interface {
+	Resolve(prompt string, routes []*Route) (*Resolution, error)
+}
+
+// UseFallback appends a fallback that Process consults, in order, when no
+// pattern matches a prompt
+func (r *Router) UseFallback(fallback Fallback) {
+	r.update(func(t *table) error {
+		t.fallbacks = append(t.fallbacks, fallback)
+		return nil
+	})
+}
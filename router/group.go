@@ -0,0 +1,274 @@
+package router
+
+import (
+	"strings"
+	"sync"
+)
+
+// Keys under which groups conventionally store their default provider and
+// model with WithDefault. Handlers read them with Context.Get.
+const (
+	ProviderKey = "router.provider"
+	ModelKey    = "router.model"
+)
+
+// Group registers routes on a router that share a prefix, middleware,
+// default values and a help section
+type Group struct {
+	router *Router
+	parent *Group
+
+	name        string
+	prefix      string
+	description string
+	defaults    map[string]interface{}
+
+	// mu guards middleware. Routes copy it when they are added to the
+	// table, so prompts being processed never read it.
+	mu         sync.RWMutex
+	middleware []Middleware
+}
+
+// GroupOption configures a group when it is created
+type GroupOption func(*Group)
+
+// WithPrefix makes every pattern in the group start with prefix, so
+// registering "commit *" in a group with prefix "git" matches "git commit *"
+func WithPrefix(prefix string) GroupOption {
+	return func(g *Group) {
+		g.prefix = strings.TrimSpace(prefix)
+	}
+}
+
+// WithDescription sets the help text describing the group
+func WithDescription(description string) GroupOption {
+	return func(g *Group) {
+		g.description = description
+	}
+}
+
+// WithDefault stores a default value, such as ProviderKey or ModelKey, that
+// handlers in the group see through Context.Get unless the context itself
+// sets the key
+func WithDefault(key string, value interface{}) GroupOption {
+	return func(g *Group) {
+		if g.defaults == nil {
+			g.defaults = make(map[string]interface{})
+		}
+		g.defaults[key] = value
+	}
+}
+
+// Module is a set of routes that can be mounted on a router. It lets other
+// packages own their commands without access to the router itself.
+type Module interface {
+	Mount(g *Group) error
+}
+
+// ModuleFunc adapts a function to the Module interface
+type ModuleFunc func(g *Group) error
+
+// Mount registers the module's routes on g
+func (f ModuleFunc) Mount(g *Group) error {
+	return f(g)
+}
+
+// Group creates a new named route group on the router
+func (r *Router) Group(name string, opts ...GroupOption) *Group {
+	return newGroup(r, nil, name, opts)
+}
+
+// Mount creates a group and lets the module register its routes in it. If
+// the module returns an error, none of its routes are kept.
+func (r *Router) Mount(name string, module Module, opts ...GroupOption) error {
+	return r.stage(nil, func(staging *Router) error {
+		return module.Mount(newGroup(staging, nil, name, opts))
+	})
+}
+
+// Remount replaces the routes of the top-level group called name with the
+// ones the module registers, for example after reloading their definitions.
+// The new routes are checked against the rest of the table before the
+// table is swapped, so on error the existing routes stay in place.
+func (r *Router) Remount(name string, module Module, opts ...GroupOption) error {
+	replaced := func(route *Route) bool {
+		return route.group != nil && route.group.root().name == name
+	}
+	return r.stage(replaced, func(staging *Router) error {
+		return module.Mount(newGroup(staging, nil, name, opts))
+	})
+}
+
+// stage runs register against a staging router holding the current routes,
+// minus those drop reports, and publishes its routes in one step if
+// register succeeds. Other changes to the router wait until it is done, so
+// register must only add routes through the staging router.
+func (r *Router) stage(drop func(*Route) bool, register func(staging *Router) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	base := r.table.Load()
+	seed := base.clone()
+	seed.routes = seed.routes[:0]
+	for _, route := range base.routes {
+		if drop == nil || !drop(route) {
+			seed.routes = append(seed.routes, route)
+		}
+	}
+
+	staging := &Router{matchOptions: r.matchOptions}
+	staging.table.Store(seed)
+	if err := register(staging); err != nil {
+		return err
+	}
+
+	// Groups created while staging keep registering on the real router
+	staged := staging.table.Load()
+	for _, route := range staged.routes {
+		for group := route.group; group != nil; group = group.parent {
+			if group.router == staging {
+				group.router = r
+			}
+		}
+	}
+
+	return r.updateLocked(func(t *table) error {
+		t.routes, t.seq = staged.routes, staged.seq
+		return nil
+	})
+}
+
+func newGroup(r *Router, parent *Group, name string, opts []GroupOption) *Group {
+	g := &Group{router: r, parent: parent, name: name}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Name returns the group's name, prefixed by the names of its parents and
+// separated by "/"
+func (g *Group) Name() string {
+	if g.parent != nil && g.parent.Name() != "" {
+		return g.parent.Name() + "/" + g.name
+	}
+	return g.name
+}
+
+// Prefix returns the full pattern prefix of the group, including the
+// prefixes of its parents
+func (g *Group) Prefix() string {
+	var parts []string
+	if g.parent != nil && g.parent.Prefix() != "" {
+		parts = append(parts, g.parent.Prefix())
+	}
+	if g.prefix != "" {
+		parts = append(parts, g.prefix)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Description returns the group's help text
+func (g *Group) Description() string {
+	return g.description
+}
+
+// Group creates a nested group that inherits the prefix, middleware and
+// defaults of g
+func (g *Group) Group(name string, opts ...GroupOption) *Group {
+	return newGroup(g.router, g, name, opts)
+}
+
+// Mount creates a nested group and lets the module register its routes in
+// it. If the module returns an error, none of its routes are kept.
+func (g *Group) Mount(name string, module Module, opts ...GroupOption) error {
+	return g.router.stage(nil, func(staging *Router) error {
+		child := newGroup(staging, g, name, opts)
+		return module.Mount(child)
+	})
+}
+
+// Use appends middleware that runs for every route in the group, inside the
+// router's and parent groups' middleware. It also applies to routes
+// registered earlier: they are rebuilt in a new table, so a prompt being
+// processed sees the middleware before the change or after it, never a mix.
+func (g *Group) Use(middleware ...Middleware) {
+	g.router.update(func(t *table) error {
+		g.mu.Lock()
+		g.middleware = append(g.middleware, middleware...)
+		g.mu.Unlock()
+
+		for i, route := range t.routes {
+			if route.group.within(g) {
+				rebuilt := *route
+				rebuilt.inherited = route.group.allMiddleware()
+				t.routes[i] = &rebuilt
+			}
+		}
+		return nil
+	})
+}
+
+// Handle registers a route in the group. Like Router.Handle, it panics if
+// the pattern conflicts with an existing route.
+func (g *Group) Handle(pattern string, handler Handler, opts ...RouteOption) {
+	if err := g.Register(pattern, handler, opts...); err != nil {
+		panic(err)
+	}
+}
+
+// Register registers a route in the group and returns any conflict
+func (g *Group) Register(pattern string, handler Handler, opts ...RouteOption) error {
+	if prefix := g.Prefix(); prefix != "" {
+		pattern = prefix + " " + pattern
+	}
+	return g.router.Register(pattern, handler, append([]RouteOption{inGroup(g)}, opts...)...)
+}
+
+// inGroup records the group a route was registered through
+func inGroup(g *Group) RouteOption {
+	return func(route *Route) {
+		route.group = g
+	}
+}
+
+// allMiddleware returns the middleware of the group's ancestors followed by
+// its own
+func (g *Group) allMiddleware() []Middleware {
+	var middleware []Middleware
+	if g.parent != nil {
+		middleware = g.parent.allMiddleware()
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return append(middleware, g.middleware...)
+}
+
+// lookup returns a default value from the group or its nearest ancestor
+func (g *Group) lookup(key string) (interface{}, bool) {
+	for group := g; group != nil; group = group.parent {
+		if value, ok := group.defaults[key]; ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// within reports whether g is ancestor or nested in it
+func (g *Group) within(ancestor *Group) bool {
+	for ; g != nil; g = g.parent {
+		if g == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// root returns the top-level group g belongs to
+func (g *Group) root() *Group {
+	for g.parent != nil {
+		g = g.parent
+	}
+	return g
+}
@@ -0,0 +1,170 @@
+package router
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// UnicodeForm selects the Unicode normalization applied to patterns and
+// prompts before matching
+type UnicodeForm int
+
+const (
+	// NoNormalization matches prompts as they are received
+	NoNormalization UnicodeForm = iota
+
+	// NFC composes characters, so "e" followed by a combining accent
+	// matches a precomposed "é"
+	NFC
+
+	// NFKC additionally folds compatibility characters such as full-width
+	// letters and ligatures. Captures are returned in the folded form.
+	NFKC
+)
+
+// MatchOptions controls how a pattern is compared against a prompt
+type MatchOptions struct {
+	// CaseInsensitive makes literal parts of the pattern match regardless
+	// of case, so "Summarize this" matches "summarize *"
+	CaseInsensitive bool
+
+	// NormalizeWhitespace makes any run of whitespace in the pattern match
+	// any run of whitespace (including newlines and non-breaking spaces) in
+	// the prompt, and ignores leading and trailing whitespace
+	NormalizeWhitespace bool
+
+	// DotAll lets wildcards match across newlines, so multi-paragraph text
+	// can be captured
+	DotAll bool
+
+	// Unicode is the normalization form applied before matching
+	Unicode UnicodeForm
+}
+
+// DefaultMatchOptions returns the options used by New. They are tolerant
+// enough for text pasted from documents and chat clients.
+func DefaultMatchOptions() MatchOptions {
+	return MatchOptions{
+		CaseInsensitive:     true,
+		NormalizeWhitespace: true,
+		DotAll:              true,
+	}
+}
+
+// WithMatchOptions overrides the router's match options for a single route
+func WithMatchOptions(opts MatchOptions) RouteOption {
+	return func(route *Route) {
+		route.matchOptions = &opts
+	}
+}
+
+// whitespaceClass matches ASCII whitespace as well as Unicode space
+// separators such as the non-breaking space
+const whitespaceClass = `[\s\p{Z}]`
+
+// normalize applies the configured Unicode normalization form to s
+func (o MatchOptions) normalize(s string) string {
+	switch o.Unicode {
+	case NFC:
+		return norm.NFC.String(s)
+	case NFKC:
+		return norm.NFKC.String(s)
+	default:
+		return s
+	}
+}
+
+// canonical returns the pattern in the form used for ranking and conflict
+// detection, so that patterns differing only in ways the options ignore
+// are treated as the same pattern
+func (o MatchOptions) canonical(pattern string) string {
+	pattern = o.normalize(pattern)
+	if o.CaseInsensitive {
+		pattern = strings.ToLower(pattern)
+	}
+	if o.NormalizeWhitespace {
+		pattern = strings.Join(strings.FieldsFunc(pattern, isSpace), " ")
+	}
+	return pattern
+}
+
+// compile converts a wildcard pattern into a regular expression that
+// honours the options
+func (o MatchOptions) compile(pattern string) *regexp.Regexp {
+	return regexp.MustCompile(patternToRegex(o.normalize(pattern), o))
+}
+
+// patternToRegex converts a wildcard pattern to a regex pattern
+func patternToRegex(pattern string, opts MatchOptions) string {
+	var regexBuilder strings.Builder
+
+	var flags string
+	if opts.CaseInsensitive {
+		flags += "i"
+	}
+	if opts.DotAll {
+		flags += "s"
+	}
+	if flags != "" {
+		regexBuilder.WriteString("(?" + flags + ")")
+	}
+
+	regexBuilder.WriteString("^")
+	if opts.NormalizeWhitespace {
+		pattern = strings.TrimFunc(pattern, isSpace)
+		regexBuilder.WriteString(whitespaceClass + "*")
+	}
+
+	// Replace * with a capture group
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		regexBuilder.WriteString(quoteLiteral(part, opts))
+		if i < len(parts)-1 {
+			regexBuilder.WriteString("(.*?)")
+		}
+	}
+
+	if opts.NormalizeWhitespace {
+		regexBuilder.WriteString(whitespaceClass + "*")
+	}
+	regexBuilder.WriteString("$")
+	return regexBuilder.String()
+}
+
+// quoteLiteral escapes a literal part of a pattern, turning whitespace runs
+// into a flexible whitespace match when whitespace is normalized
+func quoteLiteral(literal string, opts MatchOptions) string {
+	if !opts.NormalizeWhitespace {
+		return regexp.QuoteMeta(literal)
+	}
+
+	var b strings.Builder
+	inSpace := false
+	start := 0
+	for i, r := range literal {
+		if isSpace(r) {
+			if !inSpace {
+				b.WriteString(regexp.QuoteMeta(literal[start:i]))
+				b.WriteString(whitespaceClass + "+")
+				inSpace = true
+			}
+			continue
+		}
+		if inSpace {
+			start = i
+			inSpace = false
+		}
+	}
+	if !inSpace {
+		b.WriteString(regexp.QuoteMeta(literal[start:]))
+	}
+	return b.String()
+}
+
+// isSpace reports whether r is matched by whitespaceClass
+func isSpace(r rune) bool {
+	return unicode.IsSpace(r) || unicode.Is(unicode.Z, r)
+}
@@ -0,0 +1,164 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Meta describes a route for help output, the API and fallbacks that pick
+// routes without a literal pattern match
+type Meta struct {
+	// Description says what the route does in one sentence
+	Description string
+
+	// Usage shows how to call the route with named parameters, for example
+	// "translate [text] to [language]". Defaults to the pattern.
+	Usage string
+
+	// Examples are prompts the route is meant to handle
+	Examples []string
+
+	// Category is the help section the route is listed under. Defaults to
+	// the description or name of the route's group.
+	Category string
+
+	// Hidden keeps the route out of help output, route listings and the
+	// Classifier's choices. It can still be matched by its pattern.
+	Hidden bool
+}
+
+// WithMeta attaches metadata to a route
+func WithMeta(meta Meta) RouteOption {
+	return func(route *Route) {
+		route.Meta = meta
+	}
+}
+
+// Usage returns the route's usage string, falling back to its pattern
+func (route *Route) Usage() string {
+	if route.Meta.Usage != "" {
+		return route.Meta.Usage
+	}
+	return route.Pattern
+}
+
+// Category returns the help section the route belongs to
+func (route *Route) Category() string {
+	switch {
+	case route.Meta.Category != "":
+		return route.Meta.Category
+	case route.group != nil && route.group.Description() != "":
+		return route.group.Description()
+	case route.group != nil && route.group.Name() != "":
+		return route.group.Name()
+	default:
+		return "Commands"
+	}
+}
+
+// visibleRoutes returns the routes that are not hidden, in registration
+// order
+func (r *Router) visibleRoutes() []*Route {
+	var routes []*Route
+	for _, route := range r.inRegistrationOrder() {
+		if !route.Meta.Hidden {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// Help returns a listing of every visible route, grouped into sections by
+// category in registration order
+func (r *Router) Help() string {
+	var categories []string
+	sections := make(map[string][]*Route)
+	for _, route := range r.visibleRoutes() {
+		category := route.Category()
+		if _, exists := sections[category]; !exists {
+			categories = append(categories, category)
+		}
+		sections[category] = append(sections[category], route)
+	}
+
+	var b strings.Builder
+	for i, category := range categories {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s:\n", category)
+		for _, route := range sections[category] {
+			fmt.Fprintf(&b, "- %q", route.Usage())
+			if route.Meta.Description != "" {
+				fmt.Fprintf(&b, " - %s", route.Meta.Description)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// HelpFor returns detailed help for the command named by query. The query
+// can be a pattern or usage string, a prompt the route would match, or the
+// first words of one or more commands. It returns false if nothing fits.
+func (r *Router) HelpFor(query string) (string, bool) {
+	query = strings.TrimSpace(query)
+	routes := r.visibleRoutes()
+
+	// An exact pattern or usage string names a single route
+	for _, route := range routes {
+		if strings.EqualFold(route.Pattern, query) || strings.EqualFold(route.Usage(), query) {
+			return routeHelp(route), true
+		}
+	}
+
+	// A full prompt is explained by the route that would handle it
+	for _, route := range r.Routes() {
+		if !route.Meta.Hidden && route.match(query) != nil {
+			return routeHelp(route), true
+		}
+	}
+
+	// Otherwise list every command starting with the query
+	var matches []*Route
+	for _, route := range routes {
+		if strings.HasPrefix(strings.ToLower(route.Pattern), strings.ToLower(query)) {
+			matches = append(matches, route)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", false
+	case 1:
+		return routeHelp(matches[0]), true
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Commands starting with %q:\n", query)
+	for _, route := range matches {
+		fmt.Fprintf(&b, "- %q", route.Usage())
+		if route.Meta.Description != "" {
+			fmt.Fprintf(&b, " - %s", route.Meta.Description)
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), true
+}
+
+// routeHelp formats the detail view of a single route
+func routeHelp(route *Route) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", route.Usage())
+	if route.Meta.Description != "" {
+		fmt.Fprintf(&b, "\n%s\n", route.Meta.Description)
+	}
+	fmt.Fprintf(&b, "\nCategory: %s\n", route.Category())
+	fmt.Fprintf(&b, "Pattern: %s\n", route.Pattern)
+	if len(route.Meta.Examples) > 0 {
+		b.WriteString("\nExamples:\n")
+		for _, example := range route.Meta.Examples {
+			fmt.Fprintf(&b, "- %q\n", example)
+		}
+	}
+	return b.String()
+}
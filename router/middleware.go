@@ -0,0 +1,114 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+	"unicode/utf8"
+)
+
+// Middleware wraps a Handler with cross-cutting behaviour. It may run code
+// before and after calling next, modify the context, or return early
+// without calling next at all.
+type Middleware func(next Handler) Handler
+
+// Chain composes middleware so that the first one is the outermost
+func Chain(middleware ...Middleware) Middleware {
+	return func(next Handler) Handler {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			next = middleware[i](next)
+		}
+		return next
+	}
+}
+
+// WithMiddleware attaches middleware to a single route. It runs inside any
+// router and group middleware.
+func WithMiddleware(middleware ...Middleware) RouteOption {
+	return func(route *Route) {
+		route.middleware = append(route.middleware, middleware...)
+	}
+}
+
+// ErrInputTooLong is returned by MaxInputLength when a prompt exceeds the
+// configured limit
+var ErrInputTooLong = errors.New("input too long")
+
+// ErrPanic is wrapped by the error Recover returns for a panicking handler
+var ErrPanic = errors.New("handler panicked")
+
+// Logging logs the matched pattern, prompt length, duration and outcome of
+// every request
+func Logging() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			start := time.Now()
+			err := next(ctx)
+			duration := time.Since(start)
+			if err != nil {
+				log.Printf("router: %q (%d chars) failed after %s: %v", ctx.MatchedPattern, len(ctx.OriginalPrompt), duration, err)
+			} else {
+				log.Printf("router: %q (%d chars) handled in %s", ctx.MatchedPattern, len(ctx.OriginalPrompt), duration)
+			}
+			return err
+		}
+	}
+}
+
+// Timing measures how long the rest of the chain takes and passes the
+// duration to record, whether or not the handler succeeded
+func Timing(record func(ctx *Context, duration time.Duration)) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			start := time.Now()
+			err := next(ctx)
+			record(ctx, time.Since(start))
+			return err
+		}
+	}
+}
+
+// Recover turns a panic in the rest of the chain into an error wrapping
+// ErrPanic, so one faulty handler cannot take down the server
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) (err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					log.Printf("router: panic handling %q: %v\n%s", ctx.MatchedPattern, v, debug.Stack())
+					err = fmt.Errorf("%w: %v", ErrPanic, v)
+				}
+			}()
+			return next(ctx)
+		}
+	}
+}
+
+// MaxInputLength rejects prompts longer than limit characters with an error
+// wrapping ErrInputTooLong
+func MaxInputLength(limit int) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			if n := utf8.RuneCountInString(ctx.OriginalPrompt); n > limit {
+				return fmt.Errorf("%w: %d characters, limit is %d", ErrInputTooLong, n, limit)
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// Decorate rewrites the response after a successful handler, for example to
+// add a header or footer
+func Decorate(decorate func(ctx *Context, response string) string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			if err := next(ctx); err != nil {
+				return err
+			}
+			ctx.Response = decorate(ctx, ctx.Response)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package router
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// DidYouMean returns a not-found handler that fails with a CodeNotFound
+// *Error suggesting up to limit commands. Routes are suggested when the
+// literal text before their first wildcard is within a few typos of the
+// start of the prompt, closest first. Hidden routes are never suggested.
+func DidYouMean(limit int) Handler {
+	return func(ctx *Context) error {
+		suggestions := ctx.table.suggest(ctx.OriginalPrompt, limit)
+		if len(suggestions) == 0 {
+			return &Error{Code: CodeNotFound, Err: ErrNoRoute}
+		}
+
+		quoted := make([]string, len(suggestions))
+		for i, suggestion := range suggestions {
+			quoted[i] = fmt.Sprintf("%q", suggestion)
+		}
+		return &Error{
+			Code:        CodeNotFound,
+			Message:     fmt.Sprintf("%v; did you mean %s?", ErrNoRoute, strings.Join(quoted, " or ")),
+			Suggestions: suggestions,
+			Err:         ErrNoRoute,
+		}
+	}
+}
+
+// Forward returns a not-found handler that hands the whole prompt to the
+// route registered with pattern, such as "chat *". The prompt fills the
+// route's first wildcard; any others are left empty. The route runs with
+// its middleware as if it had matched.
+func Forward(pattern string) Handler {
+	return func(ctx *Context) error {
+		t := ctx.table
+		i := t.indexOf(pattern)
+		if i < 0 {
+			return Errorf(CodeInternal, "cannot forward to %q: %w", pattern, ErrRouteNotFound)
+		}
+
+		route := t.routes[i]
+		captures := make([]string, route.wildcards)
+		if len(captures) > 0 {
+			captures[0] = ctx.OriginalPrompt
+		}
+		forwarded, err := t.dispatch(ctx.ctx, route, ctx.OriginalPrompt, ctx.OriginalPrompt, captures, ctx.Input, nil)
+		ctx.MatchedPattern = forwarded.MatchedPattern
+		ctx.Provider = forwarded.Provider
+		ctx.Response = forwarded.Response
+		return err
+	}
+}
+
+// suggestion is a route close to an unmatched prompt
+type suggestion struct {
+	usage    string
+	distance int
+	seq      int
+}
+
+// suggest returns the usage of up to limit visible routes whose leading
+// literal is close to the start of the prompt
+func (t *table) suggest(prompt string, limit int) []string {
+	words := strings.Fields(strings.ToLower(prompt))
+	if len(words) == 0 {
+		return nil
+	}
+
+	var candidates []suggestion
+	seen := make(map[string]bool)
+	for _, route := range t.routes {
+		if route.Meta.Hidden || seen[route.Usage()] {
+			continue
+		}
+		literal := strings.Fields(strings.ToLower(strings.SplitN(route.Pattern, "*", 2)[0]))
+		if len(literal) == 0 {
+			continue
+		}
+
+		n := len(literal)
+		if n > len(words) {
+			n = len(words)
+		}
+		want := strings.Join(literal, " ")
+		distance := editDistance(strings.Join(words[:n], " "), want)
+		if distance > maxTypos(want) {
+			continue
+		}
+		seen[route.Usage()] = true
+		candidates = append(candidates, suggestion{usage: route.Usage(), distance: distance, seq: route.seq})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].seq < candidates[j].seq
+	})
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	suggestions := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		suggestions[i] = candidate.usage
+	}
+	return suggestions
+}
+
+// maxTypos is the largest edit distance at which a literal is still
+// considered a misspelling: one typo per three characters, at least one
+func maxTypos(literal string) int {
+	if n := utf8.RuneCountInString(literal) / 3; n > 1 {
+		return n
+	}
+	return 1
+}
+
+// editDistance returns the Levenshtein distance between a and b in runes
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
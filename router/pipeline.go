@@ -0,0 +1,287 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultMaxPipelineDepth is the maximum number of stages in a pipeline
+// unless changed with SetMaxPipelineDepth
+const DefaultMaxPipelineDepth = 5
+
+// ErrNoRoute is returned when no route or fallback handles a prompt
+var ErrNoRoute = errors.New("no matching route found for prompt")
+
+// ErrPipelineTooDeep is returned when a prompt chains more stages than the
+// router allows
+var ErrPipelineTooDeep = errors.New("pipeline too deep")
+
+// pipeDelimiter matches the explicit separator between pipeline stages, a
+// pipe surrounded by whitespace
+var pipeDelimiter = regexp.MustCompile(`[ \t]+\|[ \t]+`)
+
+// naturalDelimiter matches "then" / "and then" as a separate word, which
+// separates stages when natural pipelines are enabled
+var naturalDelimiter = regexp.MustCompile(`(?i)[\s,;]+(?:and[ \t]+)?then[ \t]+`)
+
+// Result is the outcome of processing a prompt
+type Result struct {
+	// Response is the output of the last stage
+	Response string `json:"response"`
+
+	// Stages records every stage that ran, in order. A prompt that is not a
+	// pipeline has a single stage.
+	Stages []Stage `json:"stages"`
+}
+
+// Stage records one step of processing a prompt
+type Stage struct {
+	Prompt     string `json:"prompt"`
+	Pattern    string `json:"pattern,omitempty"`
+	Input      string `json:"input,omitempty"`
+	Output     string `json:"output,omitempty"`
+	Provider   string `json:"provider,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// PipelineError reports which stage of a pipeline failed. Stages after it
+// are not run.
+type PipelineError struct {
+	// Stage is the 1-based index of the failing stage
+	Stage  int
+	Prompt string
+	Err    error
+}
+
+func (e *PipelineError) Error() string {
+	return fmt.Sprintf("pipeline stage %d (%q) failed: %v", e.Stage, e.Prompt, e.Err)
+}
+
+func (e *PipelineError) Unwrap() error {
+	return e.Err
+}
+
+// SetNaturalPipelines makes "then" and "and then" separate pipeline stages
+// like "|". It is off by default because these words are common in prose:
+// pasted text could otherwise run commands. Even when enabled, they never
+// split a prompt that a route matches as a whole, since the words are then
+// part of what its wildcards capture.
+func (r *Router) SetNaturalPipelines(enabled bool) {
+	r.update(func(t *table) error {
+		t.naturalPipelines = enabled
+		return nil
+	})
+}
+
+// SetMaxPipelineDepth sets the maximum number of stages a prompt may chain.
+// A depth of 1 or less disables pipelines, so every prompt is routed as a
+// whole.
+func (r *Router) SetMaxPipelineDepth(depth int) {
+	r.update(func(t *table) error {
+		t.maxPipelineDepth = depth
+		return nil
+	})
+}
+
+// Execute processes a prompt like Process and also reports each stage.
+//
+// A prompt such as "summarize <text> | translate to Spanish" runs as a
+// pipeline: each stage's response becomes the next stage's Context.Input.
+// A later stage may leave out one of its route's wildcards, which is then
+// filled with the input, so "translate to Spanish" runs the "translate * to
+// *" route on the previous output. A delimiter only splits the prompt when
+// the text after it can be routed; see also SetNaturalPipelines.
+//
+// The pipeline stops at the first failing stage and returns a
+// *PipelineError along with the stages run so far. Every stage uses the
+// route table as it was when Execute was called.
+func (r *Router) Execute(prompt string) (*Result, error) {
+	return r.ExecuteContext(context.Background(), prompt)
+}
+
+// ExecuteContext is Execute under ctx, which handlers get from
+// Context.Context. Once ctx is done, no further stage starts.
+func (r *Router) ExecuteContext(ctx context.Context, prompt string) (*Result, error) {
+	t := r.snapshot()
+	stages := t.splitPipeline(prompt)
+	if len(stages) > 1 && len(stages) > t.maxPipelineDepth {
+		return nil, fmt.Errorf("%w: %d stages, limit is %d", ErrPipelineTooDeep, len(stages), t.maxPipelineDepth)
+	}
+
+	result := &Result{}
+	input := ""
+	for i, stagePrompt := range stages {
+		if i > 0 && ctx.Err() != nil {
+			return result, &PipelineError{Stage: i + 1, Prompt: stagePrompt, Err: ctx.Err()}
+		}
+		stage, err := t.runStage(ctx, stagePrompt, input, i > 0)
+		result.Stages = append(result.Stages, stage)
+		if err != nil {
+			if len(stages) == 1 {
+				return result, err
+			}
+			return result, &PipelineError{Stage: i + 1, Prompt: stagePrompt, Err: err}
+		}
+		input = stage.Output
+	}
+
+	result.Response = input
+	return result, nil
+}
+
+// runStage routes a single stage. Piped stages may omit one wildcard, which
+// is filled with the input; the first stage may fall back to the router's
+// fallbacks. Prompts nothing handles go to the not-found handler, and
+// errors go through the error handler.
+func (t *table) runStage(parent context.Context, prompt, input string, piped bool) (stage Stage, err error) {
+	stage = Stage{Prompt: prompt, Input: input}
+	ctx := &Context{ctx: parent, OriginalPrompt: prompt, Input: input, table: t}
+	start := time.Now()
+	defer func() {
+		if err != nil && t.onError != nil {
+			err = t.onError(ctx, err)
+		}
+		stage.Pattern = ctx.MatchedPattern
+		stage.Provider = ctx.Provider
+		if err != nil {
+			stage.Error = err.Error()
+		} else {
+			stage.Output = ctx.Response
+		}
+		stage.DurationMs = time.Since(start).Milliseconds()
+	}()
+
+	route, matchedText, captures := t.find(prompt, input, piped)
+	var resolution *Resolution
+	if route == nil && !piped {
+		resolution, err = t.resolveFallback(prompt)
+		if err != nil {
+			return stage, err
+		}
+		if resolution != nil && resolution.Route == nil {
+			ctx.Response = resolution.Reply
+			return stage, nil
+		}
+		if resolution != nil {
+			route, matchedText, captures = resolution.Route, prompt, resolution.Captures
+		}
+	}
+	if route == nil {
+		if t.notFound == nil {
+			return stage, ErrNoRoute
+		}
+		return stage, t.notFound(ctx)
+	}
+
+	ctx, err = t.dispatch(parent, route, prompt, matchedText, captures, input, resolution)
+	return stage, err
+}
+
+// find returns the most specific route matching the prompt. For piped
+// stages, routes that match with one wildcard filled by the input are
+// considered after full matches.
+func (t *table) find(prompt, input string, piped bool) (*Route, string, []string) {
+	for _, route := range t.routes {
+		if matches := route.match(prompt); matches != nil {
+			return route, matches[0], matches[1:]
+		}
+	}
+	if !piped {
+		return nil, "", nil
+	}
+
+	for _, route := range t.routes {
+		if captures, ok := route.matchPiped(prompt, input); ok {
+			return route, prompt, captures
+		}
+	}
+	return nil, "", nil
+}
+
+// routable reports whether a pipeline stage would find a route
+func (t *table) routable(prompt string) bool {
+	route, _, _ := t.find(prompt, "", true)
+	return route != nil
+}
+
+// splitPipeline splits a prompt into stages at delimiters whose following
+// text can be routed
+func (t *table) splitPipeline(prompt string) []string {
+	if t.maxPipelineDepth <= 1 {
+		return []string{prompt}
+	}
+
+	delimiters := pipeDelimiter.FindAllStringIndex(prompt, -1)
+	if t.naturalPipelines {
+		if route, _, _ := t.find(prompt, "", false); route == nil {
+			delimiters = append(delimiters, naturalDelimiter.FindAllStringIndex(prompt, -1)...)
+			sort.Slice(delimiters, func(i, j int) bool {
+				return delimiters[i][0] < delimiters[j][0]
+			})
+		}
+	}
+	if len(delimiters) == 0 {
+		return []string{prompt}
+	}
+
+	var stages []string
+	start := 0
+	for i, delimiter := range delimiters {
+		end := len(prompt)
+		if i+1 < len(delimiters) {
+			end = delimiters[i+1][0]
+		}
+		next := strings.TrimSpace(prompt[delimiter[1]:end])
+		if delimiter[0] <= start || next == "" || !t.routable(next) {
+			continue
+		}
+		stages = append(stages, strings.TrimSpace(prompt[start:delimiter[0]]))
+		start = delimiter[1]
+	}
+	return append(stages, strings.TrimSpace(prompt[start:]))
+}
+
+// matchPiped matches the prompt against the route with one wildcard left
+// out, returning the captures with the input in that wildcard's place
+func (route *Route) matchPiped(prompt, input string) ([]string, bool) {
+	for i, variant := range route.variants {
+		matches := variant.FindStringSubmatch(route.options.normalize(prompt))
+		if matches == nil {
+			continue
+		}
+		captures := make([]string, 0, route.wildcards)
+		captures = append(captures, matches[1:i+1]...)
+		captures = append(captures, input)
+		captures = append(captures, matches[i+1:]...)
+		return captures, true
+	}
+	return nil, false
+}
+
+// compileVariants compiles one regex per wildcard of the pattern, each with
+// that wildcard removed
+func (o MatchOptions) compileVariants(pattern string) []*regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	variants := make([]*regexp.Regexp, 0, len(parts)-1)
+	for i := 0; i < len(parts)-1; i++ {
+		left := strings.TrimRight(parts[i], " ")
+		right := strings.TrimLeft(parts[i+1], " ")
+		joined := left + right
+		if left != "" && right != "" {
+			joined = left + " " + right
+		}
+
+		variant := make([]string, 0, len(parts)-1)
+		variant = append(variant, parts[:i]...)
+		variant = append(variant, joined)
+		variant = append(variant, parts[i+2:]...)
+		variants = append(variants, o.compile(strings.Join(variant, "*")))
+	}
+	return variants
+}
@@ -1,24 +1,90 @@
 package router
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"regexp"
-	"strings"
+	"sort"
+	"sync"
+	"sync/atomic"
 )
 
+// ErrRouteNotFound is returned when removing or replacing a pattern that is
+// not registered
+var ErrRouteNotFound = errors.New("route not found")
+
 // Handler is a function that processes a matched route
 type Handler func(*Context) error
 
 // Route represents a pattern and its associated handler
 type Route struct {
-	Pattern     string
-	Handler     Handler
+	Pattern      string
+	Handler      Handler
 	RegexPattern *regexp.Regexp
+
+	// Priority overrides the computed specificity; higher values win
+	Priority int
+
+	// Meta describes the route for help output and fallbacks
+	Meta Meta
+
+	matchOptions *MatchOptions
+	options      MatchOptions
+	middleware   []Middleware
+	group        *Group
+	inherited    []Middleware
+	canonical    string
+	tokens       []token
+	literals     int
+	wildcards    int
+	seq          int
+	variants     []*regexp.Regexp
+}
+
+// RouteOption configures a route at registration time
+type RouteOption func(*Route)
+
+// WithPriority sets an explicit priority for the route. Routes with a
+// higher priority are tried before more specific routes with a lower one.
+func WithPriority(priority int) RouteOption {
+	return func(route *Route) {
+		route.Priority = priority
+	}
 }
 
-// Router manages routes and processes incoming prompts
+// Router manages routes and processes incoming prompts. It is safe for
+// concurrent use: every change publishes a new copy of the route table, so
+// prompts already being processed finish against the table they started
+// with.
 type Router struct {
-	routes []*Route
+	// mu serializes changes to the table
+	mu           sync.Mutex
+	table        atomic.Pointer[table]
+	matchOptions MatchOptions
+}
+
+// table is a snapshot of the routes and settings of a router. Published
+// tables are never modified.
+type table struct {
+	routes     []*Route
+	seq        int
+	middleware []Middleware
+	fallbacks  []Fallback
+	notFound   Handler
+	onError    ErrorHandler
+
+	maxPipelineDepth int
+	naturalPipelines bool
+}
+
+// clone returns a copy of the table that can be modified
+func (t *table) clone() *table {
+	next := *t
+	next.routes = append([]*Route(nil), t.routes...)
+	next.middleware = append([]Middleware(nil), t.middleware...)
+	next.fallbacks = append([]Fallback(nil), t.fallbacks...)
+	return &next
 }
 
 // Context contains information about the current request
@@ -28,82 +94,370 @@ type Context struct {
 	MatchedText    string
 	Captures       []string
 	Response       string
+
+	// Input is the output of the previous stage when the prompt is part of
+	// a pipeline, and empty otherwise
+	Input string
+
+	// Provider names the AI provider that produced the response, if the
+	// handler or a middleware sets it
+	Provider string
+
+	ctx    context.Context
+	values map[string]interface{}
+	group  *Group
+	table  *table
+}
+
+// Context returns the context the prompt is processed under, which is
+// canceled when the caller of ExecuteContext stops waiting, such as when
+// an API client disconnects. Handlers pass it to long running work.
+func (c *Context) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// Set stores a value on the context for later middleware and the handler
+func (c *Context) Set(key string, value interface{}) {
+	if c.values == nil {
+		c.values = make(map[string]interface{})
+	}
+	c.values[key] = value
 }
 
-// New creates a new Router instance
+// Get returns a value stored with Set, falling back to the defaults of the
+// route's group. It returns nil if neither has the key.
+func (c *Context) Get(key string) interface{} {
+	if value, ok := c.values[key]; ok {
+		return value
+	}
+	if c.group != nil {
+		if value, ok := c.group.lookup(key); ok {
+			return value
+		}
+	}
+	return nil
+}
+
+// Group returns the name of the group the matched route belongs to, or an
+// empty string if it was registered directly on the router
+func (c *Context) Group() string {
+	if c.group == nil {
+		return ""
+	}
+	return c.group.Name()
+}
+
+// New creates a new Router instance using DefaultMatchOptions
 func New() *Router {
-	return &Router{
-		routes: make([]*Route, 0),
+	return NewWithMatchOptions(DefaultMatchOptions())
+}
+
+// NewWithMatchOptions creates a new Router whose routes match prompts using
+// opts unless a route overrides them with WithMatchOptions
+func NewWithMatchOptions(opts MatchOptions) *Router {
+	r := &Router{matchOptions: opts}
+	r.table.Store(&table{
+		routes:           make([]*Route, 0),
+		maxPipelineDepth: DefaultMaxPipelineDepth,
+	})
+	return r
+}
+
+// snapshot returns the current table
+func (r *Router) snapshot() *table {
+	return r.table.Load()
+}
+
+// update applies change to a copy of the table and publishes the copy,
+// unless change returns an error
+func (r *Router) update(change func(t *table) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.updateLocked(change)
+}
+
+// updateLocked is update for callers that already hold r.mu
+func (r *Router) updateLocked(change func(t *table) error) error {
+	next := r.table.Load().clone()
+	if err := change(next); err != nil {
+		return err
 	}
+	r.table.Store(next)
+	return nil
 }
 
-// Handle registers a new route with a pattern and handler
-func (r *Router) Handle(pattern string, handler Handler) {
-	// Convert the pattern to a regex
-	regexPattern := patternToRegex(pattern)
-	compiledRegex := regexp.MustCompile(regexPattern)
-	
-	r.routes = append(r.routes, &Route{
-		Pattern:      pattern,
-		Handler:      handler,
-		RegexPattern: compiledRegex,
+// Use appends middleware that runs for every route, outermost first. It
+// also applies to routes registered earlier.
+func (r *Router) Use(middleware ...Middleware) {
+	r.update(func(t *table) error {
+		t.middleware = append(t.middleware, middleware...)
+		return nil
 	})
 }
 
-// Process takes a prompt and routes it to the appropriate handler
-func (r *Router) Process(prompt string) (string, error) {
-	for _, route := range r.routes {
-		matches := route.RegexPattern.FindStringSubmatch(prompt)
-		if matches != nil {
-			ctx := &Context{
-				OriginalPrompt: prompt,
-				MatchedPattern: route.Pattern,
-				MatchedText:    matches[0],
-				Captures:       matches[1:],
-			}
-			
-			err := route.Handler(ctx)
-			if err != nil {
-				return "", err
-			}
-			
-			return ctx.Response, nil
+// Handle registers a new route with a pattern and handler. It panics if the
+// pattern conflicts with an already registered route; use Register to get
+// the conflict back as an error instead.
+func (r *Router) Handle(pattern string, handler Handler, opts ...RouteOption) {
+	if err := r.Register(pattern, handler, opts...); err != nil {
+		panic(err)
+	}
+}
+
+// Register registers a new route with a pattern and handler. If the route
+// is ambiguous with, unreachable behind, or shadows an existing route, a
+// *ConflictError is returned and the route table is left unchanged.
+func (r *Router) Register(pattern string, handler Handler, opts ...RouteOption) error {
+	route, err := r.newRoute(pattern, handler, opts)
+	if err != nil {
+		return err
+	}
+
+	return r.update(func(t *table) error {
+		route.seq = t.seq
+		if err := t.add(route); err != nil {
+			return err
+		}
+		t.seq++
+		return nil
+	})
+}
+
+// Remove unregisters the route with the pattern, written as it was
+// registered or in any form that normalizes to the same pattern
+func (r *Router) Remove(pattern string) error {
+	return r.update(func(t *table) error {
+		i := t.indexOf(pattern)
+		if i < 0 {
+			return fmt.Errorf("%w: %q", ErrRouteNotFound, pattern)
+		}
+		t.routes = append(t.routes[:i], t.routes[i+1:]...)
+		return nil
+	})
+}
+
+// Replace swaps the handler and options of a registered route in one step,
+// so no prompt sees the pattern missing. The new route keeps the group and
+// the place in help output of the old one. It fails with ErrRouteNotFound
+// if the pattern is not registered, or with a *ConflictError if the new
+// options make it conflict with another route.
+func (r *Router) Replace(pattern string, handler Handler, opts ...RouteOption) error {
+	return r.update(func(t *table) error {
+		i := t.indexOf(pattern)
+		if i < 0 {
+			return fmt.Errorf("%w: %q", ErrRouteNotFound, pattern)
+		}
+		old := t.routes[i]
+
+		route, err := r.newRoute(old.Pattern, handler, append([]RouteOption{inGroup(old.group)}, opts...))
+		if err != nil {
+			return err
+		}
+		route.seq = old.seq
+
+		t.routes = append(t.routes[:i], t.routes[i+1:]...)
+		return t.add(route)
+	})
+}
+
+// newRoute creates and compiles a route
+func (r *Router) newRoute(pattern string, handler Handler, opts []RouteOption) (*Route, error) {
+	if handler == nil {
+		return nil, fmt.Errorf("router: nil handler for pattern %q", pattern)
+	}
+
+	route := &Route{
+		Pattern: pattern,
+		Handler: handler,
+	}
+	for _, opt := range opts {
+		opt(route)
+	}
+
+	route.options = r.matchOptions
+	if route.matchOptions != nil {
+		route.options = *route.matchOptions
+	}
+
+	// Convert the pattern to a regex
+	route.RegexPattern = route.options.compile(pattern)
+	route.variants = route.options.compileVariants(pattern)
+	route.canonical = route.options.canonical(pattern)
+	route.tokens = tokenize(route.canonical)
+	route.literals, route.wildcards = countTokens(route.tokens)
+	return route, nil
+}
+
+// add inserts a route after checking it against every route in the table
+func (t *table) add(route *Route) error {
+	for _, existing := range t.routes {
+		if err := checkConflict(existing, route); err != nil {
+			return err
+		}
+	}
+	if route.group != nil {
+		route.inherited = route.group.allMiddleware()
+	}
+	t.routes = append(t.routes, route)
+	sortRoutes(t.routes)
+	return nil
+}
+
+// indexOf returns the position of the route registered with the pattern,
+// or -1
+func (t *table) indexOf(pattern string) int {
+	for i, route := range t.routes {
+		if route.Pattern == pattern {
+			return i
 		}
 	}
-	
-	return "", errors.New("no matching route found for prompt")
-}
-
-// patternToRegex converts a wildcard pattern to a regex pattern
-func patternToRegex(pattern string) string {
-	// Replace * with a capture group
-	parts := strings.Split(pattern, "*")
-	if len(parts) == 1 {
-		// No wildcards, exact match
-		return "^" + regexp.QuoteMeta(pattern) + "$"
-	}
-	
-	var regexBuilder strings.Builder
-	regexBuilder.WriteString("^")
-	
-	for i, part := range parts {
-		regexBuilder.WriteString(regexp.QuoteMeta(part))
-		if i < len(parts)-1 {
-			regexBuilder.WriteString("(.*?)")
+	for i, route := range t.routes {
+		if route.canonical == route.options.canonical(pattern) {
+			return i
 		}
 	}
-	
-	regexBuilder.WriteString("$")
-	return regexBuilder.String()
+	return -1
 }
 
-// GetRoutes returns information about registered routes
-func (r *Router) GetRoutes() []map[string]string {
-	routes := make([]map[string]string, len(r.routes))
-	for i, route := range r.routes {
-		routes[i] = map[string]string{
-			"pattern": route.Pattern,
+// Process takes a prompt and routes it to the appropriate handler. Routes
+// are tried from most to least specific, so the first match is the best one.
+// If no pattern matches, the router's fallbacks are consulted in order.
+// Prompts that chain several commands run as a pipeline; see Execute.
+func (r *Router) Process(prompt string) (string, error) {
+	result, err := r.Execute(prompt)
+	if err != nil {
+		return "", err
+	}
+	return result.Response, nil
+}
+
+// resolveFallback consults the fallbacks in order and returns the first
+// resolution, or nil if none of them can handle the prompt
+func (t *table) resolveFallback(prompt string) (*Resolution, error) {
+	for _, fallback := range t.fallbacks {
+		resolution, err := fallback.Resolve(prompt, t.routes)
+		if err != nil {
+			return nil, err
+		}
+		if resolution != nil {
+			return resolution, nil
 		}
 	}
+	return nil, nil
+}
+
+// dispatch runs the route's handler chain for a matched prompt
+func (t *table) dispatch(parent context.Context, route *Route, prompt, matchedText string, captures []string, input string, resolution *Resolution) (*Context, error) {
+	ctx := &Context{
+		ctx:            parent,
+		OriginalPrompt: prompt,
+		MatchedPattern: route.Pattern,
+		MatchedText:    matchedText,
+		Captures:       captures,
+		Input:          input,
+		group:          route.group,
+		table:          t,
+	}
+	if resolution != nil {
+		ctx.Set(FallbackKey, resolution)
+	}
+
+	err := t.handler(route)(ctx)
+	return ctx, err
+}
+
+// handler wraps the route's handler in router, group and route middleware
+func (t *table) handler(route *Route) Handler {
+	middleware := make([]Middleware, 0, len(t.middleware)+len(route.middleware))
+	middleware = append(middleware, t.middleware...)
+	middleware = append(middleware, route.inherited...)
+	middleware = append(middleware, route.middleware...)
+	return Chain(middleware...)(route.Handler)
+}
+
+// match returns the full match and captures of the prompt against the
+// route, or nil if the route does not match
+func (route *Route) match(prompt string) []string {
+	return route.RegexPattern.FindStringSubmatch(route.options.normalize(prompt))
+}
+
+// sortRoutes orders routes from most to least specific
+func sortRoutes(routes []*Route) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		return compareRoutes(routes[i], routes[j]) > 0
+	})
+}
+
+// Routes returns copies of the registered routes, most specific first.
+// Changing them does not change the router.
+func (r *Router) Routes() []*Route {
+	t := r.snapshot()
+	routes := make([]*Route, len(t.routes))
+	for i, route := range t.routes {
+		copied := *route
+		routes[i] = &copied
+	}
 	return routes
 }
+
+// RouteInfo describes a registered route
+type RouteInfo struct {
+	Pattern     string   `json:"pattern"`
+	Usage       string   `json:"usage"`
+	Description string   `json:"description,omitempty"`
+	Examples    []string `json:"examples,omitempty"`
+	Category    string   `json:"category"`
+	Priority    int      `json:"priority,omitempty"`
+}
+
+// RouteGroupInfo describes a group of routes. Routes registered directly on
+// the router are listed under a group with an empty name.
+type RouteGroupInfo struct {
+	Name        string      `json:"name"`
+	Prefix      string      `json:"prefix,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Routes      []RouteInfo `json:"routes"`
+}
+
+// inRegistrationOrder returns the routes in the order they were registered
+func (r *Router) inRegistrationOrder() []*Route {
+	ordered := r.Routes()
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].seq < ordered[j].seq
+	})
+	return ordered
+}
+
+// GetRoutes returns information about registered routes grouped by the
+// group they were registered through. Groups and the routes within them are
+// listed in registration order. Hidden routes are left out.
+func (r *Router) GetRoutes() []RouteGroupInfo {
+	var groups []RouteGroupInfo
+	index := make(map[*Group]int)
+	for _, route := range r.visibleRoutes() {
+		i, ok := index[route.group]
+		if !ok {
+			info := RouteGroupInfo{}
+			if route.group != nil {
+				info.Name = route.group.Name()
+				info.Prefix = route.group.Prefix()
+				info.Description = route.group.Description()
+			}
+			i = len(groups)
+			index[route.group] = i
+			groups = append(groups, info)
+		}
+		groups[i].Routes = append(groups[i].Routes, RouteInfo{
+			Pattern:     route.Pattern,
+			Usage:       route.Usage(),
+			Description: route.Meta.Description,
+			Examples:    route.Meta.Examples,
+			Category:    route.Category(),
+			Priority:    route.Priority,
+		})
+	}
+	return groups
+}
@@ -0,0 +1,188 @@
+package router_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/aldotobing/neurogo/router"
+)
+
+// reply returns a handler responding with text
+func reply(text string) router.Handler {
+	return func(ctx *router.Context) error {
+		ctx.Response = text
+		return nil
+	}
+}
+
+func TestConcurrentRouteChanges(t *testing.T) {
+	r := router.New()
+	r.Handle("stable *", reply("v0"))
+
+	const writers, readers, rounds = 4, 8, 200
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				pattern := fmt.Sprintf("temp %d %d *", w, i)
+				r.Handle(pattern, reply(pattern))
+				if err := r.Replace("stable *", reply(fmt.Sprintf("v%d", w+1))); err != nil {
+					t.Errorf("Replace failed: %v", err)
+				}
+				if err := r.Remove(pattern); err != nil {
+					t.Errorf("Remove failed: %v", err)
+				}
+			}
+		}(w)
+	}
+
+	valid := map[string]bool{"v0": true}
+	for w := 0; w < writers; w++ {
+		valid[fmt.Sprintf("v%d", w+1)] = true
+	}
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				response, err := r.Process("stable hello")
+				if err != nil {
+					t.Errorf("Process failed: %v", err)
+					return
+				}
+				if !valid[response] {
+					t.Errorf("Process responded %q", response)
+				}
+				if _, err := r.Execute("stable hello | stable"); err != nil {
+					t.Errorf("Execute failed: %v", err)
+					return
+				}
+				r.Routes()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if routes := r.Routes(); len(routes) != 1 {
+		t.Errorf("%d routes left, want 1", len(routes))
+	}
+}
+
+func TestInFlightPromptKeepsSnapshot(t *testing.T) {
+	r := router.New()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r.Handle("first *", func(ctx *router.Context) error {
+		close(started)
+		<-release
+		ctx.Response = "one"
+		return nil
+	})
+	r.Handle("second *", func(ctx *router.Context) error {
+		ctx.Response = ctx.Input + " two"
+		return nil
+	})
+
+	type outcome struct {
+		result *router.Result
+		err    error
+	}
+	done := make(chan outcome)
+	go func() {
+		result, err := r.Execute("first x | second")
+		done <- outcome{result, err}
+	}()
+
+	<-started
+	if err := r.Remove("second *"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := r.Replace("first *", reply("replaced")); err != nil {
+		t.Fatalf("Replace failed: %v", err)
+	}
+	close(release)
+
+	o := <-done
+	if o.err != nil {
+		t.Fatalf("in-flight pipeline failed: %v", o.err)
+	}
+	if o.result.Response != "one two" {
+		t.Errorf("in-flight pipeline responded %q, want %q", o.result.Response, "one two")
+	}
+
+	if _, err := r.Process("second y"); !errors.Is(err, router.ErrNoRoute) {
+		t.Errorf("removed route still handles prompts: %v", err)
+	}
+	if response, _ := r.Process("first x"); response != "replaced" {
+		t.Errorf("replaced route responded %q, want %q", response, "replaced")
+	}
+}
+
+// suffix returns middleware appending text to the response
+func suffix(text string) router.Middleware {
+	return func(next router.Handler) router.Handler {
+		return func(ctx *router.Context) error {
+			err := next(ctx)
+			ctx.Response += text
+			return err
+		}
+	}
+}
+
+func TestGroupUseAfterRegister(t *testing.T) {
+	r := router.New()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	git := r.Group("git", router.WithPrefix("git"))
+	git.Handle("wait *", func(ctx *router.Context) error {
+		if ctx.Captures[0] == "block" {
+			close(started)
+			<-release
+		}
+		ctx.Response = "waited"
+		return nil
+	})
+	git.Group("remote", router.WithPrefix("remote")).Handle("add *", reply("added"))
+
+	done := make(chan string)
+	go func() {
+		response, _ := r.Process("git wait block")
+		done <- response
+	}()
+
+	<-started
+	git.Use(suffix("!"))
+	close(release)
+
+	if response := <-done; response != "waited" {
+		t.Errorf("in-flight prompt responded %q, want %q", response, "waited")
+	}
+	for prompt, want := range map[string]string{
+		"git wait now":          "waited!",
+		"git remote add origin": "added!",
+	} {
+		if response, _ := r.Process(prompt); response != want {
+			t.Errorf("Process(%q) = %q, want %q", prompt, response, want)
+		}
+	}
+}
+
+func TestRoutesReturnsCopies(t *testing.T) {
+	r := router.New()
+	r.Handle("greet *", reply("hi"), router.WithPriority(1))
+
+	route := r.Routes()[0]
+	route.Handler = reply("changed")
+	route.Priority = 5
+
+	if response, _ := r.Process("greet bob"); response != "hi" {
+		t.Errorf("Process responded %q after changing a listed route, want %q", response, "hi")
+	}
+	if priority := r.Routes()[0].Priority; priority != 1 {
+		t.Errorf("Priority = %d after changing a listed route, want 1", priority)
+	}
+}
@@ -0,0 +1,258 @@
+package router
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aldotobing/neurogo/vector"
+)
+
+// DefaultSemanticThreshold is the cosine similarity a SemanticRouter needs
+// to dispatch when its Threshold is zero
+const DefaultSemanticThreshold = 0.8
+
+// DefaultSemanticTopK is the number of candidates a SemanticRouter reports
+// when its TopK is zero
+const DefaultSemanticTopK = 3
+
+// Embedder turns text into embedding vectors. providers.Embedder satisfies
+// it.
+type Embedder interface {
+	Embed(texts []string, model string) ([][]float32, error)
+}
+
+// Candidate is a route considered by a fallback, with its score
+type Candidate struct {
+	Pattern string  `json:"pattern"`
+	Example string  `json:"example,omitempty"`
+	Score   float64 `json:"score"`
+}
+
+// SemanticRouter is a Fallback that matches unmatched prompts to routes by
+// the cosine similarity between the prompt and each route's example
+// utterances. Examples are embedded once, the first time a route is seen.
+//
+// A route without wildcards is dispatched with no captures and a route with
+// one wildcard receives the whole prompt as its capture. Values for routes
+// with several wildcards cannot be recovered from a similarity match, so
+// the user is asked to rephrase instead.
+type SemanticRouter struct {
+	// Embedder produces the embeddings for examples and prompts
+	Embedder Embedder
+
+	// Model is the embedding model; empty selects the provider's default
+	Model string
+
+	// Threshold is the minimum similarity needed to dispatch
+	Threshold float64
+
+	// TopK is the number of candidates reported for debugging
+	TopK int
+
+	mu      sync.Mutex
+	index   *vector.Index
+	indexed map[string]*indexedRoute
+}
+
+// indexedRoute records the texts embedded for a pattern and the IDs of
+// their vectors
+type indexedRoute struct {
+	texts []string
+	ids   []string
+}
+
+// NewSemanticRouter creates a SemanticRouter using the embedder
+func NewSemanticRouter(embedder Embedder, model string) *SemanticRouter {
+	return &SemanticRouter{
+		Embedder: embedder,
+		Model:    model,
+		index:    vector.NewIndex(),
+		indexed:  make(map[string]*indexedRoute),
+	}
+}
+
+// Index embeds the examples of every route that has not been indexed yet.
+// routes are all the registered routes: examples of routes that were
+// removed since they were indexed, or whose examples changed, are dropped. Call it at
+// startup to avoid paying for the embeddings on the first unmatched
+// prompt.
+func (s *SemanticRouter) Index(routes []*Route) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		s.index = vector.NewIndex()
+		s.indexed = make(map[string]*indexedRoute)
+	}
+
+	current := make(map[string][]string, len(routes))
+	for _, route := range routes {
+		current[route.Pattern] = utterances(route)
+	}
+	for pattern, indexed := range s.indexed {
+		if texts, ok := current[pattern]; !ok || !equalStrings(texts, indexed.texts) {
+			s.index.Remove(indexed.ids...)
+			delete(s.indexed, pattern)
+		}
+	}
+
+	var texts []string
+	var items []vector.Item
+	var pending []*Route
+	for _, route := range routes {
+		if s.indexed[route.Pattern] != nil {
+			continue
+		}
+		pending = append(pending, route)
+		for i, text := range current[route.Pattern] {
+			texts = append(texts, text)
+			items = append(items, vector.Item{
+				ID:       route.Pattern + "#" + strconv.Itoa(i),
+				Metadata: map[string]string{"pattern": route.Pattern, "text": text},
+			})
+		}
+	}
+	if len(texts) == 0 {
+		return nil
+	}
+
+	vectors, err := s.Embedder.Embed(texts, s.Model)
+	if err != nil {
+		return fmt.Errorf("embedding route examples failed: %w", err)
+	}
+	if len(vectors) != len(items) {
+		return fmt.Errorf("embedding route examples failed: expected %d vectors, got %d", len(items), len(vectors))
+	}
+
+	for _, route := range pending {
+		s.indexed[route.Pattern] = &indexedRoute{texts: current[route.Pattern]}
+	}
+	for i := range items {
+		items[i].Vector = vectors[i]
+		indexed := s.indexed[items[i].Metadata["pattern"]]
+		indexed.ids = append(indexed.ids, items[i].ID)
+	}
+	s.index.Add(items...)
+	return nil
+}
+
+// Candidates returns the best scoring routes for the prompt, one entry per
+// route, best first
+func (s *SemanticRouter) Candidates(prompt string, routes []*Route) ([]Candidate, error) {
+	if err := s.Index(routes); err != nil {
+		return nil, err
+	}
+
+	vectors, err := s.Embedder.Embed([]string{prompt}, s.Model)
+	if err != nil {
+		return nil, fmt.Errorf("embedding prompt failed: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("embedding prompt failed: expected 1 vector, got %d", len(vectors))
+	}
+
+	topK := s.TopK
+	if topK <= 0 {
+		topK = DefaultSemanticTopK
+	}
+
+	live := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		live[route.Pattern] = true
+	}
+
+	var candidates []Candidate
+	seen := make(map[string]bool)
+	for _, result := range s.index.Search(vectors[0], 0) {
+		pattern := result.Metadata["pattern"]
+		if seen[pattern] || !live[pattern] {
+			continue
+		}
+		seen[pattern] = true
+		candidates = append(candidates, Candidate{
+			Pattern: pattern,
+			Example: result.Metadata["text"],
+			Score:   result.Score,
+		})
+		if len(candidates) == topK {
+			break
+		}
+	}
+	return candidates, nil
+}
+
+// Resolve implements Fallback
+func (s *SemanticRouter) Resolve(prompt string, routes []*Route) (*Resolution, error) {
+	if s.Embedder == nil || len(routes) == 0 {
+		return nil, nil
+	}
+
+	candidates, err := s.Candidates(prompt, routes)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := s.Threshold
+	if threshold <= 0 {
+		threshold = DefaultSemanticThreshold
+	}
+	if len(candidates) == 0 || candidates[0].Score < threshold {
+		return nil, nil
+	}
+
+	var route *Route
+	for _, candidate := range routes {
+		if candidate.Pattern == candidates[0].Pattern {
+			route = candidate
+			break
+		}
+	}
+
+	resolution := &Resolution{
+		Confidence: candidates[0].Score,
+		Candidates: candidates,
+		Source:     "semantic",
+	}
+
+	switch route.wildcards {
+	case 0:
+		resolution.Route = route
+	case 1:
+		resolution.Route = route
+		resolution.Captures = []string{strings.TrimSpace(prompt)}
+	default:
+		resolution.Reply = fmt.Sprintf("🤔 That sounds like %q. Could you phrase it like %q?", route.Pattern, candidates[0].Example)
+	}
+	return resolution, nil
+}
+
+// utterances returns the texts embedded for a route: its examples and
+// description, or its literal words if it has neither
+func utterances(route *Route) []string {
+	var texts []string
+	texts = append(texts, route.Meta.Examples...)
+	if route.Meta.Description != "" {
+		texts = append(texts, route.Meta.Description)
+	}
+	if len(texts) == 0 {
+		if words := strings.Join(strings.Fields(strings.ReplaceAll(route.Pattern, "*", " ")), " "); words != "" {
+			texts = append(texts, words)
+		}
+	}
+	return texts
+}
+
+// equalStrings reports whether a and b hold the same strings in order
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
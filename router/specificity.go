@@ -0,0 +1,191 @@
+package router
+
+import "fmt"
+
+// token is a single element of a route pattern: either a literal rune or a
+// wildcard
+type token struct {
+	r        rune
+	wildcard bool
+}
+
+// tokenize splits a pattern into literal runes and wildcards
+func tokenize(pattern string) []token {
+	tokens := make([]token, 0, len(pattern))
+	for _, r := range pattern {
+		if r == '*' {
+			tokens = append(tokens, token{wildcard: true})
+		} else {
+			tokens = append(tokens, token{r: r})
+		}
+	}
+	return tokens
+}
+
+// countTokens returns the number of literal runes and wildcards in a pattern
+func countTokens(tokens []token) (literals, wildcards int) {
+	for _, t := range tokens {
+		if t.wildcard {
+			wildcards++
+		} else {
+			literals++
+		}
+	}
+	return literals, wildcards
+}
+
+// compareRank compares two routes by explicit priority, then number of
+// literal characters, then number of wildcards. It returns a positive number
+// if a is more specific than b, a negative number if it is less specific and
+// zero if neither is preferred.
+func compareRank(a, b *Route) int {
+	if a.Priority != b.Priority {
+		return a.Priority - b.Priority
+	}
+	if a.literals != b.literals {
+		return a.literals - b.literals
+	}
+	return b.wildcards - a.wildcards
+}
+
+// compareRoutes is compareRank with registration order as the final tie
+// breaker, so it never returns zero for two distinct routes
+func compareRoutes(a, b *Route) int {
+	if c := compareRank(a, b); c != 0 {
+		return c
+	}
+	return b.seq - a.seq
+}
+
+// ConflictKind describes how two route patterns conflict
+type ConflictKind string
+
+const (
+	// ConflictDuplicate means the same pattern was registered twice
+	ConflictDuplicate ConflictKind = "duplicate"
+
+	// ConflictAmbiguous means both routes can match the same prompt and
+	// neither is more specific than the other
+	ConflictAmbiguous ConflictKind = "ambiguous"
+
+	// ConflictUnreachable means every prompt matched by one route is
+	// matched first by a higher ranked route
+	ConflictUnreachable ConflictKind = "unreachable"
+)
+
+// ConflictError is returned by Register when a new route conflicts with an
+// existing one
+type ConflictError struct {
+	Kind ConflictKind
+
+	// Pattern is the pattern being registered
+	Pattern string
+
+	// Existing is the already registered pattern it conflicts with
+	Existing string
+}
+
+func (e *ConflictError) Error() string {
+	switch e.Kind {
+	case ConflictDuplicate:
+		return fmt.Sprintf("router: pattern %q is already registered", e.Pattern)
+	case ConflictAmbiguous:
+		return fmt.Sprintf("router: pattern %q is ambiguous with %q: both can match the same prompt with equal specificity", e.Pattern, e.Existing)
+	default:
+		return fmt.Sprintf("router: pattern %q is unreachable: every prompt it matches is taken by %q", e.Pattern, e.Existing)
+	}
+}
+
+// checkConflict reports whether registering route next to existing would
+// leave one of them unreachable or make the winner depend on registration
+// order
+func checkConflict(existing, route *Route) error {
+	if existing.canonical == route.canonical {
+		return &ConflictError{Kind: ConflictDuplicate, Pattern: route.Pattern, Existing: existing.Pattern}
+	}
+
+	rank := compareRank(route, existing)
+	switch {
+	case rank < 0 && includes(existing.tokens, route.tokens):
+		return &ConflictError{Kind: ConflictUnreachable, Pattern: route.Pattern, Existing: existing.Pattern}
+	case rank > 0 && includes(route.tokens, existing.tokens):
+		return &ConflictError{Kind: ConflictUnreachable, Pattern: existing.Pattern, Existing: route.Pattern}
+	case rank == 0 && intersects(existing.tokens, route.tokens):
+		return &ConflictError{Kind: ConflictAmbiguous, Pattern: route.Pattern, Existing: existing.Pattern}
+	}
+	return nil
+}
+
+// intersects reports whether some prompt is matched by both patterns
+func intersects(a, b []token) bool {
+	memo := make(map[[2]int]bool)
+	var walk func(i, j int) bool
+	walk = func(i, j int) bool {
+		key := [2]int{i, j}
+		if v, ok := memo[key]; ok {
+			return v
+		}
+		var result bool
+		switch {
+		case i == len(a) && j == len(b):
+			result = true
+		case i < len(a) && a[i].wildcard:
+			// The wildcard either ends here or absorbs the next token of b
+			result = walk(i+1, j) || (j < len(b) && walk(i, j+1))
+		case j < len(b) && b[j].wildcard:
+			result = walk(i, j+1) || (i < len(a) && walk(i+1, j))
+		case i < len(a) && j < len(b):
+			result = a[i].r == b[j].r && walk(i+1, j+1)
+		}
+		memo[key] = result
+		return result
+	}
+	return walk(0, 0)
+}
+
+// includes reports whether every prompt matched by inner is also matched by
+// outer. Replacing each wildcard of inner with a rune that outer cannot match
+// literally gives a witness that outer matches if and only if the inclusion
+// holds.
+func includes(outer, inner []token) bool {
+	sentinel := rune(0xE000)
+	for _, t := range outer {
+		if !t.wildcard && t.r >= sentinel {
+			sentinel = t.r + 1
+		}
+	}
+
+	witness := make([]rune, len(inner))
+	for i, t := range inner {
+		if t.wildcard {
+			witness[i] = sentinel
+		} else {
+			witness[i] = t.r
+		}
+	}
+	return matchTokens(outer, witness)
+}
+
+// matchTokens reports whether the pattern matches the text in full
+func matchTokens(pattern []token, text []rune) bool {
+	memo := make(map[[2]int]bool)
+	var walk func(i, j int) bool
+	walk = func(i, j int) bool {
+		key := [2]int{i, j}
+		if v, ok := memo[key]; ok {
+			return v
+		}
+		var result bool
+		switch {
+		case i == len(pattern):
+			result = j == len(text)
+		case pattern[i].wildcard:
+			result = walk(i+1, j) || (j < len(text) && walk(i, j+1))
+		case j < len(text):
+			result = pattern[i].r == text[j] && walk(i+1, j+1)
+		}
+		memo[key] = result
+		return result
+	}
+	return walk(0, 0)
+}
@@ -0,0 +1,202 @@
+package router_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aldotobing/neurogo/router"
+)
+
+// pattern is a route to register in a test, with its options
+type pattern struct {
+	pattern  string
+	priority int
+}
+
+// register registers the patterns on a new router, each replying with its
+// own pattern
+func register(t *testing.T, patterns []pattern) *router.Router {
+	t.Helper()
+	r := router.New()
+	for _, p := range patterns {
+		if err := r.Register(p.pattern, reply(p.pattern), router.WithPriority(p.priority)); err != nil {
+			t.Fatalf("Register(%q) failed: %v", p.pattern, err)
+		}
+	}
+	return r
+}
+
+func TestRanking(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []pattern
+		prompt   string
+		want     string
+	}{
+		{
+			name:     "priority beats literals",
+			patterns: []pattern{{pattern: "open the *"}, {pattern: "* door", priority: 1}},
+			prompt:   "open the door",
+			want:     "* door",
+		},
+		{
+			name:     "more literals win",
+			patterns: []pattern{{pattern: "open *"}, {pattern: "open the *"}},
+			prompt:   "open the door",
+			want:     "open the *",
+		},
+		{
+			name:     "more literals win whatever the order",
+			patterns: []pattern{{pattern: "open the *"}, {pattern: "open *"}},
+			prompt:   "open the door",
+			want:     "open the *",
+		},
+		{
+			name:     "fewer wildcards win",
+			patterns: []pattern{{pattern: "a*b*"}, {pattern: "ab*"}},
+			prompt:   "abc",
+			want:     "ab*",
+		},
+		{
+			name:     "less specific route still matches the rest",
+			patterns: []pattern{{pattern: "a*b*"}, {pattern: "ab*"}},
+			prompt:   "axbc",
+			want:     "a*b*",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := register(t, tt.patterns)
+			response, err := r.Process(tt.prompt)
+			if err != nil {
+				t.Fatalf("Process(%q) failed: %v", tt.prompt, err)
+			}
+			if response != tt.want {
+				t.Errorf("%q was handled by %q, want %q", tt.prompt, response, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankingRegistrationOrder(t *testing.T) {
+	r := register(t, []pattern{{pattern: "dog *"}, {pattern: "cat *"}, {pattern: "the cat *"}})
+
+	var got []string
+	for _, route := range r.Routes() {
+		got = append(got, route.Pattern)
+	}
+	want := []string{"the cat *", "dog *", "cat *"}
+	if len(got) != len(want) {
+		t.Fatalf("Routes() = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Routes() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []pattern
+		add      pattern
+		kind     router.ConflictKind
+		pattern  string
+		other    string
+	}{
+		{
+			name:     "duplicate",
+			existing: []pattern{{pattern: "open *"}},
+			add:      pattern{pattern: "open *"},
+			kind:     router.ConflictDuplicate,
+			pattern:  "open *",
+			other:    "open *",
+		},
+		{
+			name:     "duplicate after normalizing",
+			existing: []pattern{{pattern: "open *"}},
+			add:      pattern{pattern: "OPEN   *"},
+			kind:     router.ConflictDuplicate,
+			pattern:  "OPEN   *",
+			other:    "open *",
+		},
+		{
+			name:     "ambiguous",
+			existing: []pattern{{pattern: "a *"}},
+			add:      pattern{pattern: "* b"},
+			kind:     router.ConflictAmbiguous,
+			pattern:  "* b",
+			other:    "a *",
+		},
+		{
+			name:     "unreachable behind a higher priority",
+			existing: []pattern{{pattern: "open *", priority: 1}},
+			add:      pattern{pattern: "open the *"},
+			kind:     router.ConflictUnreachable,
+			pattern:  "open the *",
+			other:    "open *",
+		},
+		{
+			name:     "shadows an existing route",
+			existing: []pattern{{pattern: "open the *"}},
+			add:      pattern{pattern: "open *", priority: 1},
+			kind:     router.ConflictUnreachable,
+			pattern:  "open the *",
+			other:    "open *",
+		},
+		{
+			name:     "unreachable behind a pattern with fewer wildcards",
+			existing: []pattern{{pattern: "ab*"}},
+			add:      pattern{pattern: "ab**"},
+			kind:     router.ConflictUnreachable,
+			pattern:  "ab**",
+			other:    "ab*",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := register(t, tt.existing)
+			err := r.Register(tt.add.pattern, reply(tt.add.pattern), router.WithPriority(tt.add.priority))
+
+			var conflict *router.ConflictError
+			if !errors.As(err, &conflict) {
+				t.Fatalf("Register(%q) returned %v, want a *ConflictError", tt.add.pattern, err)
+			}
+			if conflict.Kind != tt.kind || conflict.Pattern != tt.pattern || conflict.Existing != tt.other {
+				t.Errorf("conflict is %s of %q with %q, want %s of %q with %q",
+					conflict.Kind, conflict.Pattern, conflict.Existing, tt.kind, tt.pattern, tt.other)
+			}
+			if n := len(r.Routes()); n != len(tt.existing) {
+				t.Errorf("%d routes registered after the conflict, want %d", n, len(tt.existing))
+			}
+
+			defer func() {
+				if recovered, ok := recover().(error); !ok || !errors.As(recovered, &conflict) {
+					t.Errorf("Handle(%q) panicked with %v, want a *ConflictError", tt.add.pattern, recovered)
+				}
+			}()
+			r.Handle(tt.add.pattern, reply(tt.add.pattern), router.WithPriority(tt.add.priority))
+		})
+	}
+}
+
+func TestNoConflict(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []pattern
+	}{
+		{name: "disjoint literals", patterns: []pattern{{pattern: "open *"}, {pattern: "close *"}}},
+		{name: "more specific overlap", patterns: []pattern{{pattern: "open *"}, {pattern: "open the *"}}},
+		{name: "priority overlap", patterns: []pattern{{pattern: "open the *"}, {pattern: "* door", priority: 1}}},
+		{name: "equal rank without overlap", patterns: []pattern{{pattern: "dog *"}, {pattern: "cat *"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			register(t, tt.patterns)
+		})
+	}
+}
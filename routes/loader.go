@@ -0,0 +1,112 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aldotobing/neurogo/router"
+)
+
+// Loader mounts the routes of a config file on a router and reloads them
+// when asked or when the file changes
+type Loader struct {
+	router  *router.Router
+	path    string
+	backend Backend
+
+	mu      sync.Mutex
+	modTime time.Time
+	group   string
+}
+
+// NewLoader creates a loader for the routes file at path
+func NewLoader(r *router.Router, path string, backend Backend) *Loader {
+	return &Loader{router: r, path: path, backend: backend}
+}
+
+// Path returns the routes file the loader reads
+func (l *Loader) Path() string {
+	return l.path
+}
+
+// Load reads the file and replaces the routes mounted from it, returning
+// the number of routes. If the file is invalid or a route conflicts with
+// another one, the previous routes are kept.
+func (l *Loader) Load() (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := Load(l.path)
+	if err != nil {
+		return 0, err
+	}
+
+	group := file.Group
+	if group == "" {
+		group = DefaultGroup
+	}
+	if l.group != "" && group != l.group {
+		return 0, fmt.Errorf("%s: group changed from %q to %q; restart to rename it", l.path, l.group, group)
+	}
+
+	var opts []router.GroupOption
+	if file.Description != "" {
+		opts = append(opts, router.WithDescription(file.Description))
+	}
+	if err := l.router.Remount(group, file.Module(l.backend), opts...); err != nil {
+		return 0, err
+	}
+
+	l.modTime = info.ModTime()
+	l.group = group
+	return len(file.Routes), nil
+}
+
+// Watch reloads the routes whenever the file's modification time changes,
+// checking every interval until ctx is canceled. Failed reloads are logged
+// and the previous routes stay active.
+func (l *Loader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(l.path)
+		if err != nil {
+			continue
+		}
+
+		l.mu.Lock()
+		changed := !info.ModTime().Equal(l.modTime)
+		l.mu.Unlock()
+		if !changed {
+			continue
+		}
+
+		count, err := l.Load()
+		if err != nil {
+			log.Printf("❌ Reloading routes from %s failed: %v", l.path, err)
+
+			// Don't retry until the file changes again
+			l.mu.Lock()
+			l.modTime = info.ModTime()
+			l.mu.Unlock()
+			continue
+		}
+		log.Printf("🔁 Reloaded %d routes from %s", count, l.path)
+	}
+}
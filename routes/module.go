@@ -0,0 +1,153 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aldotobing/neurogo/config"
+	"github.com/aldotobing/neurogo/prompts"
+	"github.com/aldotobing/neurogo/providers"
+	"github.com/aldotobing/neurogo/router"
+)
+
+// Backend supplies what declarative routes need from the server
+type Backend struct {
+	// Provider returns the provider chosen for a request
+	Provider func(ctx *router.Context) (providers.Provider, error)
+
+	// Model returns the model for a request to provider, used when a
+	// definition sets none
+	Model func(ctx *router.Context, provider providers.Provider) string
+
+	// Options returns extra options for a route, such as the middleware
+	// that selects its provider
+	Options func(def *Definition) []router.RouteOption
+
+	// Prompts renders the templates definitions refer to
+	Prompts *prompts.Library
+}
+
+// formatInstructions are added to the system prompt for each output format
+var formatInstructions = map[string]string{
+	OutputMarkdown: "Format the response in Markdown.",
+	OutputList:     "Respond with a bulleted list, one item per line.",
+	OutputJSON:     "Respond with valid JSON only, without code fences or commentary.",
+}
+
+// Module returns a router module that registers the file's routes
+func (f *File) Module(backend Backend) router.Module {
+	return router.ModuleFunc(func(g *router.Group) error {
+		for i := range f.Routes {
+			def := &f.Routes[i]
+			if def.Template != "" && backend.Prompts == nil {
+				return fmt.Errorf("route %q uses template %q but no prompt library is configured", def.Pattern, def.Template)
+			}
+
+			opts := []router.RouteOption{
+				router.WithPriority(def.Priority),
+				router.WithMeta(router.Meta{
+					Description: def.Description,
+					Usage:       def.Usage,
+					Examples:    def.Examples,
+					Category:    def.Category,
+					Hidden:      def.Hidden,
+				}),
+			}
+			if backend.Options != nil {
+				opts = append(opts, backend.Options(def)...)
+			}
+			if err := g.Register(def.Pattern, def.handler(backend), opts...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// handler builds the prompt from the captures, completes it and formats
+// the response
+func (d *Definition) handler(backend Backend) router.Handler {
+	prompt, _ := parsePrompt(d)
+
+	return func(ctx *router.Context) error {
+		provider, err := backend.Provider(ctx)
+		if err != nil {
+			return err
+		}
+
+		vars := d.variables(ctx.Captures)
+		text := strings.Join(ctx.Captures, " ")
+		if d.Prompt != "" {
+			var b strings.Builder
+			if err := prompt.Execute(&b, vars); err != nil {
+				return fmt.Errorf("rendering prompt for %q failed: %w", d.Pattern, err)
+			}
+			text = b.String()
+		}
+
+		options := config.CompletionOptions{
+			Model:        d.Model,
+			SystemPrompt: d.SystemPrompt,
+			Temperature:  d.Temperature,
+			MaxTokens:    d.MaxTokens,
+		}
+		if options.Model == "" && backend.Model != nil {
+			options.Model = backend.Model(ctx, provider)
+		}
+		if d.Template != "" {
+			options.Template = d.Template
+			options.Variables = vars
+			if text, options, err = backend.Prompts.Apply(text, options); err != nil {
+				return err
+			}
+		}
+		if instruction := formatInstructions[d.Output]; instruction != "" {
+			options.SystemPrompt = strings.TrimSpace(options.SystemPrompt + "\n\n" + instruction)
+		}
+
+		response, err := provider.Complete(text, options)
+		if err != nil {
+			return err
+		}
+
+		ctx.Response, err = format(d.Output, response)
+		return err
+	}
+}
+
+// variables maps the captures to the definition's param names
+func (d *Definition) variables(captures []string) map[string]interface{} {
+	vars := make(map[string]interface{}, len(captures)+1)
+	for i, capture := range captures {
+		if i < len(d.Params) {
+			vars[d.Params[i]] = capture
+		}
+	}
+	if _, exists := vars["input"]; !exists && len(captures) > 0 {
+		vars["input"] = captures[0]
+	}
+	return vars
+}
+
+// format checks and normalizes a response for the output format
+func format(output, response string) (string, error) {
+	if output != OutputJSON {
+		return response, nil
+	}
+
+	// Models often wrap JSON in a code fence despite being asked not to
+	trimmed := strings.TrimSpace(response)
+	if strings.HasPrefix(trimmed, "```") {
+		trimmed = strings.TrimPrefix(trimmed, "```json")
+		trimmed = strings.TrimPrefix(trimmed, "```")
+		trimmed = strings.TrimSuffix(strings.TrimSpace(trimmed), "```")
+	}
+
+	var b bytes.Buffer
+	if err := json.Indent(&b, []byte(strings.TrimSpace(trimmed)), "", "  "); err != nil {
+		return "", fmt.Errorf("provider did not return valid JSON: %w", err)
+	}
+	return b.String(), nil
+}
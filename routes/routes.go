@@ -0,0 +1,151 @@
+package routes
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultGroup is the name of the router group declarative routes are
+// mounted in when the file does not name one
+const DefaultGroup = "config"
+
+// Output formats a route can ask the provider for
+const (
+	OutputText     = "text"
+	OutputMarkdown = "markdown"
+	OutputList     = "list"
+	OutputJSON     = "json"
+)
+
+// File is a routes config file, written in YAML or JSON
+type File struct {
+	// Group is the router group the routes are mounted in
+	Group string `yaml:"group,omitempty" json:"group,omitempty"`
+
+	// Description is the help section title of the group
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+
+	Routes []Definition `yaml:"routes" json:"routes"`
+}
+
+// Definition declares a route that sends a prompt built from its captures
+// to an AI provider
+type Definition struct {
+	Pattern string `yaml:"pattern" json:"pattern"`
+
+	// Params names the pattern's wildcards, in order. The captures are
+	// available under these names to Prompt and Template; the first one is
+	// also available as "input".
+	Params []string `yaml:"params,omitempty" json:"params,omitempty"`
+
+	// TaskType picks the provider in auto mode: translation, reasoning,
+	// coding, summary or general (the default)
+	TaskType string `yaml:"task_type,omitempty" json:"task_type,omitempty"`
+
+	// Provider is preferred over the task type's choice in auto mode
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// Model overrides the provider's default model
+	Model string `yaml:"model,omitempty" json:"model,omitempty"`
+
+	SystemPrompt string  `yaml:"system_prompt,omitempty" json:"system_prompt,omitempty"`
+	Temperature  float64 `yaml:"temperature,omitempty" json:"temperature,omitempty"`
+	MaxTokens    int     `yaml:"max_tokens,omitempty" json:"max_tokens,omitempty"`
+
+	// Prompt is a text/template rendered with the params into the prompt.
+	// Without it the captures are sent as they are.
+	Prompt string `yaml:"prompt,omitempty" json:"prompt,omitempty"`
+
+	// Template names a prompt template from the server's library, rendered
+	// with the params as variables
+	Template string `yaml:"template,omitempty" json:"template,omitempty"`
+
+	// Output is the response format: text (the default), markdown, list or
+	// json. JSON responses are checked and pretty-printed.
+	Output string `yaml:"output,omitempty" json:"output,omitempty"`
+
+	Description string   `yaml:"description,omitempty" json:"description,omitempty"`
+	Usage       string   `yaml:"usage,omitempty" json:"usage,omitempty"`
+	Examples    []string `yaml:"examples,omitempty" json:"examples,omitempty"`
+	Category    string   `yaml:"category,omitempty" json:"category,omitempty"`
+	Hidden      bool     `yaml:"hidden,omitempty" json:"hidden,omitempty"`
+	Priority    int      `yaml:"priority,omitempty" json:"priority,omitempty"`
+}
+
+// Parse decodes and validates a routes file. JSON is valid YAML, so both
+// formats are accepted.
+func Parse(data []byte) (*File, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var file File
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse routes: %w", err)
+	}
+	if err := file.Validate(); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// Load reads a routes file
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routes: %w", err)
+	}
+
+	file, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file, nil
+}
+
+// Validate checks every route definition. Conflicts between patterns are
+// reported by the router when the routes are registered.
+func (f *File) Validate() error {
+	for i := range f.Routes {
+		if err := f.Routes[i].Validate(); err != nil {
+			return fmt.Errorf("route %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the definition's pattern, params, output format and
+// prompt template
+func (d *Definition) Validate() error {
+	if strings.TrimSpace(d.Pattern) == "" {
+		return fmt.Errorf("pattern is required")
+	}
+
+	wildcards := strings.Count(d.Pattern, "*")
+	if len(d.Params) > wildcards {
+		return fmt.Errorf("%q names %d params but has %d wildcards", d.Pattern, len(d.Params), wildcards)
+	}
+
+	switch d.Output {
+	case "", OutputText, OutputMarkdown, OutputList, OutputJSON:
+	default:
+		return fmt.Errorf("%q has unknown output format %q", d.Pattern, d.Output)
+	}
+
+	if d.Prompt != "" && d.Template != "" {
+		return fmt.Errorf("%q sets both prompt and template", d.Pattern)
+	}
+	if _, err := parsePrompt(d); err != nil {
+		return fmt.Errorf("%q: %w", d.Pattern, err)
+	}
+	return nil
+}
+
+// parsePrompt parses the definition's prompt template
+func parsePrompt(d *Definition) (*template.Template, error) {
+	return template.New(d.Pattern).Option("missingkey=error").Parse(d.Prompt)
+}
@@ -17,15 +17,36 @@ type ProcessRequest struct {
 type ProcessResponse struct {
 	Response string `json:"response,omitempty"`
 	Error    string `json:"error,omitempty"`
+
+	// Code classifies the error; see router.Code
+	Code router.Code `json:"code,omitempty"`
+
+	// Suggestions lists commands the user may have meant when no route
+	// matched the prompt
+	Suggestions []string `json:"suggestions,omitempty"`
+
+	// Stages lists the intermediate results when the prompt was a pipeline
+	Stages []router.Stage `json:"stages,omitempty"`
 }
 
 // SetupAPIRoutes configures the API routes
 func SetupAPIRoutes(r *mux.Router, neuroRouter *router.Router) {
 	r.HandleFunc("/process", handleProcess(neuroRouter)).Methods("POST", "OPTIONS")
 	r.HandleFunc("/routes", handleRoutes(neuroRouter)).Methods("GET")
+	r.HandleFunc("/route/explain", handleExplain(neuroRouter)).Methods("POST", "OPTIONS")
 	r.HandleFunc("/health", handleHealth).Methods("GET")
 }
 
+// Reloader reloads routes that are defined outside of the code
+type Reloader interface {
+	Load() (int, error)
+}
+
+// SetupRouteReload adds an endpoint that reloads declarative routes
+func SetupRouteReload(r *mux.Router, reloader Reloader) {
+	r.HandleFunc("/routes/reload", handleReload(reloader)).Methods("POST", "OPTIONS")
+}
+
 // handleProcess processes a prompt through the NeuroGO router
 func handleProcess(neuroRouter *router.Router) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -48,34 +69,118 @@ func handleProcess(neuroRouter *router.Router) http.HandlerFunc {
 			return
 		}
 
-		response, err := neuroRouter.Process(req.Prompt)
+		result, err := neuroRouter.ExecuteContext(r.Context(), req.Prompt)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			w.WriteHeader(statusFor(err))
 			json.NewEncoder(w).Encode(ProcessResponse{
-				Error: err.Error(),
+				Error:       err.Error(),
+				Code:        router.CodeOf(err),
+				Suggestions: router.SuggestionsOf(err),
+				Stages:      pipelineStages(result),
 			})
 			return
 		}
 
 		json.NewEncoder(w).Encode(ProcessResponse{
-			Response: response,
+			Response: result.Response,
+			Stages:   pipelineStages(result),
 		})
 	}
 }
 
-// handleRoutes returns information about registered routes
+// statusFor returns the HTTP status for a router error's code
+func statusFor(err error) int {
+	switch router.CodeOf(err) {
+	case router.CodeNotFound:
+		return http.StatusNotFound
+	case router.CodeInvalidInput:
+		return http.StatusBadRequest
+	case router.CodeUnavailable:
+		return http.StatusServiceUnavailable
+	case router.CodeProvider:
+		return http.StatusBadGateway
+	case router.CodeTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// pipelineStages returns the stages of a pipeline result, or nil if the
+// prompt was a single command
+func pipelineStages(result *router.Result) []router.Stage {
+	if result == nil || len(result.Stages) < 2 {
+		return nil
+	}
+	return result.Stages
+}
+
+// handleRoutes returns information about registered routes, grouped by
+// the route group they belong to
 func handleRoutes(neuroRouter *router.Router) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		
-		routes := neuroRouter.GetRoutes()
+
+		groups := neuroRouter.GetRoutes()
+		count := 0
+		for _, group := range groups {
+			count += len(group.Routes)
+		}
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"routes": routes,
-			"count":  len(routes),
+			"groups": groups,
+			"count":  count,
 		})
 	}
 }
 
+// handleReload reloads declarative routes, keeping the current ones if the
+// new definitions are invalid
+func handleReload(reloader Reloader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		count, err := reloader.Load()
+		if err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "reloaded",
+			"count":  count,
+		})
+	}
+}
+
+// handleExplain shows how a prompt would be routed without running it
+func handleExplain(neuroRouter *router.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var req ProcessRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ProcessResponse{
+				Error: "Invalid JSON payload",
+			})
+			return
+		}
+
+		if req.Prompt == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ProcessResponse{
+				Error: "Prompt is required",
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(neuroRouter.Explain(req.Prompt))
+	}
+}
+
 // handleHealth returns the health status of the API
 func handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+
+	"github.com/aldotobing/neurogo/rag"
+	"github.com/gorilla/mux"
+)
+
+// CollectionRequest represents a request to create a collection and
+// optionally ingest documents into it
+type CollectionRequest struct {
+	Name  string   `json:"name"`
+	Paths []string `json:"paths,omitempty"`
+}
+
+// IngestRequest represents a request to ingest documents. Without paths,
+// the collection's paths are ingested again.
+type IngestRequest struct {
+	Paths []string `json:"paths,omitempty"`
+}
+
+// QueryRequest represents a search or a question about a collection
+type QueryRequest struct {
+	Query string `json:"query"`
+
+	// K is the number of chunks retrieved
+	K int `json:"k,omitempty"`
+}
+
+// AskFunc answers a question from a collection with an AI provider
+type AskFunc func(ctx context.Context, collection *rag.Collection, question string, k int) (*rag.Answer, error)
+
+// SetupCollectionRoutes configures the document collection routes
+func SetupCollectionRoutes(r *mux.Router, manager *rag.Manager, ask AskFunc) {
+	r.HandleFunc("/collections", handleListCollections(manager)).Methods("GET")
+	r.HandleFunc("/collections", handleCreateCollection(manager)).Methods("POST", "OPTIONS")
+	r.HandleFunc("/collections/{name}", handleShowCollection(manager)).Methods("GET")
+	r.HandleFunc("/collections/{name}", handleDeleteCollection(manager)).Methods("DELETE", "OPTIONS")
+	r.HandleFunc("/collections/{name}/ingest", handleIngest(manager)).Methods("POST", "OPTIONS")
+	r.HandleFunc("/collections/{name}/search", handleSearchCollection(manager)).Methods("POST", "OPTIONS")
+	r.HandleFunc("/collections/{name}/ask", handleAskCollection(manager, ask)).Methods("POST", "OPTIONS")
+}
+
+// handleListCollections lists the collections
+func handleListCollections(manager *rag.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		collections := manager.List()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"collections": collections,
+			"count":       len(collections),
+		})
+	}
+}
+
+// handleCreateCollection creates a collection, ingesting the given paths
+func handleCreateCollection(manager *rag.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var req CollectionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "A collection name is required",
+			})
+			return
+		}
+
+		collection, err := manager.Create(req.Name)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		response := map[string]interface{}{}
+		if len(req.Paths) > 0 {
+			report, err := collection.Ingest(r.Context(), req.Paths...)
+			if err != nil {
+				writeCollectionError(w, err)
+				return
+			}
+			response["report"] = report
+		}
+		response["collection"] = collection.Info()
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// handleShowCollection describes a collection and lists its documents
+func handleShowCollection(manager *rag.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		collection, err := manager.Collection(mux.Vars(r)["name"])
+		if err != nil {
+			writeCollectionError(w, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"collection": collection.Info(),
+			"files":      collection.Files(),
+		})
+	}
+}
+
+// handleDeleteCollection removes a collection
+func handleDeleteCollection(manager *rag.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		name := mux.Vars(r)["name"]
+		if err := manager.Delete(name); err != nil {
+			writeCollectionError(w, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":     "deleted",
+			"collection": name,
+		})
+	}
+}
+
+// handleIngest ingests documents into a collection, or ingests its paths
+// again if none are given
+func handleIngest(manager *rag.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		collection, err := manager.Collection(mux.Vars(r)["name"])
+		if err != nil {
+			writeCollectionError(w, err)
+			return
+		}
+
+		var req IngestRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"error": "Invalid JSON payload",
+				})
+				return
+			}
+		}
+
+		var report *rag.Report
+		if len(req.Paths) > 0 {
+			report, err = collection.Ingest(r.Context(), req.Paths...)
+		} else {
+			report, err = collection.Refresh(r.Context())
+		}
+		if err != nil {
+			writeCollectionError(w, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"collection": collection.Info(),
+			"report":     report,
+		})
+	}
+}
+
+// handleSearchCollection returns the chunks most similar to a query
+func handleSearchCollection(manager *rag.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		collection, req, ok := collectionQuery(w, r, manager)
+		if !ok {
+			return
+		}
+
+		k := req.K
+		if k <= 0 {
+			k = rag.DefaultTopK
+		}
+		matches, err := collection.Search(r.Context(), req.Query, k)
+		if err != nil {
+			writeCollectionError(w, err)
+			return
+		}
+		if matches == nil {
+			matches = []rag.Match{}
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"matches": matches,
+			"count":   len(matches),
+		})
+	}
+}
+
+// handleAskCollection answers a question from a collection's documents,
+// with the chunks the answer cites
+func handleAskCollection(manager *rag.Manager, ask AskFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		collection, req, ok := collectionQuery(w, r, manager)
+		if !ok {
+			return
+		}
+
+		answer, err := ask(r.Context(), collection, req.Query, req.K)
+		if err != nil {
+			writeCollectionError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(answer)
+	}
+}
+
+// collectionQuery reads the collection and query of a search or question,
+// reporting errors itself
+func collectionQuery(w http.ResponseWriter, r *http.Request, manager *rag.Manager) (*rag.Collection, QueryRequest, bool) {
+	var req QueryRequest
+	collection, err := manager.Collection(mux.Vars(r)["name"])
+	if err != nil {
+		writeCollectionError(w, err)
+		return nil, req, false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Query == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "A query is required",
+		})
+		return nil, req, false
+	}
+	return collection, req, true
+}
+
+// writeCollectionError reports a collection error, with 404 for unknown
+// collections and 400 for paths that cannot be ingested
+func writeCollectionError(w http.ResponseWriter, err error) {
+	status := http.StatusBadGateway
+	switch {
+	case errors.Is(err, rag.ErrCollectionNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, rag.ErrOutsideRoot), errors.Is(err, fs.ErrNotExist), errors.Is(err, rag.ErrEmptyCollection):
+		status = http.StatusBadRequest
+	case errors.Is(err, rag.ErrEmbeddingModel):
+		status = http.StatusConflict
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/aldotobing/neurogo/providers"
+	"github.com/gorilla/mux"
+)
+
+// SetupModelRoutes configures the model catalog routes
+func SetupModelRoutes(r *mux.Router, catalog *providers.Catalog) {
+	r.HandleFunc("/models", handleModels(catalog)).Methods("GET")
+}
+
+// SetupSelectionRoutes configures the route that explains which provider
+// and model satisfy requirements
+func SetupSelectionRoutes(r *mux.Router, selector *providers.Selector) {
+	r.HandleFunc("/providers/select", handleSelect(selector)).Methods("POST", "OPTIONS")
+}
+
+// SelectionResponse is the provider and model chosen for requirements, or
+// the error if none satisfies them, with the rejected candidates
+type SelectionResponse struct {
+	Provider string                `json:"provider,omitempty"`
+	Model    *providers.Model      `json:"model,omitempty"`
+	Rejected []providers.Rejection `json:"rejected,omitempty"`
+	Error    string                `json:"error,omitempty"`
+}
+
+// handleSelect selects a provider and model for the posted requirements
+// without running anything. Nothing satisfying them is a 503.
+func handleSelect(selector *providers.Selector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var requirements providers.Requirements
+		if err := json.NewDecoder(r.Body).Decode(&requirements); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(SelectionResponse{
+				Error: "Invalid JSON payload",
+			})
+			return
+		}
+
+		selection, err := selector.Select(r.Context(), requirements)
+		if err != nil {
+			response := SelectionResponse{Error: err.Error()}
+			var selectionErr *providers.SelectionError
+			if errors.As(err, &selectionErr) {
+				response.Rejected = selectionErr.Rejected
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+
+		json.NewEncoder(w).Encode(SelectionResponse{
+			Provider: selection.Provider.GetName(),
+			Model:    &selection.Model,
+			Rejected: selection.Rejected,
+		})
+	}
+}
+
+// handleModels lists the models of every provider, or of the provider
+// named by the "provider" query parameter. "capability" keeps the models
+// with a capability, and "refresh=true" lists the models again instead of
+// using the cached catalog.
+func handleModels(catalog *providers.Catalog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		query := r.URL.Query()
+		provider := query.Get("provider")
+		refresh := query.Get("refresh") == "true"
+
+		var models []providers.Model
+		errs := make(map[string]string)
+		if provider != "" {
+			name, ok := findProvider(catalog, provider)
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"error": "Unknown provider: " + provider,
+				})
+				return
+			}
+			if refresh {
+				catalog.Refresh(name)
+			}
+			listed, err := catalog.Models(r.Context(), name)
+			if err != nil {
+				errs[name] = err.Error()
+			}
+			models = listed
+		} else {
+			if refresh {
+				catalog.Refresh("")
+			}
+			listed, listErrs := catalog.All(r.Context())
+			for name, err := range listErrs {
+				errs[name] = err.Error()
+			}
+			models = listed
+		}
+
+		if capability := query.Get("capability"); capability != "" {
+			models = withCapability(models, capability)
+		}
+		if models == nil {
+			models = []providers.Model{}
+		}
+
+		response := map[string]interface{}{
+			"models": models,
+			"count":  len(models),
+		}
+		if len(errs) > 0 {
+			response["errors"] = errs
+		}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// findProvider returns the catalog's name for a provider, ignoring case
+func findProvider(catalog *providers.Catalog, name string) (string, bool) {
+	for _, provider := range catalog.Providers() {
+		if strings.EqualFold(provider, name) {
+			return provider, true
+		}
+	}
+	return "", false
+}
+
+// withCapability returns the models that have a capability
+func withCapability(models []providers.Model, capability string) []providers.Model {
+	var kept []providers.Model
+	for _, model := range models {
+		c := model.Capabilities
+		has := map[string]bool{
+			"streaming":  c.Streaming,
+			"tools":      c.Tools,
+			"vision":     c.Vision,
+			"json_mode":  c.JSONMode,
+			"embeddings": c.Embeddings,
+			"chat":       model.Chat(),
+		}[capability]
+		if has {
+			kept = append(kept, model)
+		}
+	}
+	return kept
+}
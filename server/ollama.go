@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/aldotobing/neurogo/providers"
+	"github.com/gorilla/mux"
+)
+
+// ModelManager manages the models installed in a local model server such
+// as Ollama
+type ModelManager interface {
+	ListModels(ctx context.Context) ([]providers.OllamaModel, error)
+	ShowModel(ctx context.Context, name string) (*providers.OllamaModelInfo, error)
+	PullModel(ctx context.Context, name string, progress func(providers.PullProgress)) error
+	DeleteModel(ctx context.Context, name string) error
+}
+
+// PullRequest represents a request to download a model
+type PullRequest struct {
+	Model string `json:"model"`
+}
+
+// SetupOllamaRoutes configures the Ollama model management routes
+func SetupOllamaRoutes(r *mux.Router, manager ModelManager) {
+	r.HandleFunc("/ollama/models", handleListModels(manager)).Methods("GET")
+	r.HandleFunc("/ollama/models/{name:.+}", handleShowModel(manager)).Methods("GET")
+	r.HandleFunc("/ollama/models/{name:.+}", handleDeleteModel(manager)).Methods("DELETE", "OPTIONS")
+	r.HandleFunc("/ollama/pull", handlePullModel(manager)).Methods("POST", "OPTIONS")
+}
+
+// handleListModels lists the installed models
+func handleListModels(manager ModelManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		models, err := manager.ListModels(r.Context())
+		if err != nil {
+			writeModelError(w, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"models": models,
+			"count":  len(models),
+		})
+	}
+}
+
+// handleShowModel returns the details of an installed model
+func handleShowModel(manager ModelManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		info, err := manager.ShowModel(r.Context(), mux.Vars(r)["name"])
+		if err != nil {
+			writeModelError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(info)
+	}
+}
+
+// handleDeleteModel removes an installed model
+func handleDeleteModel(manager ModelManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		name := mux.Vars(r)["name"]
+		if err := manager.DeleteModel(r.Context(), name); err != nil {
+			writeModelError(w, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "deleted",
+			"model":  name,
+		})
+	}
+}
+
+// handlePullModel downloads a model, streaming its progress as
+// newline-delimited JSON. The last line has a "success" status or an
+// error.
+func handlePullModel(manager ModelManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var req PullRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "A model name is required",
+			})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		encoder := json.NewEncoder(w)
+		flusher, _ := w.(http.Flusher)
+		err := manager.PullModel(r.Context(), req.Model, func(progress providers.PullProgress) {
+			encoder.Encode(progress)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		})
+
+		// The status is already sent, so errors go in the last line
+		if err != nil {
+			encoder.Encode(map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
+// writeModelError reports a model management error, with 404 for unknown
+// models
+func writeModelError(w http.ResponseWriter, err error) {
+	status := http.StatusBadGateway
+	if errors.Is(err, providers.ErrModelNotFound) {
+		status = http.StatusNotFound
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/aldotobing/neurogo/summarize"
+	"github.com/gorilla/mux"
+)
+
+// SummaryRequest represents a request to summarize a document
+type SummaryRequest struct {
+	Text string `json:"text"`
+
+	// Provider names the provider to use, or is empty to select one
+	Provider string `json:"provider,omitempty"`
+
+	// Stream reports progress as newline-delimited JSON before the result
+	Stream bool `json:"stream,omitempty"`
+}
+
+// SummarizerFunc returns a summarizer using the named provider, or the one
+// selected for summaries if the name is empty
+type SummarizerFunc func(provider string) (*summarize.Summarizer, error)
+
+// SetupSummaryRoutes configures the document summarization route
+func SetupSummaryRoutes(r *mux.Router, summarizer SummarizerFunc) {
+	r.HandleFunc("/summarize", handleSummarize(summarizer)).Methods("POST", "OPTIONS")
+}
+
+// handleSummarize summarizes a document of any length. With "stream" set,
+// progress is streamed as newline-delimited JSON and the last line holds
+// the result or an error.
+func handleSummarize(newSummarizer SummarizerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var req SummaryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Text == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "A text to summarize is required",
+			})
+			return
+		}
+
+		summarizer, err := newSummarizer(req.Provider)
+		if err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		if !req.Stream {
+			result, err := summarizer.Summarize(r.Context(), req.Text)
+			if err != nil {
+				status := http.StatusBadGateway
+				if errors.Is(err, summarize.ErrEmpty) {
+					status = http.StatusBadRequest
+				}
+				w.WriteHeader(status)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"error": err.Error(),
+				})
+				return
+			}
+			json.NewEncoder(w).Encode(result)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		encoder := json.NewEncoder(w)
+		flusher, _ := w.(http.Flusher)
+		summarizer.Progress = func(progress summarize.Progress) {
+			encoder.Encode(map[string]interface{}{
+				"progress": progress,
+			})
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		// The status is already sent, so errors go in the last line
+		result, err := summarizer.Summarize(r.Context(), req.Text)
+		if err != nil {
+			encoder.Encode(map[string]interface{}{
+				"error": err.Error(),
+			})
+			return
+		}
+		encoder.Encode(result)
+	}
+}
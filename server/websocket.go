@@ -1,8 +1,13 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"sync"
+
+	"github.com/aldotobing/neurogo/providers"
 
 	"github.com/aldotobing/neurogo/router"
 	"github.com/gorilla/mux"
@@ -21,15 +26,49 @@ type WSMessage struct {
 	Prompt   string `json:"prompt,omitempty"`
 	Response string `json:"response,omitempty"`
 	Error    string `json:"error,omitempty"`
+
+	// Code and Suggestions are set on errors as in ProcessResponse
+	Code        router.Code `json:"code,omitempty"`
+	Suggestions []string    `json:"suggestions,omitempty"`
+
+	// Stages lists the intermediate results when the prompt was a pipeline
+	Stages []router.Stage `json:"stages,omitempty"`
+
+	// Model is the model to download in "pull" messages
+	Model string `json:"model,omitempty"`
+
+	// Progress is set on "progress" messages while a model downloads
+	Progress *providers.PullProgress `json:"progress,omitempty"`
+}
+
+// WebSocketOption configures the WebSocket endpoint
+type WebSocketOption func(*wsConfig)
+
+// wsConfig holds what the WebSocket endpoint can do besides processing
+// prompts
+type wsConfig struct {
+	models ModelManager
+}
+
+// WithModelManager lets WebSocket clients download models with "pull"
+// messages, receiving "progress" messages as the download goes
+func WithModelManager(manager ModelManager) WebSocketOption {
+	return func(c *wsConfig) {
+		c.models = manager
+	}
 }
 
 // SetupWebSocket configures WebSocket endpoints
-func SetupWebSocket(r *mux.Router, neuroRouter *router.Router) {
-	r.HandleFunc("/ws", handleWebSocket(neuroRouter))
+func SetupWebSocket(r *mux.Router, neuroRouter *router.Router, opts ...WebSocketOption) {
+	var cfg wsConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	r.HandleFunc("/ws", handleWebSocket(neuroRouter, cfg))
 }
 
 // handleWebSocket handles WebSocket connections for real-time communication
-func handleWebSocket(neuroRouter *router.Router) http.HandlerFunc {
+func handleWebSocket(neuroRouter *router.Router, cfg wsConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
@@ -40,6 +79,17 @@ func handleWebSocket(neuroRouter *router.Router) http.HandlerFunc {
 
 		log.Println("WebSocket client connected")
 
+		// Downloads run in the background and stop when the client leaves,
+		// so writes to the connection are serialized
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var writeMu sync.Mutex
+		send := func(msg WSMessage) {
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			conn.WriteJSON(msg)
+		}
+
 		for {
 			var msg WSMessage
 			err := conn.ReadJSON(&msg)
@@ -52,20 +102,33 @@ func handleWebSocket(neuroRouter *router.Router) http.HandlerFunc {
 
 			switch msg.Type {
 			case "process":
-				response, err := neuroRouter.Process(msg.Prompt)
+				result, err := neuroRouter.ExecuteContext(ctx, msg.Prompt)
 				if err != nil {
-					conn.WriteJSON(WSMessage{
-						Type:  "error",
-						Error: err.Error(),
+					send(WSMessage{
+						Type:        "error",
+						Error:       err.Error(),
+						Code:        router.CodeOf(err),
+						Suggestions: router.SuggestionsOf(err),
+						Stages:      pipelineStages(result),
 					})
 				} else {
-					conn.WriteJSON(WSMessage{
+					send(WSMessage{
 						Type:     "response",
-						Response: response,
+						Response: result.Response,
+						Stages:   pipelineStages(result),
+					})
+				}
+			case "pull":
+				if cfg.models == nil || msg.Model == "" {
+					send(WSMessage{
+						Type:  "error",
+						Error: "Model downloads are not available",
 					})
+					continue
 				}
+				go pullModel(ctx, cfg.models, msg.Model, send)
 			default:
-				conn.WriteJSON(WSMessage{
+				send(WSMessage{
 					Type:  "error",
 					Error: "Unknown message type",
 				})
@@ -75,3 +138,16 @@ func handleWebSocket(neuroRouter *router.Router) http.HandlerFunc {
 		log.Println("WebSocket client disconnected")
 	}
 }
+
+// pullModel downloads a model, sending its progress and then a response or
+// an error
+func pullModel(ctx context.Context, manager ModelManager, model string, send func(WSMessage)) {
+	err := manager.PullModel(ctx, model, func(progress providers.PullProgress) {
+		send(WSMessage{Type: "progress", Model: model, Progress: &progress})
+	})
+	if err != nil {
+		send(WSMessage{Type: "error", Model: model, Error: err.Error()})
+		return
+	}
+	send(WSMessage{Type: "response", Model: model, Response: fmt.Sprintf("✅ Pulled %s", model)})
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/aldotobing/neurogo/workflow"
+	"github.com/gorilla/mux"
+)
+
+// WorkflowRequest represents a request to run a workflow
+type WorkflowRequest struct {
+	// Name selects one of the loaded workflows
+	Name string `json:"name,omitempty"`
+
+	// Workflow is an inline definition, used when Name is empty
+	Workflow *workflow.Workflow `json:"workflow,omitempty"`
+
+	Inputs map[string]interface{} `json:"inputs,omitempty"`
+}
+
+// WorkflowResponse represents the result of running a workflow
+type WorkflowResponse struct {
+	Execution *workflow.Execution `json:"execution,omitempty"`
+	Error     string              `json:"error,omitempty"`
+}
+
+// SetupWorkflowRoutes configures the workflow API routes
+func SetupWorkflowRoutes(r *mux.Router, engine *workflow.Engine, workflows map[string]*workflow.Workflow) {
+	r.HandleFunc("/workflows", handleWorkflows(workflows)).Methods("GET")
+	r.HandleFunc("/workflows/run", handleRunWorkflow(engine, workflows)).Methods("POST", "OPTIONS")
+}
+
+// handleWorkflows lists the loaded workflows
+func handleWorkflows(workflows map[string]*workflow.Workflow) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		list := make([]*workflow.Workflow, 0, len(workflows))
+		for _, wf := range workflows {
+			list = append(list, wf)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Name < list[j].Name
+		})
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"workflows": list,
+			"count":     len(list),
+		})
+	}
+}
+
+// handleRunWorkflow runs a loaded or inline workflow and returns its
+// execution record
+func handleRunWorkflow(engine *workflow.Engine, workflows map[string]*workflow.Workflow) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var req WorkflowRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(WorkflowResponse{
+				Error: "Invalid JSON payload",
+			})
+			return
+		}
+
+		wf := req.Workflow
+		if req.Name != "" {
+			wf = workflows[req.Name]
+			if wf == nil {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(WorkflowResponse{
+					Error: "Unknown workflow: " + req.Name,
+				})
+				return
+			}
+		}
+		if wf == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(WorkflowResponse{
+				Error: "Workflow name or definition is required",
+			})
+			return
+		}
+
+		execution, err := engine.Run(r.Context(), wf, req.Inputs)
+		if err != nil {
+			// Without an execution record the workflow or its inputs were
+			// invalid
+			status := http.StatusInternalServerError
+			if execution == nil {
+				status = http.StatusBadRequest
+			}
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(WorkflowResponse{
+				Execution: execution,
+				Error:     err.Error(),
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(WorkflowResponse{
+			Execution: execution,
+		})
+	}
+}
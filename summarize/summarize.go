@@ -0,0 +1,259 @@
+package summarize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aldotobing/neurogo/config"
+	"github.com/aldotobing/neurogo/providers"
+	"github.com/aldotobing/neurogo/tokens"
+)
+
+// DefaultConcurrency is the number of summaries a Summarizer requests at
+// once when its Concurrency is zero
+const DefaultConcurrency = 4
+
+// DefaultChunkTokens is the size of chunks when neither ChunkTokens nor
+// Window is set
+const DefaultChunkTokens = 2000
+
+// promptOverhead is the room kept in the context window for the template
+// and system prompt around a chunk
+const promptOverhead = 256
+
+// maxLevels bounds the rounds of combining summaries
+const maxLevels = 8
+
+// ErrEmpty is returned when there is no text to summarize
+var ErrEmpty = errors.New("nothing to summarize")
+
+// ErrNoProgress is returned when combining summaries does not make them
+// shorter, which would otherwise never end
+var ErrNoProgress = errors.New("summaries are not getting shorter")
+
+// Stage is the phase a summarization is in
+type Stage string
+
+const (
+	// StageChunks summarizes the chunks of the text
+	StageChunks Stage = "chunks"
+
+	// StageCombine summarizes groups of summaries together
+	StageCombine Stage = "combine"
+)
+
+// Progress reports how far a summarization has got. It is reported when a
+// round starts and after each summary of the round.
+type Progress struct {
+	Stage Stage `json:"stage"`
+
+	// Level is 0 for the chunk summaries and counts combining rounds
+	Level int `json:"level"`
+
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// Result is the summary of a text with what it took to produce it
+type Result struct {
+	Summary  string `json:"summary"`
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"`
+
+	// Tokens is the size of the text, estimated unless Exact
+	Tokens int  `json:"tokens"`
+	Exact  bool `json:"exact"`
+
+	// Chunks is the number of pieces the text was split into
+	Chunks int `json:"chunks"`
+
+	// Levels is the number of rounds of combining summaries
+	Levels int `json:"levels"`
+
+	// Calls is the number of completions requested
+	Calls int `json:"calls"`
+}
+
+// Summarizer summarizes texts of any length. Texts too long for one
+// request are split on paragraph and sentence boundaries, the chunks are
+// summarized concurrently, and the summaries are combined in rounds until
+// one is left.
+type Summarizer struct {
+	// Provider completes the summaries. It must render prompt templates,
+	// see prompts.Library.Wrap.
+	Provider providers.Provider
+
+	// Options are the base options of every request, typically the model
+	Options config.CompletionOptions
+
+	// Window is the model's context window in tokens, or 0 if unknown
+	Window int
+
+	// ChunkTokens is the largest input of a request in tokens. By default
+	// it is what the window holds besides the response and the template,
+	// or DefaultChunkTokens if the window is unknown.
+	ChunkTokens int
+
+	// Concurrency limits the summaries requested at once
+	Concurrency int
+
+	// ChunkTemplate summarizes a chunk of the text; "summarize" by default
+	ChunkTemplate string
+
+	// CombineTemplate summarizes summaries together; "summarize_combine"
+	// by default
+	CombineTemplate string
+
+	// Progress is called as summaries complete, one call at a time
+	Progress func(Progress)
+}
+
+// Summarize returns the summary of text. The first failed request stops
+// the requests not sent yet, as does canceling ctx.
+func (s *Summarizer) Summarize(ctx context.Context, text string) (*Result, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, ErrEmpty
+	}
+
+	tokenizer := tokens.For(s.Options.Model)
+	budget := s.budget()
+	result := &Result{
+		Provider: s.Provider.GetName(),
+		Model:    s.Options.Model,
+		Tokens:   tokenizer.Count(text),
+		Exact:    tokenizer.Exact(),
+	}
+
+	chunks := tokens.Split(tokenizer, text, budget)
+	result.Chunks = len(chunks)
+	summaries, err := s.round(ctx, chunks, 0, result)
+	if err != nil {
+		return nil, err
+	}
+
+	for len(summaries) > 1 {
+		result.Levels++
+		if result.Levels > maxLevels {
+			return nil, fmt.Errorf("%w after %d rounds", ErrNoProgress, maxLevels)
+		}
+
+		groups := tokens.Split(tokenizer, strings.Join(summaries, "\n\n"), budget)
+		if len(groups) >= len(summaries) {
+			return nil, fmt.Errorf("%w: %d summaries fill %d requests", ErrNoProgress, len(summaries), len(groups))
+		}
+		if summaries, err = s.round(ctx, groups, result.Levels, result); err != nil {
+			return nil, err
+		}
+	}
+
+	result.Summary = summaries[0]
+	return result, nil
+}
+
+// budget returns the largest input of a request in tokens
+func (s *Summarizer) budget() int {
+	if s.ChunkTokens > 0 {
+		return s.ChunkTokens
+	}
+	if s.Window <= 0 {
+		return DefaultChunkTokens
+	}
+
+	reserve := s.Options.MaxTokens
+	if reserve <= 0 {
+		reserve = min(s.Window/4, 1024)
+	}
+	return max(s.Window-reserve-promptOverhead, promptOverhead)
+}
+
+// round summarizes each input concurrently, returning the summaries in the
+// order of the inputs
+func (s *Summarizer) round(ctx context.Context, inputs []string, level int, result *Result) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stage, template := StageChunks, s.template(s.ChunkTemplate, "summarize")
+	if level > 0 {
+		stage, template = StageCombine, s.template(s.CombineTemplate, "summarize_combine")
+	}
+
+	concurrency := s.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var mu sync.Mutex
+	done := 0
+	s.report(Progress{Stage: stage, Level: level, Total: len(inputs)})
+
+	outputs := make([]string, len(inputs))
+	errs := make([]error, len(inputs))
+	var wg sync.WaitGroup
+	for i, input := range inputs {
+		wg.Add(1)
+		go func(i int, input string) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+			if err := ctx.Err(); err != nil {
+				errs[i] = err
+				return
+			}
+
+			// Complete rather than a stream, which not every provider
+			// supports, since only the final text is used
+			options := s.Options
+			options.Template = template
+			options.Variables = nil
+			output, err := s.Provider.Complete(input, options)
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.Calls++
+			if err != nil {
+				errs[i] = err
+				cancel()
+				return
+			}
+			outputs[i] = strings.TrimSpace(output)
+			done++
+			s.report(Progress{Stage: stage, Level: level, Done: done, Total: len(inputs)})
+		}(i, input)
+	}
+	wg.Wait()
+
+	// Report the failure that canceled the others rather than a
+	// cancellation
+	var first error
+	for i, err := range errs {
+		if err != nil && (first == nil || errors.Is(first, context.Canceled) && !errors.Is(err, context.Canceled)) {
+			first = fmt.Errorf("summarizing part %d of %d: %w", i+1, len(inputs), err)
+		}
+	}
+	return outputs, first
+}
+
+// template returns name, or fallback if it is empty
+func (s *Summarizer) template(name, fallback string) string {
+	if name == "" {
+		return fallback
+	}
+	return name
+}
+
+// report passes progress to the Progress callback, if any
+func (s *Summarizer) report(progress Progress) {
+	if s.Progress != nil {
+		s.Progress(progress)
+	}
+}
@@ -0,0 +1,292 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aldotobing/neurogo/config"
+	"github.com/aldotobing/neurogo/providers"
+)
+
+// Strategy is what a Guard does with a prompt too long for the model's
+// context window
+type Strategy string
+
+const (
+	// Reject fails the request with a *ContextLengthError
+	Reject Strategy = "reject"
+
+	// TruncateHead drops the start of the prompt, keeping its end
+	TruncateHead Strategy = "truncate_head"
+
+	// TruncateTail drops the end of the prompt, keeping its start
+	TruncateTail Strategy = "truncate_tail"
+
+	// MapReduce completes each chunk of the prompt on its own and combines
+	// the responses
+	MapReduce Strategy = "map_reduce"
+)
+
+// ParseStrategy returns the strategy with a name
+func ParseStrategy(name string) (Strategy, error) {
+	switch s := Strategy(strings.ToLower(strings.TrimSpace(name))); s {
+	case Reject, TruncateHead, TruncateTail, MapReduce:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown context strategy %q (want reject, truncate_head, truncate_tail or map_reduce)", name)
+}
+
+// ErrContextLength is returned when a prompt does not fit in the model's
+// context window
+var ErrContextLength = errors.New("prompt exceeds the model's context window")
+
+// ContextLengthError reports a prompt that does not fit in the model's
+// context window. It matches ErrContextLength.
+type ContextLengthError struct {
+	Model string
+
+	// Tokens is the size of the prompt, with its template and system prompt
+	Tokens int
+
+	// Limit is the context window less the tokens kept for the response
+	Limit int
+
+	// Estimated reports whether Tokens is an estimate
+	Estimated bool
+}
+
+func (e *ContextLengthError) Error() string {
+	about := ""
+	if e.Estimated {
+		about = "about "
+	}
+	return fmt.Sprintf("%s: %s%d tokens, but %s accepts %d with room for the response",
+		ErrContextLength, about, e.Tokens, e.Model, e.Limit)
+}
+
+func (e *ContextLengthError) Is(target error) bool {
+	return target == ErrContextLength
+}
+
+// Guard checks the size of prompts against the model's context window
+// before they reach a provider, and applies its Strategy to prompts that
+// are too long
+type Guard struct {
+	Strategy Strategy
+
+	// Window returns the context window of a model in tokens. Prompts for
+	// models whose window is 0 are passed through unchecked.
+	Window func(model string) int
+
+	// Reserve is the number of tokens kept for the response when the
+	// options set no MaxTokens. By default it is a quarter of the window,
+	// at most 1024.
+	Reserve int
+
+	// Render renders a prompt as the provider receives it, such as
+	// prompts.Library.Apply, so template text is counted too
+	Render func(prompt string, options config.CompletionOptions) (string, config.CompletionOptions, error)
+
+	// Reduce combines the responses to the chunks of a MapReduce. provider
+	// is the guarded provider, so a combined prompt that is still too long
+	// is split again. By default the responses are joined with blank lines.
+	Reduce func(provider providers.Provider, parts []string, options config.CompletionOptions) (string, error)
+}
+
+// guardedProvider fits prompts into the context window before calling the
+// provider
+type guardedProvider struct {
+	providers.Provider
+	guard *Guard
+}
+
+// guardedEmbedder keeps the embedding support of a wrapped provider
+type guardedEmbedder struct {
+	*guardedProvider
+	providers.Embedder
+}
+
+// Wrap returns a provider that applies the guard to prompts before
+// completing them. Embedding support of the provider is preserved. When
+// the provider renders templates, wrap it with the guard last so the guard
+// sees the raw prompt.
+func (g *Guard) Wrap(provider providers.Provider) providers.Provider {
+	wrapped := &guardedProvider{Provider: provider, guard: g}
+	if embedder, ok := provider.(providers.Embedder); ok {
+		return &guardedEmbedder{guardedProvider: wrapped, Embedder: embedder}
+	}
+	return wrapped
+}
+
+// Fit returns the prompt to send for the options, or its chunks with
+// MapReduce. It fails with a *ContextLengthError if the strategy is Reject
+// or if the template and system prompt alone leave no room for the prompt.
+func (g *Guard) Fit(prompt string, options config.CompletionOptions) ([]string, error) {
+	window := 0
+	if g.Window != nil {
+		window = g.Window(options.Model)
+	}
+	if window <= 0 {
+		return []string{prompt}, nil
+	}
+
+	tokenizer := For(options.Model)
+	rendered, renderedOptions := prompt, options
+	if g.Render != nil {
+		var err error
+		rendered, renderedOptions, err = g.Render(prompt, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	total := tokenizer.Count(rendered) + tokenizer.Count(renderedOptions.SystemPrompt)
+	limit := window - g.reserve(window, options)
+	if total <= limit {
+		return []string{prompt}, nil
+	}
+
+	tooLong := &ContextLengthError{Model: options.Model, Tokens: total, Limit: limit, Estimated: !tokenizer.Exact()}
+	promptTokens := tokenizer.Count(prompt)
+	budget := limit - max(total-promptTokens, 0)
+	if budget <= 0 {
+		return nil, tooLong
+	}
+
+	switch g.Strategy {
+	case TruncateHead:
+		return []string{tokenizer.Tail(prompt, budget)}, nil
+	case TruncateTail:
+		return []string{tokenizer.Head(prompt, budget)}, nil
+	case MapReduce:
+		return Split(tokenizer, prompt, budget), nil
+	default:
+		return nil, tooLong
+	}
+}
+
+// reserve returns the tokens kept for the response
+func (g *Guard) reserve(window int, options config.CompletionOptions) int {
+	switch {
+	case options.MaxTokens > 0:
+		return options.MaxTokens
+	case g.Reserve > 0:
+		return g.Reserve
+	default:
+		return min(window/4, 1024)
+	}
+}
+
+// Complete implements providers.Provider
+func (p *guardedProvider) Complete(prompt string, options config.CompletionOptions) (string, error) {
+	chunks, err := p.guard.Fit(prompt, options)
+	if err != nil {
+		return "", err
+	}
+	if len(chunks) == 1 {
+		return p.Provider.Complete(chunks[0], withPrompt(options, prompt, chunks[0]))
+	}
+
+	parts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		if parts[i], err = p.Provider.Complete(chunk, withPrompt(options, prompt, chunk)); err != nil {
+			return "", fmt.Errorf("chunk %d of %d: %w", i+1, len(chunks), err)
+		}
+	}
+	return p.reduce(prompt, parts, options)
+}
+
+// reduce combines the responses to the chunks of prompt
+func (p *guardedProvider) reduce(prompt string, parts []string, options config.CompletionOptions) (string, error) {
+	if p.guard.Reduce == nil {
+		return strings.Join(parts, "\n\n"), nil
+	}
+
+	// Reducing would split the responses again without end if they are no
+	// shorter than the prompt they came from
+	tokenizer := For(options.Model)
+	if tokenizer.Count(strings.Join(parts, "\n\n")) >= tokenizer.Count(prompt) {
+		return "", fmt.Errorf("%w: the responses to its chunks are no shorter than the prompt", ErrContextLength)
+	}
+	return p.guard.Reduce(p, parts, options)
+}
+
+// Stream implements providers.Provider
+func (p *guardedProvider) Stream(prompt string, options config.CompletionOptions, callback func(chunk string)) error {
+	return providers.StreamCallback(p.StreamEvents(context.Background(), prompt, options), callback)
+}
+
+// StreamEvents implements providers.Streamer. The responses to the chunks
+// of a MapReduce are streamed one after another, separated by blank lines,
+// unless the guard has a Reduce, whose result is sent in one piece.
+func (p *guardedProvider) StreamEvents(ctx context.Context, prompt string, options config.CompletionOptions) *providers.EventStream {
+	chunks, err := p.guard.Fit(prompt, options)
+	if err != nil {
+		return providers.NewEventStream(ctx, func(context.Context, func(providers.StreamEvent) bool) error {
+			return err
+		})
+	}
+	if len(chunks) == 1 {
+		return providers.OpenStream(ctx, p.Provider, chunks[0], withPrompt(options, prompt, chunks[0]))
+	}
+
+	return providers.NewEventStream(ctx, func(ctx context.Context, emit func(providers.StreamEvent) bool) error {
+		if p.guard.Reduce != nil {
+			text, err := p.Complete(prompt, options)
+			if err != nil {
+				return err
+			}
+			if !emit(providers.StreamEvent{Type: providers.EventText, Text: text}) {
+				return ctx.Err()
+			}
+			return nil
+		}
+
+		for i, chunk := range chunks {
+			if i > 0 && !emit(providers.StreamEvent{Type: providers.EventText, Text: "\n\n"}) {
+				return ctx.Err()
+			}
+			if err := forward(ctx, providers.OpenStream(ctx, p.Provider, chunk, withPrompt(options, prompt, chunk)), emit); err != nil {
+				return fmt.Errorf("chunk %d of %d: %w", i+1, len(chunks), err)
+			}
+		}
+		return nil
+	})
+}
+
+// forward emits the text of a stream, closing it
+func forward(ctx context.Context, stream *providers.EventStream, emit func(providers.StreamEvent) bool) error {
+	defer stream.Close()
+
+	for event := range stream.Events() {
+		switch event.Type {
+		case providers.EventText:
+			if !emit(event) {
+				return ctx.Err()
+			}
+		case providers.EventError:
+			return event.Err
+		}
+	}
+	return stream.Err()
+}
+
+// withPrompt returns the options with template variables holding the
+// original prompt set to its replacement, so a template that reads the
+// prompt from a variable gets the shortened one
+func withPrompt(options config.CompletionOptions, original, replacement string) config.CompletionOptions {
+	if original == replacement || len(options.Variables) == 0 {
+		return options
+	}
+	vars := make(map[string]interface{}, len(options.Variables))
+	for name, value := range options.Variables {
+		if s, ok := value.(string); ok && s == original {
+			value = replacement
+		}
+		vars[name] = value
+	}
+	options.Variables = vars
+	return options
+}
@@ -0,0 +1,127 @@
+package tokens
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// boundary is a level at which text is split into pieces
+type boundary struct {
+	split func(text string) []string
+	join  string
+}
+
+var paragraphBreak = regexp.MustCompile(`\n[ \t]*\n`)
+
+// boundaries are tried in order, each splitting the pieces the previous one
+// left too long
+var boundaries = []boundary{
+	{split: func(text string) []string { return paragraphBreak.Split(text, -1) }, join: "\n\n"},
+	{split: func(text string) []string { return strings.Split(text, "\n") }, join: "\n"},
+	{split: sentences, join: " "},
+	{split: strings.Fields, join: " "},
+}
+
+// Split splits text into chunks of at most budget tokens. It breaks between
+// paragraphs where it can, then between lines, sentences and words, and
+// cuts words that do not fit on their own. Pieces are packed greedily, so
+// chunks are as large as the budget allows. Counts of joined pieces are
+// summed, so with an exact tokenizer a chunk may be off by a few tokens.
+func Split(tokenizer Tokenizer, text string, budget int) []string {
+	if budget < 1 {
+		budget = 1
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	if tokenizer.Count(text) <= budget {
+		return []string{text}
+	}
+	return split(tokenizer, text, budget, 0)
+}
+
+// split splits text at the boundary of a level, recursing into the pieces
+// that are too long
+func split(tokenizer Tokenizer, text string, budget, level int) []string {
+	if level == len(boundaries) {
+		return cut(tokenizer, text, budget)
+	}
+	b := boundaries[level]
+	joinTokens := tokenizer.Count(b.join)
+
+	var chunks, current []string
+	currentTokens := 0
+	flush := func() {
+		if len(current) > 0 {
+			chunks = append(chunks, strings.Join(current, b.join))
+			current, currentTokens = nil, 0
+		}
+	}
+
+	for _, piece := range b.split(text) {
+		piece = strings.TrimSpace(piece)
+		if piece == "" {
+			continue
+		}
+		n := tokenizer.Count(piece)
+		if n > budget {
+			flush()
+			chunks = append(chunks, split(tokenizer, piece, budget, level+1)...)
+			continue
+		}
+		if len(current) > 0 && currentTokens+joinTokens+n > budget {
+			flush()
+		}
+		if len(current) > 0 {
+			currentTokens += joinTokens
+		}
+		current = append(current, piece)
+		currentTokens += n
+	}
+	flush()
+	return chunks
+}
+
+// cut splits text into pieces of at most budget tokens regardless of words
+func cut(tokenizer Tokenizer, text string, budget int) []string {
+	var pieces []string
+	for text != "" {
+		head := tokenizer.Head(text, budget)
+		if head == "" {
+			// Not even one character fits; take it anyway to make progress
+			head = string([]rune(text)[:1])
+		}
+		pieces = append(pieces, head)
+		text = strings.TrimLeftFunc(text[len(head):], unicode.IsSpace)
+	}
+	return pieces
+}
+
+// sentences splits text after sentence ending punctuation followed by
+// whitespace, and after CJK full stops
+func sentences(text string) []string {
+	var pieces []string
+	start := 0
+	runes := []rune(text)
+	offset := 0
+	for i, r := range runes {
+		size := len(string(r))
+		end := false
+		switch r {
+		case '.', '!', '?':
+			end = i+1 < len(runes) && unicode.IsSpace(runes[i+1])
+		case '。', '！', '？':
+			end = true
+		}
+		offset += size
+		if end {
+			pieces = append(pieces, text[start:offset])
+			start = offset
+		}
+	}
+	if start < len(text) {
+		pieces = append(pieces, text[start:])
+	}
+	return pieces
+}
@@ -0,0 +1,251 @@
+package tokens
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// Tokenizer counts the tokens of text as a model sees them
+type Tokenizer interface {
+	// Count returns the number of tokens in text
+	Count(text string) int
+
+	// Head returns the longest start of text with at most n tokens
+	Head(text string, n int) string
+
+	// Tail returns the longest end of text with at most n tokens
+	Tail(text string, n int) string
+
+	// Exact reports whether counts are exact rather than estimates
+	Exact() bool
+}
+
+// encodingRetry is how long For waits before trying again to load an
+// encoding that failed to load
+const encodingRetry = 10 * time.Minute
+
+// encodings maps model prefixes to the BPE encodings of OpenAI models.
+// IDs match the entry with the longest prefix.
+var encodings = map[string]string{
+	"gpt-4o":                 tiktoken.MODEL_O200K_BASE,
+	"gpt-4.1":                tiktoken.MODEL_O200K_BASE,
+	"chatgpt-4o":             tiktoken.MODEL_O200K_BASE,
+	"o1":                     tiktoken.MODEL_O200K_BASE,
+	"o3":                     tiktoken.MODEL_O200K_BASE,
+	"o4":                     tiktoken.MODEL_O200K_BASE,
+	"gpt-4":                  tiktoken.MODEL_CL100K_BASE,
+	"gpt-3.5-turbo":          tiktoken.MODEL_CL100K_BASE,
+	"text-embedding-3":       tiktoken.MODEL_CL100K_BASE,
+	"text-embedding-ada-002": tiktoken.MODEL_CL100K_BASE,
+	"gpt2":                   tiktoken.MODEL_R50K_BASE,
+}
+
+// DefaultEstimator is used for models without a known tokenizer
+var DefaultEstimator = Estimator{CharsPerToken: 4}
+
+var registry = struct {
+	sync.Mutex
+	tokenizers map[string]Tokenizer
+	loaded     map[string]*tiktoken.Tiktoken
+	loading    map[string]bool
+	failed     map[string]time.Time
+}{
+	tokenizers: make(map[string]Tokenizer),
+	loaded:     make(map[string]*tiktoken.Tiktoken),
+	loading:    make(map[string]bool),
+	failed:     make(map[string]time.Time),
+}
+
+// Register sets the tokenizer of the models whose IDs start with prefix.
+// Registered tokenizers take precedence over the built-in ones.
+func Register(prefix string, tokenizer Tokenizer) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.tokenizers[prefix] = tokenizer
+}
+
+// For returns the tokenizer of a model: a registered one, or the exact BPE
+// encoding of OpenAI models, or else DefaultEstimator. BPE encodings are
+// loaded in the background on first use, downloading them into
+// TIKTOKEN_CACHE_DIR if they are not cached there, and the estimate is
+// used until they are loaded, or until the download is retried if it
+// failed.
+func For(model string) Tokenizer {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if prefix := longestPrefix(model, registry.tokenizers); prefix != "" {
+		return registry.tokenizers[prefix]
+	}
+
+	prefix := longestPrefix(model, encodings)
+	if prefix == "" {
+		return DefaultEstimator
+	}
+	name := encodings[prefix]
+	if encoding, ok := registry.loaded[name]; ok {
+		return bpe{encoding: encoding}
+	}
+	if registry.loading[name] {
+		return DefaultEstimator
+	}
+	if failed, ok := registry.failed[name]; ok && time.Since(failed) < encodingRetry {
+		return DefaultEstimator
+	}
+
+	registry.loading[name] = true
+	go load(name)
+	return DefaultEstimator
+}
+
+// load loads an encoding without holding the registry lock, since it may
+// take as long as a download
+func load(name string) {
+	encoding, err := tiktoken.GetEncoding(name)
+
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.loading, name)
+	if err != nil {
+		registry.failed[name] = time.Now()
+		return
+	}
+	registry.loaded[name] = encoding
+	delete(registry.failed, name)
+}
+
+// longestPrefix returns the longest key of m that model starts with
+func longestPrefix[T any](model string, m map[string]T) string {
+	best := ""
+	for prefix := range m {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	return best
+}
+
+// bpe counts tokens exactly with a byte pair encoding
+type bpe struct {
+	encoding *tiktoken.Tiktoken
+}
+
+func (b bpe) Count(text string) int {
+	return len(b.encoding.EncodeOrdinary(text))
+}
+
+func (b bpe) Head(text string, n int) string {
+	ids := b.encoding.EncodeOrdinary(text)
+	if len(ids) <= n {
+		return text
+	}
+	// A cut through a multi-byte character leaves a partial one behind
+	return strings.ToValidUTF8(b.encoding.Decode(ids[:max(n, 0)]), "")
+}
+
+func (b bpe) Tail(text string, n int) string {
+	ids := b.encoding.EncodeOrdinary(text)
+	if len(ids) <= n {
+		return text
+	}
+	return strings.ToValidUTF8(b.encoding.Decode(ids[len(ids)-max(n, 0):]), "")
+}
+
+func (b bpe) Exact() bool {
+	return true
+}
+
+// Estimator estimates token counts from the length of text
+type Estimator struct {
+	// CharsPerToken is the average length of a token in alphabetic text.
+	// CJK characters count as a token each.
+	CharsPerToken float64
+}
+
+// Count estimates the number of tokens in text
+func (e Estimator) Count(text string) int {
+	chars, wide := 0, 0
+	for _, r := range text {
+		if isWide(r) {
+			wide++
+		} else {
+			chars++
+		}
+	}
+	perToken := e.CharsPerToken
+	if perToken <= 0 {
+		perToken = DefaultEstimator.CharsPerToken
+	}
+	return int(math.Ceil(float64(chars)/perToken)) + wide
+}
+
+// Head returns the longest start of text estimated to have at most n
+// tokens, cut between words where possible
+func (e Estimator) Head(text string, n int) string {
+	if e.Count(text) <= n {
+		return text
+	}
+	runes := []rune(text)
+	cut := fit(len(runes), n, func(i int) int { return e.Count(string(runes[:i])) })
+
+	// Prefer to end at a word boundary in the last tenth of the cut
+	for i := cut; i > cut-cut/10 && i > 0; i-- {
+		if unicode.IsSpace(runes[i-1]) {
+			return string(runes[:i])
+		}
+	}
+	return string(runes[:cut])
+}
+
+// Tail returns the longest end of text estimated to have at most n tokens,
+// cut between words where possible
+func (e Estimator) Tail(text string, n int) string {
+	if e.Count(text) <= n {
+		return text
+	}
+	runes := []rune(text)
+	cut := fit(len(runes), n, func(i int) int { return e.Count(string(runes[len(runes)-i:])) })
+
+	start := len(runes) - cut
+	for i := start; i < start+cut/10 && i < len(runes); i++ {
+		if unicode.IsSpace(runes[i]) {
+			return string(runes[i+1:])
+		}
+	}
+	return string(runes[start:])
+}
+
+// Exact reports false, since counts are estimates
+func (e Estimator) Exact() bool {
+	return false
+}
+
+// fit returns the largest length up to size whose count is at most n,
+// assuming counts grow with length
+func fit(size, n int, count func(length int) int) int {
+	low, high := 0, size
+	for low < high {
+		mid := (low + high + 1) / 2
+		if count(mid) <= n {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	return low
+}
+
+// isWide reports whether r is a CJK character, which tokenizers usually
+// encode as at least one token
+func isWide(r rune) bool {
+	return r >= utf8.RuneSelf && (unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r))
+}
@@ -0,0 +1,391 @@
+package vector
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// compactAfter is the number of logged changes that makes a FileStore
+// rewrite its file
+const compactAfter = 1000
+
+// ErrClosed is returned by stores used after Close
+var ErrClosed = errors.New("vector store is closed")
+
+// FileStore is a Store kept in a file and indexed by an HNSW graph, which
+// answers queries approximately in logarithmic time. Changes are appended
+// to a log next to the file as they are made, and folded into the file
+// when the log grows long or the store is closed, so a crash loses at most
+// a partially written change.
+type FileStore struct {
+	path string
+
+	mu     sync.RWMutex
+	graph  *hnsw
+	ids    map[string]int32
+	dim    int
+	log    *os.File
+	logged int
+}
+
+// fileSnapshot is the format of FileStore files
+type fileSnapshot struct {
+	Options HNSWOptions
+	Entry   int32
+	Nodes   []fileNode
+}
+
+// fileNode is a graph node in a FileStore file
+type fileNode struct {
+	Item    Item
+	Friends [][]int32
+	Deleted bool
+}
+
+// fileChange is a line of a FileStore log
+type fileChange struct {
+	Upsert []Item   `json:"upsert,omitempty"`
+	Delete []string `json:"delete,omitempty"`
+}
+
+// OpenFileStore opens the store kept at path, creating it if it does not
+// exist. M and EfConstruction only apply to new files; EfSearch can change
+// each time the store is opened.
+func OpenFileStore(path string, options HNSWOptions) (*FileStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &FileStore{path: path, graph: newHNSW(options), ids: make(map[string]int32)}
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("reading vector store %s: %w", path, err)
+	}
+	if options.EfSearch > 0 {
+		s.graph.options.EfSearch = options.EfSearch
+	}
+
+	replayed, err := s.replay()
+	if err != nil {
+		return nil, fmt.Errorf("reading vector store log %s: %w", s.logPath(), err)
+	}
+	s.log, err = os.OpenFile(s.logPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if replayed > 0 {
+		if err := s.compact(); err != nil {
+			s.log.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// RemoveFileStore deletes the files of the store kept at path, which must
+// be closed
+func RemoveFileStore(path string) error {
+	for _, name := range []string{path, path + ".log"} {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// openFile opens a FileStore from a URL such as
+// file:data/vectors.hnsw?m=16&ef_construction=200&ef=64
+func openFile(u *url.URL) (Store, error) {
+	path := u.Opaque
+	if path == "" {
+		path = u.Host + u.Path
+	}
+	if path == "" {
+		return nil, fmt.Errorf("a path is required for a file vector store")
+	}
+
+	var options HNSWOptions
+	for key, value := range map[string]*int{"m": &options.M, "ef_construction": &options.EfConstruction, "ef": &options.EfSearch} {
+		if raw := u.Query().Get(key); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q for a file vector store", key, raw)
+			}
+			*value = n
+		}
+	}
+	return OpenFileStore(path, options)
+}
+
+// Upsert inserts items, replacing existing items with the same ID. Every
+// vector must have the length of the first one stored.
+func (s *FileStore) Upsert(ctx context.Context, items ...Item) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.log == nil {
+		return ErrClosed
+	}
+	dim := s.dim
+	for _, item := range items {
+		if dim == 0 {
+			dim = len(item.Vector)
+		}
+		if len(item.Vector) != dim || dim == 0 {
+			return fmt.Errorf("vector of %s has %d dimensions, want %d", item.ID, len(item.Vector), dim)
+		}
+	}
+	if err := s.append(fileChange{Upsert: items}); err != nil {
+		return err
+	}
+	s.upsert(items)
+	return s.maybeCompact()
+}
+
+// Delete removes the items with the given IDs, ignoring unknown ones
+func (s *FileStore) Delete(ctx context.Context, ids ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.log == nil {
+		return ErrClosed
+	}
+	if err := s.append(fileChange{Delete: ids}); err != nil {
+		return err
+	}
+	s.delete(ids)
+	return s.maybeCompact()
+}
+
+// Query returns the k items matching the filter most similar to the
+// vector, best first
+func (s *FileStore) Query(ctx context.Context, vector []float32, k int, filter Filter) ([]Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.log == nil {
+		return nil, ErrClosed
+	}
+	if s.dim != 0 && len(vector) != s.dim {
+		return nil, fmt.Errorf("query has %d dimensions, want %d", len(vector), s.dim)
+	}
+	return s.graph.search(vector, k, filter), nil
+}
+
+// Count returns the number of items matching the filter
+func (s *FileStore) Count(ctx context.Context, filter Filter) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.log == nil {
+		return 0, ErrClosed
+	}
+	if len(filter) == 0 {
+		return len(s.ids), nil
+	}
+	n := 0
+	for _, id := range s.ids {
+		if filter.Matches(s.graph.nodes[id].item.Metadata) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Close folds the log into the file and closes the store
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.log == nil {
+		return nil
+	}
+	err := s.compact()
+	if closeErr := s.log.Close(); err == nil {
+		err = closeErr
+	}
+	s.log = nil
+	return err
+}
+
+// upsert applies inserted items to the graph
+func (s *FileStore) upsert(items []Item) {
+	for _, item := range items {
+		if old, exists := s.ids[item.ID]; exists {
+			s.graph.remove(old)
+		}
+		s.ids[item.ID] = s.graph.insert(item)
+		if s.dim == 0 {
+			s.dim = len(item.Vector)
+		}
+	}
+}
+
+// delete applies deleted IDs to the graph
+func (s *FileStore) delete(ids []string) {
+	for _, id := range ids {
+		if node, exists := s.ids[id]; exists {
+			s.graph.remove(node)
+			delete(s.ids, id)
+		}
+	}
+}
+
+// logPath returns the path of the change log
+func (s *FileStore) logPath() string {
+	return s.path + ".log"
+}
+
+// append writes a change to the log
+func (s *FileStore) append(change fileChange) error {
+	line, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	if _, err := s.log.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing vector store log: %w", err)
+	}
+	s.logged++
+	return nil
+}
+
+// maybeCompact folds the log into the file once it is long
+func (s *FileStore) maybeCompact() error {
+	if s.logged < compactAfter {
+		return nil
+	}
+	return s.compact()
+}
+
+// load reads the file, if there is one
+func (s *FileStore) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var snapshot fileSnapshot
+	if err := gob.NewDecoder(f).Decode(&snapshot); err != nil {
+		return err
+	}
+
+	graph := newHNSW(snapshot.Options)
+	graph.entry = snapshot.Entry
+	graph.nodes = make([]*hnswNode, len(snapshot.Nodes))
+	for i, n := range snapshot.Nodes {
+		graph.nodes[i] = &hnswNode{item: n.Item, unit: normalize(n.Item.Vector), friends: n.Friends, deleted: n.Deleted}
+		if n.Deleted {
+			graph.deleted++
+			continue
+		}
+		s.ids[n.Item.ID] = int32(i)
+		s.dim = len(n.Item.Vector)
+	}
+	s.graph = graph
+	return nil
+}
+
+// replay applies the changes logged since the file was written. A torn
+// last line, left by a crash while writing it, is cut off so later changes
+// are not appended to it.
+func (s *FileStore) replay() (int, error) {
+	f, err := os.Open(s.logPath())
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	replayed := 0
+	var complete int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				return replayed, os.Truncate(s.logPath(), complete)
+			}
+			return replayed, nil
+		}
+		if err != nil {
+			return replayed, err
+		}
+
+		var change fileChange
+		if err := json.Unmarshal(line, &change); err != nil {
+			return replayed, err
+		}
+		s.upsert(change.Upsert)
+		s.delete(change.Delete)
+		replayed++
+		complete += int64(len(line))
+	}
+}
+
+// compact writes the graph to the file, rebuilt without deleted nodes if
+// they outnumber the others, and empties the log
+func (s *FileStore) compact() error {
+	if s.graph.deleted > s.graph.live() {
+		s.graph = s.graph.rebuild()
+		s.ids = make(map[string]int32, len(s.graph.nodes))
+		for i, node := range s.graph.nodes {
+			s.ids[node.item.ID] = int32(i)
+		}
+	}
+
+	snapshot := fileSnapshot{Options: s.graph.options, Entry: s.graph.entry, Nodes: make([]fileNode, len(s.graph.nodes))}
+	for i, node := range s.graph.nodes {
+		snapshot.Nodes[i] = fileNode{Item: node.item, Friends: node.friends, Deleted: node.deleted}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(&snapshot); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing vector store %s: %w", s.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+
+	// The file now holds every logged change, so replaying them again
+	// after a crash here would be harmless
+	if err := s.log.Truncate(0); err != nil {
+		return err
+	}
+	s.logged = 0
+	return nil
+}
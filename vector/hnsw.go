@@ -0,0 +1,330 @@
+package vector
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Defaults for the HNSW options left at zero
+const (
+	DefaultM              = 16
+	DefaultEfConstruction = 200
+	DefaultEfSearch       = 64
+)
+
+// maxLevel caps the layers of an HNSW graph
+const maxLevel = 16
+
+// HNSWOptions tune an HNSW graph. Zero values select the defaults.
+type HNSWOptions struct {
+	// M is the number of neighbors a node links to on each layer, twice
+	// that on the bottom layer. Higher values improve recall and cost
+	// memory.
+	M int
+
+	// EfConstruction is the number of candidates considered when linking a
+	// new node
+	EfConstruction int
+
+	// EfSearch is the number of candidates considered by a query, raised to
+	// k when smaller
+	EfSearch int
+}
+
+// withDefaults fills in the options left at zero
+func (o HNSWOptions) withDefaults() HNSWOptions {
+	if o.M <= 0 {
+		o.M = DefaultM
+	}
+	if o.EfConstruction <= 0 {
+		o.EfConstruction = DefaultEfConstruction
+	}
+	if o.EfSearch <= 0 {
+		o.EfSearch = DefaultEfSearch
+	}
+	return o
+}
+
+// hnswNode is an item in the graph, with its links on each of its layers.
+// Deleted nodes stay in the graph to keep it connected, but are never
+// returned.
+type hnswNode struct {
+	item    Item
+	unit    []float32
+	friends [][]int32
+	deleted bool
+}
+
+// hnsw is a Hierarchical Navigable Small World graph, which finds
+// approximate nearest neighbors by greedy search from a sparse top layer
+// down to the bottom layer holding every node. It is not safe for
+// concurrent use.
+type hnsw struct {
+	options   HNSWOptions
+	nodes     []*hnswNode
+	entry     int32
+	deleted   int
+	levelMult float64
+	rng       *rand.Rand
+}
+
+// newHNSW creates an empty graph
+func newHNSW(options HNSWOptions) *hnsw {
+	options = options.withDefaults()
+	return &hnsw{
+		options:   options,
+		entry:     -1,
+		levelMult: 1 / math.Log(float64(options.M)),
+		rng:       rand.New(rand.NewSource(1)),
+	}
+}
+
+// live returns the number of nodes that are not deleted
+func (h *hnsw) live() int {
+	return len(h.nodes) - h.deleted
+}
+
+// insert adds an item to the graph and returns its node
+func (h *hnsw) insert(item Item) int32 {
+	id := int32(len(h.nodes))
+	level := h.randomLevel()
+	node := &hnswNode{item: item, unit: normalize(item.Vector), friends: make([][]int32, level+1)}
+	h.nodes = append(h.nodes, node)
+	if h.entry < 0 {
+		h.entry = id
+		return id
+	}
+
+	top := len(h.nodes[h.entry].friends) - 1
+	cur := h.entry
+	for l := top; l > level; l-- {
+		cur = h.greedy(node.unit, cur, l)
+	}
+	for l := min(level, top); l >= 0; l-- {
+		found := h.searchLayer(node.unit, cur, h.options.EfConstruction, l)
+		limit := h.maxFriends(l)
+		for i := 0; i < len(found) && i < limit; i++ {
+			node.friends[l] = append(node.friends[l], found[i].id)
+			h.link(found[i].id, id, l)
+		}
+		cur = found[0].id
+	}
+	if level > top {
+		h.entry = id
+	}
+	return id
+}
+
+// remove marks a node deleted
+func (h *hnsw) remove(id int32) {
+	node := h.nodes[id]
+	if !node.deleted {
+		node.deleted = true
+		node.item.Metadata = nil
+		h.deleted++
+	}
+}
+
+// search returns the k nodes accepted by the filter nearest to the query.
+// When too few of the candidates pass the filter, the search widens, and
+// it falls back to comparing every node once it would visit them all.
+func (h *hnsw) search(query []float32, k int, filter Filter) []Result {
+	if h.entry < 0 || k <= 0 {
+		return nil
+	}
+	unit := normalize(query)
+
+	cur := h.entry
+	for l := len(h.nodes[h.entry].friends) - 1; l > 0; l-- {
+		cur = h.greedy(unit, cur, l)
+	}
+
+	for ef := max(h.options.EfSearch, k); ef < len(h.nodes); ef *= 2 {
+		var results []Result
+		for _, c := range h.searchLayer(unit, cur, ef, 0) {
+			node := h.nodes[c.id]
+			if !node.deleted && filter.Matches(node.item.Metadata) {
+				results = append(results, Result{Item: node.item, Score: float64(1 - c.dist)})
+			}
+		}
+		if len(results) >= k {
+			return results[:k]
+		}
+	}
+
+	var results []Result
+	for _, node := range h.nodes {
+		if !node.deleted && filter.Matches(node.item.Metadata) {
+			results = append(results, Result{Item: node.item, Score: float64(1 - distance(unit, node.unit))})
+		}
+	}
+	return rank(results, k)
+}
+
+// rebuild returns a graph of the nodes that are not deleted
+func (h *hnsw) rebuild() *hnsw {
+	graph := newHNSW(h.options)
+	for _, node := range h.nodes {
+		if !node.deleted {
+			graph.insert(node.item)
+		}
+	}
+	return graph
+}
+
+// greedy walks a layer from a node towards the query while a neighbor is
+// nearer, returning the nearest node found
+func (h *hnsw) greedy(query []float32, cur int32, layer int) int32 {
+	best := distance(query, h.nodes[cur].unit)
+	for changed := true; changed; {
+		changed = false
+		for _, friend := range h.nodes[cur].friends[layer] {
+			if d := distance(query, h.nodes[friend].unit); d < best {
+				cur, best, changed = friend, d, true
+			}
+		}
+	}
+	return cur
+}
+
+// searchLayer returns up to ef nodes of a layer nearest to the query,
+// nearest first, exploring from the entry node
+func (h *hnsw) searchLayer(query []float32, entry int32, ef, layer int) []candidate {
+	start := candidate{id: entry, dist: distance(query, h.nodes[entry].unit)}
+	visited := map[int32]bool{entry: true}
+	pending := &nearestFirst{start}
+	found := &farthestFirst{start}
+
+	for pending.Len() > 0 {
+		c := heap.Pop(pending).(candidate)
+		if found.Len() >= ef && c.dist > (*found)[0].dist {
+			break
+		}
+		for _, friend := range h.nodes[c.id].friends[layer] {
+			if visited[friend] {
+				continue
+			}
+			visited[friend] = true
+
+			d := distance(query, h.nodes[friend].unit)
+			if found.Len() < ef || d < (*found)[0].dist {
+				heap.Push(pending, candidate{id: friend, dist: d})
+				heap.Push(found, candidate{id: friend, dist: d})
+				if found.Len() > ef {
+					heap.Pop(found)
+				}
+			}
+		}
+	}
+
+	nearest := []candidate(*found)
+	sort.Slice(nearest, func(i, j int) bool {
+		return nearest[i].dist < nearest[j].dist
+	})
+	return nearest
+}
+
+// link adds a neighbor to a node on a layer, dropping its farthest
+// neighbor when it has too many
+func (h *hnsw) link(id, friend int32, layer int) {
+	node := h.nodes[id]
+	node.friends[layer] = append(node.friends[layer], friend)
+	limit := h.maxFriends(layer)
+	if len(node.friends[layer]) <= limit {
+		return
+	}
+
+	nearest := make([]candidate, len(node.friends[layer]))
+	for i, f := range node.friends[layer] {
+		nearest[i] = candidate{id: f, dist: distance(node.unit, h.nodes[f].unit)}
+	}
+	sort.Slice(nearest, func(i, j int) bool {
+		return nearest[i].dist < nearest[j].dist
+	})
+	friends := node.friends[layer][:0]
+	for _, c := range nearest[:limit] {
+		friends = append(friends, c.id)
+	}
+	node.friends[layer] = friends
+}
+
+// maxFriends returns the number of neighbors nodes keep on a layer
+func (h *hnsw) maxFriends(layer int) int {
+	if layer == 0 {
+		return 2 * h.options.M
+	}
+	return h.options.M
+}
+
+// randomLevel draws the top layer of a new node, each layer being M times
+// sparser than the one below
+func (h *hnsw) randomLevel() int {
+	level := int(-math.Log(1-h.rng.Float64()) * h.levelMult)
+	return min(level, maxLevel)
+}
+
+// candidate is a node with its distance to a query
+type candidate struct {
+	id   int32
+	dist float32
+}
+
+// nearestFirst is a heap of candidates with the nearest on top
+type nearestFirst []candidate
+
+func (q nearestFirst) Len() int            { return len(q) }
+func (q nearestFirst) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q nearestFirst) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *nearestFirst) Push(x interface{}) { *q = append(*q, x.(candidate)) }
+func (q *nearestFirst) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// farthestFirst is a heap of candidates with the farthest on top
+type farthestFirst []candidate
+
+func (q farthestFirst) Len() int            { return len(q) }
+func (q farthestFirst) Less(i, j int) bool  { return q[i].dist > q[j].dist }
+func (q farthestFirst) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *farthestFirst) Push(x interface{}) { *q = append(*q, x.(candidate)) }
+func (q *farthestFirst) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// normalize returns the vector scaled to unit length, so the dot product
+// of two normalized vectors is their cosine similarity
+func normalize(v []float32) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	unit := make([]float32, len(v))
+	if norm == 0 {
+		return unit
+	}
+	scale := 1 / math.Sqrt(norm)
+	for i, x := range v {
+		unit[i] = float32(float64(x) * scale)
+	}
+	return unit
+}
+
+// distance returns the cosine distance of two normalized vectors
+func distance(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 1
+	}
+	var dot float32
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return 1 - dot
+}
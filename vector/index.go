@@ -0,0 +1,167 @@
+package vector
+
+import (
+	"context"
+	"math"
+	"sync"
+)
+
+// Item is a vector with an identifier and optional metadata
+type Item struct {
+	ID       string
+	Vector   []float32
+	Metadata map[string]string
+}
+
+// Result is an item returned by a search together with its similarity to
+// the query
+type Result struct {
+	Item
+	Score float64
+}
+
+// Index is an in-memory vector index that searches by brute-force cosine
+// similarity. It is safe for concurrent use and implements Store.
+type Index struct {
+	mu    sync.RWMutex
+	items []Item
+	byID  map[string]int
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{
+		byID: make(map[string]int),
+	}
+}
+
+// Add inserts items into the index, replacing any existing items with the
+// same ID
+func (idx *Index) Add(items ...Item) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, item := range items {
+		if i, exists := idx.byID[item.ID]; exists {
+			idx.items[i] = item
+			continue
+		}
+		idx.byID[item.ID] = len(idx.items)
+		idx.items = append(idx.items, item)
+	}
+}
+
+// Remove deletes the items with the given IDs, ignoring unknown ones
+func (idx *Index) Remove(ids ...string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	removed := false
+	for _, id := range ids {
+		if _, exists := idx.byID[id]; exists {
+			delete(idx.byID, id)
+			removed = true
+		}
+	}
+	if !removed {
+		return
+	}
+
+	kept := idx.items[:0]
+	for _, item := range idx.items {
+		if _, exists := idx.byID[item.ID]; exists {
+			idx.byID[item.ID] = len(kept)
+			kept = append(kept, item)
+		}
+	}
+	idx.items = kept
+}
+
+// Len returns the number of items in the index
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.items)
+}
+
+// Search returns the k items most similar to the query, best first
+func (idx *Index) Search(query []float32, k int) []Result {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	results := make([]Result, 0, len(idx.items))
+	for _, item := range idx.items {
+		results = append(results, Result{Item: item, Score: Cosine(query, item.Vector)})
+	}
+
+	return rank(results, k)
+}
+
+// Upsert inserts items like Add
+func (idx *Index) Upsert(ctx context.Context, items ...Item) error {
+	idx.Add(items...)
+	return nil
+}
+
+// Delete removes items like Remove
+func (idx *Index) Delete(ctx context.Context, ids ...string) error {
+	idx.Remove(ids...)
+	return nil
+}
+
+// Query returns the k items matching the filter most similar to the
+// vector, best first
+func (idx *Index) Query(ctx context.Context, vector []float32, k int, filter Filter) ([]Result, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var results []Result
+	for _, item := range idx.items {
+		if filter.Matches(item.Metadata) {
+			results = append(results, Result{Item: item, Score: Cosine(vector, item.Vector)})
+		}
+	}
+	return rank(results, k), nil
+}
+
+// Count returns the number of items matching the filter
+func (idx *Index) Count(ctx context.Context, filter Filter) (int, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if len(filter) == 0 {
+		return len(idx.items), nil
+	}
+	n := 0
+	for _, item := range idx.items {
+		if filter.Matches(item.Metadata) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Close does nothing; an Index lives in memory
+func (idx *Index) Close() error {
+	return nil
+}
+
+// Cosine returns the cosine similarity of two vectors, or 0 if their
+// lengths differ or either is all zeros
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,292 @@
+package vector
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// qdrantIDKey is the payload field holding item IDs, which Qdrant does not
+// accept as point IDs
+const qdrantIDKey = "neurogo_id"
+
+// QdrantStore is a Store backed by a Qdrant collection, used through its
+// REST API. The collection is created with cosine distance on the first
+// upsert. Metadata is stored as payload fields, so filters match payload
+// values. Results carry no vectors, to keep responses small.
+type QdrantStore struct {
+	baseURL    string
+	collection string
+	apiKey     string
+	client     *http.Client
+
+	mu    sync.Mutex
+	ready bool
+}
+
+// NewQdrantStore creates a store for a collection of the Qdrant server at
+// baseURL, such as http://localhost:6333. apiKey may be empty.
+func NewQdrantStore(baseURL, collection, apiKey string) *QdrantStore {
+	return &QdrantStore{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		collection: collection,
+		apiKey:     apiKey,
+		client:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// openQdrant opens a QdrantStore from a URL such as
+// qdrant://localhost:6333/docs?api_key=secret
+func openQdrant(u *url.URL) (Store, error) {
+	collection := strings.Trim(u.Path, "/")
+	if u.Host == "" || collection == "" {
+		return nil, fmt.Errorf("a Qdrant vector store URL needs a host and a collection, like qdrant://localhost:6333/docs")
+	}
+	scheme := "http"
+	if u.Scheme == "qdrants" {
+		scheme = "https"
+	}
+	return NewQdrantStore(scheme+"://"+u.Host, collection, u.Query().Get("api_key")), nil
+}
+
+// qdrantPoint is a point sent to Qdrant
+type qdrantPoint struct {
+	ID      string            `json:"id"`
+	Vector  []float32         `json:"vector"`
+	Payload map[string]string `json:"payload"`
+}
+
+// qdrantCondition matches a payload field to a value
+type qdrantCondition struct {
+	Key   string `json:"key"`
+	Match struct {
+		Value string `json:"value"`
+	} `json:"match"`
+}
+
+// qdrantFilter is a Qdrant filter of conditions that must all hold
+type qdrantFilter struct {
+	Must []qdrantCondition `json:"must"`
+}
+
+// Upsert inserts items, creating the collection for their vectors if it
+// does not exist
+func (s *QdrantStore) Upsert(ctx context.Context, items ...Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+	if err := s.ensureCollection(ctx, len(items[0].Vector)); err != nil {
+		return err
+	}
+
+	points := make([]qdrantPoint, len(items))
+	for i, item := range items {
+		payload := make(map[string]string, len(item.Metadata)+1)
+		for key, value := range item.Metadata {
+			payload[key] = value
+		}
+		payload[qdrantIDKey] = item.ID
+		points[i] = qdrantPoint{ID: qdrantPointID(item.ID), Vector: item.Vector, Payload: payload}
+	}
+	found, err := s.call(ctx, http.MethodPut, "/points?wait=true", map[string]interface{}{"points": points}, nil)
+	if err == nil && !found {
+		// The collection was deleted behind our back
+		s.mu.Lock()
+		s.ready = false
+		s.mu.Unlock()
+		return fmt.Errorf("qdrant collection %s not found", s.collection)
+	}
+	return err
+}
+
+// Delete removes the items with the given IDs
+func (s *QdrantStore) Delete(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	points := make([]string, len(ids))
+	for i, id := range ids {
+		points[i] = qdrantPointID(id)
+	}
+	_, err := s.call(ctx, http.MethodPost, "/points/delete?wait=true", map[string]interface{}{"points": points}, nil)
+	return err
+}
+
+// Query returns the k items matching the filter most similar to the
+// vector, best first
+func (s *QdrantStore) Query(ctx context.Context, vector []float32, k int, filter Filter) ([]Result, error) {
+	request := map[string]interface{}{
+		"vector":       vector,
+		"limit":        k,
+		"with_payload": true,
+	}
+	if f := qdrantFilterOf(filter); f != nil {
+		request["filter"] = f
+	}
+
+	var points []struct {
+		Score   float64                `json:"score"`
+		Payload map[string]interface{} `json:"payload"`
+	}
+	found, err := s.call(ctx, http.MethodPost, "/points/search", request, &points)
+	if !found || err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(points))
+	for _, point := range points {
+		item := Item{Metadata: make(map[string]string, len(point.Payload))}
+		for key, value := range point.Payload {
+			text, ok := value.(string)
+			if !ok {
+				text = fmt.Sprint(value)
+			}
+			if key == qdrantIDKey {
+				item.ID = text
+				continue
+			}
+			item.Metadata[key] = text
+		}
+		results = append(results, Result{Item: item, Score: point.Score})
+	}
+	return results, nil
+}
+
+// Count returns the number of items matching the filter
+func (s *QdrantStore) Count(ctx context.Context, filter Filter) (int, error) {
+	request := map[string]interface{}{"exact": true}
+	if f := qdrantFilterOf(filter); f != nil {
+		request["filter"] = f
+	}
+
+	var result struct {
+		Count int `json:"count"`
+	}
+	found, err := s.call(ctx, http.MethodPost, "/points/count", request, &result)
+	if !found || err != nil {
+		return 0, err
+	}
+	return result.Count, nil
+}
+
+// Close does nothing; Qdrant persists changes itself
+func (s *QdrantStore) Close() error {
+	return nil
+}
+
+// ensureCollection creates the collection for vectors of a dimension if it
+// does not exist
+func (s *QdrantStore) ensureCollection(ctx context.Context, dim int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ready {
+		return nil
+	}
+	found, err := s.call(ctx, http.MethodGet, "", nil, nil)
+	if err != nil {
+		return err
+	}
+	if !found {
+		config := map[string]interface{}{
+			"vectors": map[string]interface{}{"size": dim, "distance": "Cosine"},
+		}
+		if _, err := s.call(ctx, http.MethodPut, "", config, nil); err != nil {
+			return err
+		}
+	}
+	s.ready = true
+	return nil
+}
+
+// call sends a request about the collection and decodes the result of the
+// response into result. It reports found as false, with no error, when
+// the collection does not exist.
+func (s *QdrantStore) call(ctx context.Context, method, path string, body, result interface{}) (bool, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return false, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	endpoint := s.baseURL + "/collections/" + url.PathEscape(s.collection) + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("api-key", s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("qdrant request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+		Status interface{}     `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil && err != io.EOF {
+		return true, fmt.Errorf("qdrant returned %s: %w", resp.Status, err)
+	}
+	if resp.StatusCode >= 300 {
+		if status, ok := envelope.Status.(map[string]interface{}); ok && status["error"] != nil {
+			return true, fmt.Errorf("qdrant returned %s: %v", resp.Status, status["error"])
+		}
+		return true, fmt.Errorf("qdrant returned %s", resp.Status)
+	}
+	if result != nil && len(envelope.Result) > 0 {
+		if err := json.Unmarshal(envelope.Result, result); err != nil {
+			return true, fmt.Errorf("qdrant returned an invalid result: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// qdrantFilterOf converts a filter, returning nil for an empty one
+func qdrantFilterOf(filter Filter) *qdrantFilter {
+	if len(filter) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	f := &qdrantFilter{}
+	for _, key := range keys {
+		condition := qdrantCondition{Key: key}
+		condition.Match.Value = filter[key]
+		f.Must = append(f.Must, condition)
+	}
+	return f
+}
+
+// qdrantPointID derives a UUID, as Qdrant point IDs must be UUIDs or
+// integers, from an item ID
+func qdrantPointID(id string) string {
+	sum := sha1.Sum([]byte(id))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
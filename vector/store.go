@@ -0,0 +1,116 @@
+package vector
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Store keeps vectors with their metadata and finds the ones most similar
+// to a query. Implementations are safe for concurrent use.
+type Store interface {
+	// Upsert inserts items, replacing existing items with the same ID
+	Upsert(ctx context.Context, items ...Item) error
+
+	// Delete removes the items with the given IDs, ignoring unknown ones
+	Delete(ctx context.Context, ids ...string) error
+
+	// Query returns the k items matching the filter that are most similar
+	// to the vector by cosine similarity, best first
+	Query(ctx context.Context, vector []float32, k int, filter Filter) ([]Result, error)
+
+	// Count returns the number of items matching the filter
+	Count(ctx context.Context, filter Filter) (int, error)
+
+	// Close releases the store, writing out anything it buffers
+	Close() error
+}
+
+// Filter selects items by metadata: an item matches when it has every key
+// of the filter with the same value. An empty filter matches every item.
+type Filter map[string]string
+
+// Matches reports whether metadata satisfies the filter
+func (f Filter) Matches(metadata map[string]string) bool {
+	for key, value := range f {
+		if v, ok := metadata[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Opener opens a store from its URL
+type Opener func(u *url.URL) (Store, error)
+
+var (
+	openersMu sync.RWMutex
+	openers   = map[string]Opener{
+		"memory":  openMemory,
+		"file":    openFile,
+		"qdrant":  openQdrant,
+		"qdrants": openQdrant,
+	}
+)
+
+// Register makes a store available to Open under a URL scheme, replacing
+// any opener registered for it. Adapters for external stores, such as
+// pgvector, register themselves from their own packages so their drivers
+// are only linked when used.
+func Register(scheme string, open Opener) {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+	openers[strings.ToLower(scheme)] = open
+}
+
+// Schemes returns the URL schemes Open accepts, sorted
+func Schemes() []string {
+	openersMu.RLock()
+	defer openersMu.RUnlock()
+
+	schemes := make([]string, 0, len(openers))
+	for scheme := range openers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Open opens the store a URL describes:
+//
+//	memory:                                 in-memory brute-force Index
+//	file:data/vectors.hnsw?m=16&ef=64       FileStore with an HNSW index
+//	qdrant://localhost:6333/docs?api_key=…  QdrantStore, qdrants:// for HTTPS
+func Open(rawURL string) (Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vector store URL: %w", err)
+	}
+
+	openersMu.RLock()
+	open, exists := openers[strings.ToLower(u.Scheme)]
+	openersMu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("unknown vector store %q: use one of %s", u.Scheme, strings.Join(Schemes(), ", "))
+	}
+	return open(u)
+}
+
+// openMemory opens an in-memory store
+func openMemory(u *url.URL) (Store, error) {
+	return NewIndex(), nil
+}
+
+// rank sorts results best first and keeps the top k
+func rank(results []Result, k int) []Result {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
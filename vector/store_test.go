@@ -0,0 +1,154 @@
+package vector_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldotobing/neurogo/neurogotest"
+	"github.com/aldotobing/neurogo/vector"
+)
+
+func TestIndexStore(t *testing.T) {
+	neurogotest.TestStore(t, func(t *testing.T) vector.Store {
+		return vector.NewIndex()
+	})
+}
+
+func TestFileStore(t *testing.T) {
+	neurogotest.TestStore(t, func(t *testing.T) vector.Store {
+		store, err := vector.Open("file:" + filepath.Join(t.TempDir(), "vectors.hnsw") + "?m=8&ef=32")
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		return store
+	})
+}
+
+func TestQdrantStore(t *testing.T) {
+	url := os.Getenv("QDRANT_URL") // e.g. http://localhost:6333
+	if url == "" {
+		t.Skip("QDRANT_URL is not set")
+	}
+	neurogotest.TestStore(t, func(t *testing.T) vector.Store {
+		name := fmt.Sprintf("test_%s_%d", strings.NewReplacer("/", "_", " ", "_").Replace(strings.ToLower(t.Name())), time.Now().UnixNano())
+		t.Cleanup(func() {
+			req, _ := http.NewRequest(http.MethodDelete, strings.TrimRight(url, "/")+"/collections/"+name, nil)
+			if resp, err := http.DefaultClient.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		})
+		return vector.NewQdrantStore(url, name, os.Getenv("QDRANT_API_KEY"))
+	})
+}
+
+func TestFileStoreReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vectors.hnsw")
+
+	store := openFile(t, path)
+	upsert(t, store, "a", 1, 0)
+	upsert(t, store, "b", 0, 1)
+	if err := store.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := store.Count(ctx, nil); err != vector.ErrClosed {
+		t.Errorf("Count after Close returned %v, want %v", err, vector.ErrClosed)
+	}
+
+	store = openFile(t, path)
+	defer store.Close()
+	assertIDs(t, store, "b")
+}
+
+func TestFileStoreTornLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vectors.hnsw")
+	store := openFile(t, path)
+	upsert(t, store, "a", 1, 0)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// A crash while logging a change leaves a partial line behind
+	if err := os.WriteFile(path+".log", []byte(`{"upsert":[{"ID":"b","Vec`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store = openFile(t, path)
+	assertIDs(t, store, "a")
+	upsert(t, store, "c", 0, 1)
+
+	// Simulate another crash, so the log is replayed rather than compacted
+	data, err := os.ReadFile(path + ".log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened := filepath.Join(t.TempDir(), "copy.hnsw")
+	copyFile(t, path, reopened)
+	if err := os.WriteFile(reopened+".log", data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store = openFile(t, reopened)
+	defer store.Close()
+	assertIDs(t, store, "a", "c")
+}
+
+// openFile opens a FileStore, failing the test if it cannot
+func openFile(t *testing.T, path string) *vector.FileStore {
+	t.Helper()
+	store, err := vector.OpenFileStore(path, vector.HNSWOptions{})
+	if err != nil {
+		t.Fatalf("OpenFileStore failed: %v", err)
+	}
+	return store
+}
+
+// upsert adds an item with a two dimensional vector
+func upsert(t *testing.T, store vector.Store, id string, x, y float32) {
+	t.Helper()
+	if err := store.Upsert(context.Background(), vector.Item{ID: id, Vector: []float32{x, y}}); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+}
+
+// assertIDs checks the IDs of every item in the store, in any order
+func assertIDs(t *testing.T, store vector.Store, want ...string) {
+	t.Helper()
+	results, err := store.Query(context.Background(), []float32{1, 1}, 10, nil)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	found := make(map[string]bool, len(results))
+	for _, result := range results {
+		found[result.ID] = true
+	}
+	for _, id := range want {
+		if !found[id] {
+			t.Errorf("%s is missing from %v", id, results)
+		}
+	}
+	if len(results) != len(want) {
+		t.Errorf("store holds %d items, want %d", len(results), len(want))
+	}
+}
+
+// copyFile copies the file at src to dst
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -6,6 +6,7 @@ window.onload = () => {
   checkAPIStatus()
   loadProviders()
   updateCurrentProvider()
+  loadCommandReference()
 }
 
 // API Status Check
@@ -83,6 +84,61 @@ function updateProviderStatus() {
   })
 }
 
+// Escape text for safe insertion into HTML
+function escapeHTML(text) {
+  const div = document.createElement("div")
+  div.textContent = text
+  return div.innerHTML
+}
+
+// Render the command reference from the live route table
+async function loadCommandReference() {
+  const referenceEl = document.getElementById("commandReference")
+  if (!referenceEl) {
+    return
+  }
+
+  try {
+    const response = await fetch("/api/routes")
+    const data = await response.json()
+
+    referenceEl.innerHTML = ""
+    ;(data.groups || []).forEach((group) => {
+      const heading = document.createElement("h4")
+      heading.textContent = group.description || group.name || "Commands"
+      referenceEl.appendChild(heading)
+
+      const grid = document.createElement("div")
+      grid.className = "command-grid"
+
+      group.routes.forEach((route) => {
+        const card = document.createElement("div")
+        card.className = "command-example"
+
+        let html = `<div class="command-title"><span>${escapeHTML(route.usage)}</span></div>`
+        if (route.description) {
+          html += `<div class="command-desc">${escapeHTML(route.description)}</div>`
+        }
+        card.innerHTML = html
+
+        ;(route.examples || []).forEach((example) => {
+          const button = document.createElement("button")
+          button.className = "test-button"
+          button.textContent = example
+          button.onclick = () => testCommand(example)
+          card.appendChild(button)
+        })
+
+        grid.appendChild(card)
+      })
+
+      referenceEl.appendChild(grid)
+    })
+  } catch (error) {
+    referenceEl.innerHTML = "<p>Failed to load commands: " + escapeHTML(error.message) + "</p>"
+  }
+}
+
 // Update current provider display
 async function updateCurrentProvider() {
   try {
@@ -153,6 +209,53 @@ async function testProcessEndpoint() {
   }
 }
 
+// Explain how a prompt would be routed, highlighting the winning route
+async function testExplainEndpoint() {
+  const prompt = document.getElementById("explainPrompt").value
+  const resultEl = document.getElementById("explain-result")
+
+  try {
+    const response = await fetch("/api/route/explain", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ prompt: prompt }),
+    })
+    const data = await response.json()
+    if (!response.ok) {
+      throw new Error(data.error || `HTTP ${response.status}`)
+    }
+
+    const stages = data.stages
+      .map((stage, i) => {
+        const rows = stage.routes
+          .map(
+            (route) => `<tr${route.winner ? ' style="font-weight: bold"' : ""}>
+                <td>${route.winner ? "🏆" : route.matched ? "✅" : "❌"}</td>
+                <td><code>${escapeHTML(route.pattern)}</code></td>
+                <td><code>${escapeHTML(route.regex)}</code></td>
+                <td>${escapeHTML((route.captures || []).join(" | "))}${route.piped ? " (piped)" : ""}</td>
+            </tr>`,
+          )
+          .join("")
+        const outcome = stage.winner
+          ? `Handled by <code>${escapeHTML(stage.winner)}</code>`
+          : stage.fallback
+            ? "No pattern matches; fallbacks would be consulted"
+            : "No route matches"
+        return `<h4>Stage ${i + 1}: ${escapeHTML(stage.prompt)}</h4>
+            <p>${outcome}</p>
+            <table><tr><th></th><th>Pattern</th><th>Regex</th><th>Captures</th></tr>${rows}</table>`
+      })
+      .join("")
+
+    resultEl.innerHTML = `<div class="test-result">${stages}</div>`
+  } catch (error) {
+    resultEl.innerHTML = `<div class="test-result test-error">
+            <strong>Error:</strong> ${escapeHTML(error.message)}
+        </div>`
+  }
+}
+
 // Test commands
 async function testCommand(command) {
   const resultEl = document.getElementById("test-results")
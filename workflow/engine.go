@@ -0,0 +1,458 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aldotobing/neurogo/config"
+	"github.com/aldotobing/neurogo/router"
+)
+
+// DefaultMaxConcurrency is the number of calls an Engine runs at once when
+// its MaxConcurrency is zero
+const DefaultMaxConcurrency = 4
+
+// ErrTimeout is returned when a step attempt exceeds its timeout
+var ErrTimeout = errors.New("step timed out")
+
+// Status is the state of a workflow run or one of its steps
+type Status string
+
+const (
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusSkipped   Status = "skipped"
+)
+
+// Completer is the part of providers.Provider the engine needs
+type Completer interface {
+	Complete(prompt string, options config.CompletionOptions) (string, error)
+}
+
+// ProviderFunc resolves the provider named by a step, or the default one if
+// the name is empty. The returned options, typically just the model, are
+// the base the step's own options are applied to.
+type ProviderFunc func(name string) (Completer, config.CompletionOptions, error)
+
+// Engine runs workflows
+type Engine struct {
+	// Provider resolves providers for prompt steps
+	Provider ProviderFunc
+
+	// Router runs route steps
+	Router *router.Router
+
+	// MaxConcurrency limits the provider, route and func calls running at
+	// once across a run
+	MaxConcurrency int
+}
+
+// Execution is the record of a workflow run
+type Execution struct {
+	Workflow   string        `json:"workflow"`
+	Status     Status        `json:"status"`
+	Output     string        `json:"output,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	DurationMs int64         `json:"duration_ms"`
+	Steps      []*StepResult `json:"steps"`
+}
+
+// StepResult is the record of one step in a run
+type StepResult struct {
+	ID     string `json:"id"`
+	Status Status `json:"status"`
+
+	// Output is the step's result. For ForEach steps it is the item
+	// results joined by blank lines.
+	Output string `json:"output,omitempty"`
+
+	// Outputs holds the result for each item of a ForEach step
+	Outputs []string `json:"outputs,omitempty"`
+
+	// Attempts counts the calls made, including retries
+	Attempts int `json:"attempts"`
+
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// StepError reports the first step that failed in a run
+type StepError struct {
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("workflow step %q failed: %v", e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// run holds the state of a single workflow run
+type run struct {
+	engine  *Engine
+	wf      *Workflow
+	inputs  map[string]interface{}
+	results map[string]*StepResult
+	errs    map[string]error
+	done    map[string]chan struct{}
+	sem     chan struct{}
+	mu      sync.Mutex
+}
+
+// Run executes the workflow with the inputs and returns its execution
+// record. Each step starts as soon as the steps it depends on have
+// succeeded; steps whose dependencies failed are skipped, while
+// independent branches keep running. If any step failed, the record is
+// returned together with a *StepError for the first one in workflow order.
+//
+// Timeouts stop waiting for a call, but providers cannot be interrupted, so
+// a timed out call may keep running in the background.
+func (e *Engine) Run(ctx context.Context, wf *Workflow, inputs map[string]interface{}) (*Execution, error) {
+	if err := wf.Validate(); err != nil {
+		return nil, err
+	}
+	for _, name := range wf.Inputs {
+		if _, exists := inputs[name]; !exists {
+			return nil, fmt.Errorf("workflow %q: missing input %q", wf.Name, name)
+		}
+	}
+
+	concurrency := e.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultMaxConcurrency
+	}
+
+	r := &run{
+		engine:  e,
+		wf:      wf,
+		inputs:  inputs,
+		results: make(map[string]*StepResult, len(wf.Steps)),
+		errs:    make(map[string]error, len(wf.Steps)),
+		done:    make(map[string]chan struct{}, len(wf.Steps)),
+		sem:     make(chan struct{}, concurrency),
+	}
+
+	execution := &Execution{Workflow: wf.Name, StartedAt: time.Now()}
+	for _, step := range wf.Steps {
+		result := &StepResult{ID: step.ID}
+		r.results[step.ID] = result
+		r.done[step.ID] = make(chan struct{})
+		execution.Steps = append(execution.Steps, result)
+	}
+
+	var wg sync.WaitGroup
+	for i := range wf.Steps {
+		wg.Add(1)
+		go func(step *Step) {
+			defer wg.Done()
+			defer close(r.done[step.ID])
+			r.runStep(ctx, step)
+		}(&wf.Steps[i])
+	}
+	wg.Wait()
+
+	execution.Status = StatusSucceeded
+	var failure error
+	for _, step := range wf.Steps {
+		if r.results[step.ID].Status == StatusSucceeded {
+			continue
+		}
+		execution.Status = StatusFailed
+		if err := r.errs[step.ID]; err != nil && failure == nil {
+			failure = &StepError{Step: step.ID, Err: err}
+		}
+	}
+
+	if failure == nil {
+		output, err := r.output()
+		if err != nil {
+			execution.Status = StatusFailed
+			failure = fmt.Errorf("workflow %q: rendering output failed: %w", wf.Name, err)
+		}
+		execution.Output = output
+	}
+
+	execution.DurationMs = time.Since(execution.StartedAt).Milliseconds()
+	if failure != nil {
+		execution.Error = failure.Error()
+	}
+	return execution, failure
+}
+
+// runStep waits for the step's dependencies and runs it
+func (r *run) runStep(ctx context.Context, step *Step) {
+	result := r.results[step.ID]
+	for _, dep := range step.DependsOn {
+		<-r.done[dep]
+	}
+	for _, dep := range step.DependsOn {
+		if r.results[dep].Status != StatusSucceeded {
+			result.Status = StatusSkipped
+			result.Error = fmt.Sprintf("dependency %q did not succeed", dep)
+			return
+		}
+	}
+
+	result.StartedAt = time.Now()
+	outputs, attempts, err := r.execute(ctx, step)
+	result.DurationMs = time.Since(result.StartedAt).Milliseconds()
+	result.Attempts = attempts
+
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = err.Error()
+		r.mu.Lock()
+		r.errs[step.ID] = err
+		r.mu.Unlock()
+		return
+	}
+
+	result.Status = StatusSucceeded
+	if step.ForEach != "" {
+		result.Outputs = outputs
+		result.Output = strings.Join(outputs, "\n\n")
+	} else {
+		result.Output = outputs[0]
+	}
+}
+
+// execute runs a step once, or once per item for ForEach steps, and
+// returns the outputs and the total number of attempts
+func (r *run) execute(ctx context.Context, step *Step) ([]string, int, error) {
+	data := Data{Inputs: r.inputs, Steps: r.completed(step)}
+	if step.ForEach == "" {
+		output, attempts, err := r.retry(ctx, step, data)
+		return []string{output}, attempts, err
+	}
+
+	items, err := r.items(step)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	outputs := make([]string, len(items))
+	attempts := make([]int, len(items))
+	errs := make([]error, len(items))
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		go func(i int, item interface{}) {
+			defer wg.Done()
+			itemData := data
+			itemData.Item, itemData.Index = item, i
+			outputs[i], attempts[i], errs[i] = r.retry(ctx, step, itemData)
+		}(i, item)
+	}
+	wg.Wait()
+
+	total := 0
+	for _, n := range attempts {
+		total += n
+	}
+	for i, err := range errs {
+		if err != nil {
+			return nil, total, fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+	return outputs, total, nil
+}
+
+// retry renders the step and calls it until it succeeds or runs out of
+// retries
+func (r *run) retry(ctx context.Context, step *Step, data Data) (string, int, error) {
+	text := step.Prompt
+	if step.Route != "" {
+		text = step.Route
+	}
+	prompt, err := render(step.ID, text, data)
+	if err != nil {
+		return "", 0, fmt.Errorf("rendering template failed: %w", err)
+	}
+
+	var vars map[string]interface{}
+	if len(step.Variables) > 0 {
+		vars = make(map[string]interface{}, len(step.Variables))
+		for name, text := range step.Variables {
+			value, err := render(step.ID, text, data)
+			if err != nil {
+				return "", 0, fmt.Errorf("rendering variable %q failed: %w", name, err)
+			}
+			vars[name] = value
+		}
+	}
+
+	attempts := 0
+	for {
+		attempts++
+		output, err := r.attempt(ctx, step, prompt, vars, data)
+		if err == nil || attempts > step.Retries || ctx.Err() != nil {
+			return output, attempts, err
+		}
+
+		select {
+		case <-time.After(time.Duration(step.RetryDelay)):
+		case <-ctx.Done():
+			return "", attempts, ctx.Err()
+		}
+	}
+}
+
+// attempt makes a single call for the step, bounded by its timeout and the
+// run's concurrency limit. A call that times out keeps its slot until it
+// actually returns, since providers cannot always be interrupted, so
+// retries never push the run past its limit.
+func (r *run) attempt(ctx context.Context, step *Step, prompt string, vars map[string]interface{}, data Data) (string, error) {
+	callCtx := ctx
+	if step.Timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, time.Duration(step.Timeout))
+		defer cancel()
+	}
+
+	select {
+	case r.sem <- struct{}{}:
+	case <-callCtx.Done():
+		return "", r.canceled(ctx, step)
+	}
+
+	type outcome struct {
+		output string
+		err    error
+	}
+	finished := make(chan outcome, 1)
+	go func() {
+		defer func() { <-r.sem }()
+		output, err := r.call(callCtx, step, prompt, vars, data)
+		finished <- outcome{output, err}
+	}()
+
+	select {
+	case o := <-finished:
+		return o.output, o.err
+	case <-callCtx.Done():
+		return "", r.canceled(ctx, step)
+	}
+}
+
+// canceled returns the error for an attempt that was cut short, telling a
+// step timeout apart from the whole run being canceled
+func (r *run) canceled(ctx context.Context, step *Step) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("%w after %s", ErrTimeout, time.Duration(step.Timeout))
+}
+
+// call performs the step's action
+func (r *run) call(ctx context.Context, step *Step, prompt string, vars map[string]interface{}, data Data) (string, error) {
+	switch {
+	case step.Func != nil:
+		return step.Func(ctx, data)
+
+	case step.Route != "":
+		if r.engine.Router == nil {
+			return "", errors.New("route steps need an engine with a router")
+		}
+		// The handler gets ctx, so a timeout or cancellation reaches it
+		result, err := r.engine.Router.ExecuteContext(ctx, prompt)
+		if err != nil {
+			return "", err
+		}
+		return result.Response, nil
+
+	default:
+		if r.engine.Provider == nil {
+			return "", errors.New("prompt steps need an engine with a provider")
+		}
+		provider, options, err := r.engine.Provider(step.Provider)
+		if err != nil {
+			return "", err
+		}
+		if step.Model != "" {
+			options.Model = step.Model
+		}
+		if step.SystemPrompt != "" {
+			options.SystemPrompt = step.SystemPrompt
+		}
+		if step.Temperature != 0 {
+			options.Temperature = step.Temperature
+		}
+		if step.MaxTokens != 0 {
+			options.MaxTokens = step.MaxTokens
+		}
+		if step.Template != "" {
+			options.Template = step.Template
+			options.Variables = vars
+		}
+		return provider.Complete(prompt, options)
+	}
+}
+
+// completed returns the results of the step's dependencies and everything
+// they depend on, all of which have finished
+func (r *run) completed(step *Step) map[string]*StepResult {
+	deps := make(map[string][]string, len(r.wf.Steps))
+	for _, s := range r.wf.Steps {
+		deps[s.ID] = s.DependsOn
+	}
+
+	completed := make(map[string]*StepResult)
+	var visit func(ids []string)
+	visit = func(ids []string) {
+		for _, id := range ids {
+			if _, seen := completed[id]; seen {
+				continue
+			}
+			completed[id] = r.results[id]
+			visit(deps[id])
+		}
+	}
+	visit(step.DependsOn)
+	return completed
+}
+
+// items returns the list a ForEach step iterates over
+func (r *run) items(step *Step) ([]interface{}, error) {
+	if value, exists := r.inputs[step.ForEach]; exists {
+		return toList(step.ForEach, value)
+	}
+
+	outputs := r.results[step.ForEach].Outputs
+	items := make([]interface{}, len(outputs))
+	for i, output := range outputs {
+		items[i] = output
+	}
+	return items, nil
+}
+
+// output renders the workflow output, or returns the last step's output
+func (r *run) output() (string, error) {
+	if r.wf.Output == "" {
+		return r.results[r.wf.Steps[len(r.wf.Steps)-1].ID].Output, nil
+	}
+	return render("output", r.wf.Output, Data{Inputs: r.inputs, Steps: r.results})
+}
+
+// toList converts an input value to a list of items
+func toList(name string, value interface{}) ([]interface{}, error) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("input %q is not a list", name)
+	}
+
+	items := make([]interface{}, v.Len())
+	for i := range items {
+		items[i] = v.Index(i).Interface()
+	}
+	return items, nil
+}
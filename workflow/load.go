@@ -0,0 +1,84 @@
+package workflow
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Parse decodes and validates a workflow written in YAML. JSON is valid
+// YAML, so JSON definitions work too.
+func Parse(data []byte) (*Workflow, error) {
+	wf, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := wf.Validate(); err != nil {
+		return nil, err
+	}
+	return wf, nil
+}
+
+// decode decodes a workflow without validating it, rejecting unknown fields
+func decode(data []byte) (*Workflow, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var wf Workflow
+	if err := decoder.Decode(&wf); err != nil {
+		return nil, fmt.Errorf("failed to parse workflow: %w", err)
+	}
+	return &wf, nil
+}
+
+// Load reads a workflow from a YAML file. A workflow without a name is
+// named after the file.
+func Load(path string) (*Workflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow: %w", err)
+	}
+
+	wf, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if wf.Name == "" {
+		wf.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := wf.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return wf, nil
+}
+
+// LoadDir loads every .yaml and .yml file in a directory, keyed by workflow
+// name
+func LoadDir(dir string) (map[string]*Workflow, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow directory: %w", err)
+	}
+
+	workflows := make(map[string]*Workflow)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		wf, err := Load(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := workflows[wf.Name]; exists {
+			return nil, fmt.Errorf("duplicate workflow name %q in %s", wf.Name, dir)
+		}
+		workflows[wf.Name] = wf
+	}
+	return workflows, nil
+}
@@ -0,0 +1,291 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Workflow is a set of steps that run in dependency order. Steps without a
+// dependency between them run concurrently.
+type Workflow struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+
+	// Inputs are the names of the inputs a run must provide
+	Inputs []string `json:"inputs,omitempty" yaml:"inputs,omitempty"`
+
+	Steps []Step `json:"steps" yaml:"steps"`
+
+	// Output is a template rendered after every step succeeded to produce
+	// the workflow's output. Defaults to the output of the last step.
+	Output string `json:"output,omitempty" yaml:"output,omitempty"`
+}
+
+// Step is a single unit of work. It runs exactly one of a completion
+// (Prompt, Template or both), Route or Func.
+//
+// Prompt and Route are text/template strings rendered with a Data value, so
+// a step can use "{{ .Inputs.topic }}", "{{ .Steps.summarize.Output }}" or,
+// inside a ForEach step, "{{ .Item }}".
+type Step struct {
+	ID string `json:"id" yaml:"id"`
+
+	// DependsOn lists the IDs of the steps that must succeed first
+	DependsOn []string `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`
+
+	// Prompt is sent to Provider as a completion
+	Prompt string `json:"prompt,omitempty" yaml:"prompt,omitempty"`
+
+	// Template names a prompt template to complete, with Prompt as its
+	// input. The engine's provider must render templates, for example by
+	// being wrapped with prompts.Library.Wrap.
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+
+	// Variables are rendered like Prompt and passed to Template
+	Variables map[string]string `json:"variables,omitempty" yaml:"variables,omitempty"`
+
+	// Route is processed by the engine's router like a user prompt, so
+	// steps can reuse existing commands and pipelines
+	Route string `json:"route,omitempty" yaml:"route,omitempty"`
+
+	// Func runs Go code instead of a prompt. It can only be set from Go.
+	Func func(ctx context.Context, data Data) (string, error) `json:"-" yaml:"-"`
+
+	// Provider names the provider for Prompt; empty uses the engine's
+	// default
+	Provider     string  `json:"provider,omitempty" yaml:"provider,omitempty"`
+	Model        string  `json:"model,omitempty" yaml:"model,omitempty"`
+	SystemPrompt string  `json:"system_prompt,omitempty" yaml:"system_prompt,omitempty"`
+	Temperature  float64 `json:"temperature,omitempty" yaml:"temperature,omitempty"`
+	MaxTokens    int     `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
+
+	// ForEach fans the step out over a list: the name of an input holding
+	// a list, or of a ForEach step it depends on. The step runs once per
+	// item and its Outputs hold the results in order.
+	ForEach string `json:"for_each,omitempty" yaml:"for_each,omitempty"`
+
+	// Retries is the number of extra attempts after a failure
+	Retries int `json:"retries,omitempty" yaml:"retries,omitempty"`
+
+	// RetryDelay is the wait between attempts
+	RetryDelay Duration `json:"retry_delay,omitempty" yaml:"retry_delay,omitempty"`
+
+	// Timeout limits each attempt; zero means no limit
+	Timeout Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// Data is the value templates are rendered with
+type Data struct {
+	Inputs map[string]interface{}
+	Steps  map[string]*StepResult
+
+	// Item and Index are the current element of a ForEach step
+	Item  interface{}
+	Index int
+}
+
+// Duration is a time.Duration written as a string such as "30s" in YAML and
+// JSON
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Plain numbers are seconds.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	return d.parse(s)
+}
+
+// MarshalYAML implements yaml.Marshaler
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler. Plain numbers are seconds.
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	var seconds float64
+	if err := value.Decode(&seconds); err == nil {
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+	return d.parse(value.Value)
+}
+
+func (d *Duration) parse(s string) error {
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Validate checks that step IDs are unique, every step runs exactly one
+// thing, dependencies exist and form no cycle, and all templates parse
+func (wf *Workflow) Validate() error {
+	if len(wf.Steps) == 0 {
+		return fmt.Errorf("workflow %q has no steps", wf.Name)
+	}
+
+	steps := make(map[string]*Step, len(wf.Steps))
+	for i := range wf.Steps {
+		step := &wf.Steps[i]
+		if step.ID == "" {
+			return fmt.Errorf("workflow %q: step %d has no id", wf.Name, i+1)
+		}
+		if _, exists := steps[step.ID]; exists {
+			return fmt.Errorf("workflow %q: duplicate step id %q", wf.Name, step.ID)
+		}
+		steps[step.ID] = step
+	}
+
+	inputs := make(map[string]bool, len(wf.Inputs))
+	for _, input := range wf.Inputs {
+		inputs[input] = true
+	}
+
+	for i := range wf.Steps {
+		step := &wf.Steps[i]
+
+		actions := 0
+		for _, set := range []bool{step.Prompt != "" || step.Template != "", step.Route != "", step.Func != nil} {
+			if set {
+				actions++
+			}
+		}
+		if actions != 1 {
+			return fmt.Errorf("workflow %q: step %q must set exactly one of prompt or template, route, or func", wf.Name, step.ID)
+		}
+
+		for _, dep := range step.DependsOn {
+			if _, exists := steps[dep]; !exists {
+				return fmt.Errorf("workflow %q: step %q depends on unknown step %q", wf.Name, step.ID, dep)
+			}
+		}
+
+		if step.ForEach != "" && !inputs[step.ForEach] {
+			source, exists := steps[step.ForEach]
+			if !exists || source.ForEach == "" || !contains(step.DependsOn, step.ForEach) {
+				return fmt.Errorf("workflow %q: step %q iterates over %q, which is neither an input nor a for_each step it depends on", wf.Name, step.ID, step.ForEach)
+			}
+		}
+
+		for _, text := range []string{step.Prompt, step.Route} {
+			if _, err := parseTemplate(step.ID, text); err != nil {
+				return fmt.Errorf("workflow %q: step %q: %w", wf.Name, step.ID, err)
+			}
+		}
+		for name, text := range step.Variables {
+			if _, err := parseTemplate(step.ID, text); err != nil {
+				return fmt.Errorf("workflow %q: step %q: variable %q: %w", wf.Name, step.ID, name, err)
+			}
+		}
+	}
+
+	if _, err := parseTemplate("output", wf.Output); err != nil {
+		return fmt.Errorf("workflow %q: output: %w", wf.Name, err)
+	}
+
+	if cycle := findCycle(wf.Steps); cycle != nil {
+		return fmt.Errorf("workflow %q: dependency cycle %s", wf.Name, strings.Join(cycle, " -> "))
+	}
+	return nil
+}
+
+// findCycle returns the step IDs of a dependency cycle, or nil
+func findCycle(steps []Step) []string {
+	deps := make(map[string][]string, len(steps))
+	for _, step := range steps {
+		deps[step.ID] = step.DependsOn
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(steps))
+	var path []string
+
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		switch state[id] {
+		case visiting:
+			for i, p := range path {
+				if p == id {
+					return append(append([]string{}, path[i:]...), id)
+				}
+			}
+		case done:
+			return nil
+		}
+
+		state[id] = visiting
+		path = append(path, id)
+		for _, dep := range deps[id] {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = done
+		return nil
+	}
+
+	for _, step := range steps {
+		if cycle := visit(step.ID); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// parseTemplate parses a step template with the workflow functions
+func parseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(template.FuncMap{
+		"join": func(items []string, sep string) string {
+			return strings.Join(items, sep)
+		},
+	}).Option("missingkey=error").Parse(text)
+}
+
+// render executes a template with the data
+func render(name, text string, data Data) (string, error) {
+	tmpl, err := parseTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func contains(items []string, item string) bool {
+	for _, candidate := range items {
+		if candidate == item {
+			return true
+		}
+	}
+	return false
+}